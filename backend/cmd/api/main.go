@@ -1,13 +1,17 @@
 package main
 
 import (
+	"context"
 	"log"
 	"net/http"
 
 	"github.com/gorilla/mux"
+	"github.com/josedelrio85/bndcmp_downloader/internal/album_catalog"
 	"github.com/josedelrio85/bndcmp_downloader/internal/handler"
+	"github.com/josedelrio85/bndcmp_downloader/internal/jobs"
 	"github.com/josedelrio85/bndcmp_downloader/internal/scrapper"
 	"github.com/josedelrio85/bndcmp_downloader/internal/setup"
+	"github.com/josedelrio85/bndcmp_downloader/internal/verifier"
 	"github.com/rs/cors"
 )
 
@@ -27,28 +31,60 @@ func main() {
 
 func setupHttpHHandler() *handler.HttpHandler {
 	config := setup.LoadConfig()
+	if config.WatchConfig.Enabled {
+		startWatcher(config)
+	}
 
-	discographyScrapper := scrapper.NewDiscographyScrapper(config.Retriever, config.Parser, config.Saver, config.AlbumCatalog)
-	albumScrapper := scrapper.NewAlbumScrapper(config.Retriever, config.Parser, config.Saver, config.AlbumCatalog)
-	trackScrapper := scrapper.NewTrackScrapper(config.Retriever, config.Parser, config.Saver, config.AlbumCatalog)
+	// Every request and job gets scrappers of its own.
+	factory := scrapper.NewFactory(config.Retriever, config.Parser, config.Saver, config.AlbumCatalog)
+	factory.SetOptions(config.ScrapperOptions)
 
 	return handler.NewHttpHandler(
 		config.BaseFolder,
-		discographyScrapper,
-		albumScrapper,
-		trackScrapper,
+		factory,
+		verifier.NewLibraryVerifier(config.BaseFolder, config.AlbumCatalog),
+		startJobs(config, factory),
 	)
 }
 
+// startJobs runs the download jobs queued through the API in the background,
+// resuming the ones interrupted by the previous shutdown.
+func startJobs(config *setup.Config, factory jobs.Factory) *jobs.Manager {
+	manager := jobs.NewManager(factory, setup.NewJobStore(config.BaseFolder), config.QueueConfig)
+	if err := manager.Resume(); err != nil {
+		log.Println("Error resuming download jobs: ", err)
+	}
+	go manager.Run(context.Background())
+	return manager
+}
+
+// startWatcher keeps the album catalog in sync with the library while the API runs.
+func startWatcher(config *setup.Config) {
+	watcher, err := album_catalog.NewWatcher(config.AlbumCatalog, config.BaseFolder, config.WatchConfig)
+	if err != nil {
+		log.Println("Error watching the library, the album catalog will not be updated: ", err)
+		return
+	}
+	go watcher.Run(context.Background())
+}
+
 func setupRouter(httpHandler *handler.HttpHandler) http.Handler {
 	r := mux.NewRouter()
 	apiV1 := r.PathPrefix("/api/v1").Subrouter()
 	apiV1.HandleFunc("/health", httpHandler.Health).Methods("GET")
 	apiV1.HandleFunc("/scrapp", httpHandler.Scrapp).Methods("GET")
+	apiV1.HandleFunc("/verify", httpHandler.Verify).Methods("GET", "POST")
+	apiV1.HandleFunc("/jobs", httpHandler.CreateJob).Methods("POST")
+	apiV1.HandleFunc("/jobs", httpHandler.ListJobs).Methods("GET")
+	apiV1.HandleFunc("/jobs/{id}", httpHandler.GetJob).Methods("GET")
+	apiV1.HandleFunc("/jobs/{id}", httpHandler.CancelJob).Methods("DELETE")
+	apiV1.HandleFunc("/jobs/{id}/pause", httpHandler.PauseJob).Methods("POST")
+	apiV1.HandleFunc("/jobs/{id}/resume", httpHandler.ResumeJob).Methods("POST")
+	apiV1.HandleFunc("/jobs/{id}/events", httpHandler.JobEvents).Methods("GET")
 
 	c := cors.New(cors.Options{
 		AllowedOrigins: []string{"http://localhost:5173", "http://localhost:8080", "http://192.168.50.10:8080", "https://bndcmp.leningrado"},
-		AllowedMethods: []string{"GET", "POST", "OPTIONS"},
+		AllowedMethods: []string{"GET", "POST", "DELETE", "OPTIONS"},
 		AllowedHeaders: []string{"*"},
 	})
 	return c.Handler(r)
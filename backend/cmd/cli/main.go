@@ -1,36 +1,70 @@
 package main
 
 import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
 	"log"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
 
-	"github.com/josedelrio85/bndcmp_downloader/internal/album_catalog"
 	"github.com/josedelrio85/bndcmp_downloader/internal/parser"
 	"github.com/josedelrio85/bndcmp_downloader/internal/prompt"
 	"github.com/josedelrio85/bndcmp_downloader/internal/retriever"
 	"github.com/josedelrio85/bndcmp_downloader/internal/saver"
 	"github.com/josedelrio85/bndcmp_downloader/internal/scrapper"
+	appsetup "github.com/josedelrio85/bndcmp_downloader/internal/setup"
+	"github.com/josedelrio85/bndcmp_downloader/internal/verifier"
 )
 
 func main() {
 	log.Println("Starting Bandcamp downloader CLI")
 
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	if len(os.Args) > 1 && os.Args[1] == "verify" {
+		verify(ctx, os.Args[2:])
+		return
+	}
+
 	promptChain := setupPromptChain()
 
-	httpClient, parseClient, saveClient := setup(&promptChain.ChainMessage.StorageType)
+	options := appsetup.LoadScrapperOptions()
+	httpClient, parseClient, saveClient := setup(&promptChain.ChainMessage.StorageType, options)
+
+	// walking the whole library takes a while, downloads do not wait for it
+	go func() {
+		if err := saveClient.CleanPartials(); err != nil {
+			log.Println("Error cleaning partial downloads: ", err)
+		}
+	}()
 
-	inMemoryAlbumCatalog := album_catalog.NewInMemoryAlbumCatalog(promptChain.ChainMessage.StorageType)
-	if err := inMemoryAlbumCatalog.Generate(promptChain.ChainMessage.StorageType); err != nil {
+	albumCatalog := appsetup.NewAlbumCatalog(promptChain.ChainMessage.StorageType)
+	if closer, ok := albumCatalog.(io.Closer); ok {
+		defer closer.Close()
+	}
+	if err := albumCatalog.Generate(promptChain.ChainMessage.StorageType); err != nil {
 		log.Println("Error generating album catalog: ", err)
 	}
 
 	var err error
 	switch promptChain.ChainMessage.ScrapType {
 	case scrapper.Track:
-		err = scrapper.NewTrackScrapper(httpClient, parseClient, saveClient, inMemoryAlbumCatalog).Execute(promptChain.ChainMessage.URL.URL)
+		trackScrapper := scrapper.NewTrackScrapper(httpClient, parseClient, saveClient, albumCatalog)
+		trackScrapper.SetOptions(options)
+		err = trackScrapper.Execute(ctx, promptChain.ChainMessage.URL.URL)
 	case scrapper.Album:
-		err = scrapper.NewAlbumScrapper(httpClient, parseClient, saveClient, inMemoryAlbumCatalog).Execute(promptChain.ChainMessage.URL.URL)
+		albumScrapper := scrapper.NewAlbumScrapper(httpClient, parseClient, saveClient, albumCatalog)
+		albumScrapper.SetOptions(options)
+		err = albumScrapper.Execute(ctx, promptChain.ChainMessage.URL.URL)
 	case scrapper.Discography:
-		err = scrapper.NewDiscographyScrapper(httpClient, parseClient, saveClient, inMemoryAlbumCatalog).Execute(promptChain.ChainMessage.URL.URL)
+		discographyScrapper := scrapper.NewDiscographyScrapper(httpClient, parseClient, saveClient, albumCatalog)
+		discographyScrapper.SetOptions(options)
+		err = discographyScrapper.Execute(ctx, promptChain.ChainMessage.URL.URL)
 	default:
 		log.Println("Invalid scrap type")
 	}
@@ -40,10 +74,48 @@ func main() {
 	}
 }
 
-func setup(saveFolder *string) (*retriever.HttpClient, *parser.ParseClient, *saver.LocalSaver) {
-	httpClient := retriever.NewHttpClient()
+// verify checks the library for damaged files and partial downloads left behind:
+// verify [-repair] [folder], the current directory by default.
+func verify(ctx context.Context, args []string) {
+	flags := flag.NewFlagSet("verify", flag.ExitOnError)
+	repair := flags.Bool("repair", false, "remove the damaged files and forget them in the album catalog, so the next download fetches them again")
+	flags.Parse(args)
+	folder := "."
+	if flags.NArg() > 0 {
+		folder = flags.Arg(0)
+	}
+
+	albumCatalog := appsetup.NewAlbumCatalog(folder)
+	if closer, ok := albumCatalog.(io.Closer); ok {
+		defer closer.Close()
+	}
+	if err := albumCatalog.Generate(folder); err != nil {
+		log.Println("Error generating album catalog: ", err)
+		return
+	}
+
+	report, err := verifier.NewLibraryVerifier(folder, albumCatalog).Verify(ctx, *repair)
+	if err != nil {
+		log.Println("Error verifying library: ", err)
+		return
+	}
+	for _, issue := range report.Issues {
+		line := []string{string(issue.Problem), issue.Path}
+		if issue.Detail != "" {
+			line = append(line, issue.Detail)
+		}
+		if issue.Repaired {
+			line = append(line, "repaired")
+		}
+		fmt.Println(strings.Join(line, "\t"))
+	}
+	fmt.Printf("Checked %d files, found %d problems\n", report.Checked, len(report.Issues))
+}
+
+func setup(saveFolder *string, options scrapper.Options) (*retriever.RetryingClient, *parser.ParseClient, *saver.LocalSaver) {
+	httpClient := appsetup.NewRetriever(appsetup.LoadRetryConfig(), appsetup.LoadRateLimitConfig())
 	parseClient := parser.NewParseClient()
-	saveClient := saver.NewLocalSaver(saveFolder)
+	saveClient := saver.NewLocalSaver(saveFolder, options.Layout)
 
 	return httpClient, parseClient, saveClient
 }
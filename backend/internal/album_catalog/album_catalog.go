@@ -6,33 +6,78 @@ import (
 	"path/filepath"
 	"strings"
 	"sync"
+
+	"github.com/josedelrio85/bndcmp_downloader/internal/model"
+	"github.com/josedelrio85/bndcmp_downloader/internal/sanitize"
+	"github.com/josedelrio85/bndcmp_downloader/internal/saver"
 )
 
+// AlbumCatalog indexes the downloaded tracks by their library path, relative to the library
+// folder. Paths are matched by sanitize.Key. Implementations are safe for concurrent use.
+//
 //go:generate mockgen -source=$GOFILE -package=$GOPACKAGE -destination=mock_$GOFILE
 type AlbumCatalog interface {
 	Generate(folder string) error
-	GetMapDir() *map[string]bool
-	Update(path string)
+	// Update records a track just saved at path, relative to the library folder.
+	Update(path string, track *model.Track)
+	// Lookup returns the path a track was saved at by its Bandcamp track id, which
+	// unlike the path survives renames on Bandcamp and layout changes.
+	Lookup(trackID int64) (string, bool)
+	Contains(path string) bool
+	// Remove forgets the track at path, so the next scrape downloads it again.
+	Remove(path string)
+	ListArtists() []string
+	ListAlbums(artist string) []Album
+	ListTracks(album Album) []string
+	Stats() Stats
 }
 
 type InMemoryAlbumCatalog struct {
-	mapDir     map[string]bool
+	// mapDir maps the key of every file of the library to its path.
+	mapDir     map[string]string
+	trackIDs   map[int64]string
 	baseFolder string
-	mutex      sync.Mutex
+	mutex      sync.RWMutex
 }
 
 func NewInMemoryAlbumCatalog(baseFolder string) *InMemoryAlbumCatalog {
 	return &InMemoryAlbumCatalog{
-		mapDir:     make(map[string]bool),
+		mapDir:     make(map[string]string),
+		trackIDs:   make(map[int64]string),
 		baseFolder: baseFolder,
-		mutex:      sync.Mutex{},
 	}
 }
 
+// Generate indexes the files below folder, forgetting the ones that are gone since.
 func (i *InMemoryAlbumCatalog) Generate(folder string) error {
 	if i.baseFolder == "" {
 		i.baseFolder = folder
 	}
+	found := make(map[string]string)
+	if err := i.walk(folder, found); err != nil {
+		return err
+	}
+
+	prefix := sanitize.Key(i.relative(folder))
+	i.mutex.Lock()
+	defer i.mutex.Unlock()
+	for key := range i.mapDir {
+		if _, ok := found[key]; !ok && (prefix == "." || key == prefix || strings.HasPrefix(key, prefix+"/")) {
+			delete(i.mapDir, key)
+		}
+	}
+	for trackID, path := range i.trackIDs {
+		if _, ok := i.mapDir[sanitize.Key(path)]; !ok {
+			delete(i.trackIDs, trackID)
+		}
+	}
+	for key, path := range found {
+		i.mapDir[key] = path
+	}
+	return nil
+}
+
+func (i *InMemoryAlbumCatalog) walk(folder string, found map[string]string) error {
 	entries, err := os.ReadDir(folder)
 	if err != nil {
 		log.Printf("InMemoryAlbumCatalog -> Generate -> error iterating over folder: %s: %v\n", folder, err)
@@ -41,25 +86,89 @@ func (i *InMemoryAlbumCatalog) Generate(folder string) error {
 
 	for _, entry := range entries {
 		nextTrack := filepath.Join(folder, entry.Name())
+		// such as the quarantine of the saver and the databases of the API
+		if saver.Internal(entry.Name()) {
+			continue
+		}
 		if entry.IsDir() {
-			i.Generate(nextTrack)
-		} else {
-			i.mutex.Lock()
-			nextTrack = strings.TrimPrefix(nextTrack, i.baseFolder)
-			nextTrack = strings.TrimPrefix(nextTrack, string(os.PathSeparator))
-			i.mapDir[nextTrack] = true
-			i.mutex.Unlock()
+			i.walk(nextTrack, found)
+		} else if !saver.IsPartial(entry.Name()) {
+			nextTrack = i.relative(nextTrack)
+			found[sanitize.Key(nextTrack)] = nextTrack
 		}
 	}
 	return nil
 }
 
-func (i *InMemoryAlbumCatalog) GetMapDir() *map[string]bool {
-	return &i.mapDir
+// relative returns path relative to the library folder, with forward slashes.
+func (i *InMemoryAlbumCatalog) relative(path string) string {
+	if rel, err := filepath.Rel(i.baseFolder, path); err == nil {
+		return filepath.ToSlash(rel)
+	}
+	path = strings.TrimPrefix(path, i.baseFolder)
+	return filepath.ToSlash(strings.TrimPrefix(path, string(os.PathSeparator)))
 }
 
-func (i *InMemoryAlbumCatalog) Update(path string) {
+func (i *InMemoryAlbumCatalog) Update(path string, track *model.Track) {
 	i.mutex.Lock()
-	i.mapDir[path] = true
+	i.mapDir[sanitize.Key(path)] = path
+	if track != nil && track.TrackID != 0 {
+		i.trackIDs[track.TrackID] = path
+	}
 	i.mutex.Unlock()
 }
+
+func (i *InMemoryAlbumCatalog) Remove(path string) {
+	key := sanitize.Key(path)
+	i.mutex.Lock()
+	defer i.mutex.Unlock()
+	delete(i.mapDir, key)
+	for trackID, trackPath := range i.trackIDs {
+		if sanitize.Key(trackPath) == key {
+			delete(i.trackIDs, trackID)
+		}
+	}
+}
+
+// Lookup only knows the tracks saved since the catalog was generated, the library
+// files themselves do not carry their Bandcamp ids.
+func (i *InMemoryAlbumCatalog) Lookup(trackID int64) (string, bool) {
+	i.mutex.RLock()
+	defer i.mutex.RUnlock()
+	path, ok := i.trackIDs[trackID]
+	return path, ok
+}
+
+func (i *InMemoryAlbumCatalog) Contains(path string) bool {
+	i.mutex.RLock()
+	defer i.mutex.RUnlock()
+	_, ok := i.mapDir[sanitize.Key(path)]
+	return ok
+}
+
+func (i *InMemoryAlbumCatalog) ListArtists() []string {
+	return listArtists(i.paths())
+}
+
+func (i *InMemoryAlbumCatalog) ListAlbums(artist string) []Album {
+	return listAlbums(i.paths(), artist)
+}
+
+func (i *InMemoryAlbumCatalog) ListTracks(album Album) []string {
+	return listTracks(i.paths(), album)
+}
+
+func (i *InMemoryAlbumCatalog) Stats() Stats {
+	return stats(i.paths())
+}
+
+// paths copies the catalog, so queries do not hold the lock while sorting it.
+func (i *InMemoryAlbumCatalog) paths() []string {
+	i.mutex.RLock()
+	defer i.mutex.RUnlock()
+	paths := make([]string, 0, len(i.mapDir))
+	for _, path := range i.mapDir {
+		paths = append(paths, path)
+	}
+	return paths
+}
@@ -5,7 +5,11 @@ import (
 	"path/filepath"
 	"testing"
 
+	"github.com/josedelrio85/bndcmp_downloader/internal/model"
+	"github.com/josedelrio85/bndcmp_downloader/internal/sanitize"
+	"github.com/josedelrio85/bndcmp_downloader/internal/saver"
 	"github.com/stretchr/testify/suite"
+	"golang.org/x/text/unicode/norm"
 )
 
 type AlbumCatalogTestSuite struct {
@@ -43,7 +47,7 @@ func (s *AlbumCatalogTestSuite) TestGenerate_SingleFile() {
 	err = s.catalog.Generate(s.tempDir)
 	s.Require().NoError(err)
 	s.Len(s.catalog.mapDir, 1)
-	s.True(s.catalog.mapDir[filename])
+	s.True(s.catalog.Contains(filename))
 }
 
 func (s *AlbumCatalogTestSuite) TestGenerate_NestedDirectories() {
@@ -61,8 +65,44 @@ func (s *AlbumCatalogTestSuite) TestGenerate_NestedDirectories() {
 	err = s.catalog.Generate(s.tempDir)
 	s.Require().NoError(err)
 	s.Len(s.catalog.mapDir, 2) // 2 files
-	s.True(s.catalog.mapDir[filename1])
-	s.True(s.catalog.mapDir[filepath.Join("nested", filename2)])
+	s.True(s.catalog.Contains(filename1))
+	s.True(s.catalog.Contains(filepath.Join("nested", filename2)))
+}
+
+func (s *AlbumCatalogTestSuite) TestGenerate_NormalizedKeys() {
+	albumDir := filepath.Join(s.tempDir, norm.NFD.String("Björk"), "Debut")
+	s.Require().NoError(os.MkdirAll(albumDir, 0755))
+	s.Require().NoError(os.WriteFile(filepath.Join(albumDir, "01 - Human Behaviour.mp3"), []byte("audio"), 0644))
+
+	err := s.catalog.Generate(s.tempDir)
+
+	s.Require().NoError(err)
+	s.True(s.catalog.Contains(sanitize.Key(norm.NFC.String("Björk/Debut/01 - Human Behaviour.mp3"))))
+	s.True(s.catalog.Contains(sanitize.Key("BJÖRK/debut/01 - human behaviour.mp3")))
+}
+
+func (s *AlbumCatalogTestSuite) TestGenerate_SkipsPartialDownloads() {
+	s.Require().NoError(os.WriteFile(filepath.Join(s.tempDir, "01 - Done.mp3"), []byte("done"), 0644))
+	s.Require().NoError(os.WriteFile(filepath.Join(s.tempDir, "02 - Crashed.mp3"+saver.PartialExtension), []byte("cra"), 0644))
+	s.Require().NoError(os.Mkdir(filepath.Join(s.tempDir, saver.QuarantineFolder), 0755))
+	s.Require().NoError(os.WriteFile(filepath.Join(s.tempDir, saver.QuarantineFolder, "03 - Invalid.mp3"), []byte("html"), 0644))
+	s.Require().NoError(os.WriteFile(filepath.Join(s.tempDir, ".jobs.db"), []byte("jobs"), 0644))
+
+	err := s.catalog.Generate(s.tempDir)
+
+	s.Require().NoError(err)
+	s.Len(s.catalog.mapDir, 1)
+	s.True(s.catalog.Contains("01 - done.mp3"))
+}
+
+func (s *AlbumCatalogTestSuite) TestGenerate_LeadingDots() {
+	s.Require().NoError(os.MkdirAll(filepath.Join(s.tempDir, "Queens of the Stone Age", "...Like Clockwork"), 0755))
+	s.Require().NoError(os.WriteFile(filepath.Join(s.tempDir, "Queens of the Stone Age", "...Like Clockwork", "01 - Keep Your Eyes Peeled.mp3"), []byte("audio"), 0644))
+
+	err := s.catalog.Generate(s.tempDir)
+
+	s.Require().NoError(err)
+	s.True(s.catalog.Contains(sanitize.Key("Queens of the Stone Age/...Like Clockwork/01 - Keep Your Eyes Peeled.mp3")))
 }
 
 func (s *AlbumCatalogTestSuite) TestGenerate_NonExistentDirectory() {
@@ -87,23 +127,44 @@ func (s *AlbumCatalogTestSuite) TestNewInMemoryAlbumCatalog() {
 	s.Empty(catalog.mapDir)
 }
 
-func (s *AlbumCatalogTestSuite) TestGetMapDir() {
-	s.catalog.mapDir["test1.txt"] = true
-	s.catalog.mapDir["test2.txt"] = true
-
-	result := s.catalog.GetMapDir()
+func (s *AlbumCatalogTestSuite) TestContains() {
+	s.catalog.Update("Artist/Album/01 - Track.mp3", nil)
 
-	s.Equal(&s.catalog.mapDir, result)
-	s.Len(*result, 2)
-	s.True((*result)["test1.txt"])
-	s.True((*result)["test2.txt"])
+	s.True(s.catalog.Contains("Artist/Album/01 - Track.mp3"))
+	s.True(s.catalog.Contains("artist/ALBUM/01 - track.mp3"))
+	s.False(s.catalog.Contains("Artist/Album/02 - Track.mp3"))
 }
 
 func (s *AlbumCatalogTestSuite) TestUpdate() {
-	s.catalog.mapDir["test1.txt"] = true
+	s.catalog.Update("test1.txt", nil)
+
+	s.catalog.Update("test2.txt", nil)
+
+	s.True(s.catalog.Contains("test1.txt"))
+	s.True(s.catalog.Contains("test2.txt"))
+}
+
+func (s *AlbumCatalogTestSuite) TestLookup() {
+	s.catalog.Update("Artist/Album/01 - Track.mp3", &model.Track{TrackID: 3749823254})
+
+	path, found := s.catalog.Lookup(3749823254)
+	s.True(found)
+	s.Equal("Artist/Album/01 - Track.mp3", path)
+
+	_, found = s.catalog.Lookup(1090418036)
+	s.False(found)
+}
+
+func (s *AlbumCatalogTestSuite) TestRemove() {
+	s.catalog.Update("Artist/Album/01 - Track.mp3", &model.Track{TrackID: 3749823254})
+	s.catalog.Update("Artist/Album/02 - Track.mp3", &model.Track{TrackID: 1090418036})
 
-	s.catalog.Update("test2.txt")
+	s.catalog.Remove("artist/album/01 - track.mp3")
 
-	s.True(s.catalog.mapDir["test1.txt"])
-	s.True(s.catalog.mapDir["test2.txt"])
+	s.False(s.catalog.Contains("Artist/Album/01 - Track.mp3"))
+	_, found := s.catalog.Lookup(3749823254)
+	s.False(found)
+	s.True(s.catalog.Contains("Artist/Album/02 - Track.mp3"))
+	_, found = s.catalog.Lookup(1090418036)
+	s.True(found)
 }
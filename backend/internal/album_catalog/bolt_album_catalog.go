@@ -0,0 +1,431 @@
+package album_catalog
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/josedelrio85/bndcmp_downloader/internal/model"
+	"github.com/josedelrio85/bndcmp_downloader/internal/sanitize"
+	"github.com/josedelrio85/bndcmp_downloader/internal/saver"
+	bolt "go.etcd.io/bbolt"
+)
+
+var (
+	tracksBucket      = []byte("tracks")
+	directoriesBucket = []byte("directories")
+	// trackIDsBucket maps a Bandcamp track id to the key of its entry in tracksBucket.
+	trackIDsBucket = []byte("track_ids")
+)
+
+// Entry is what the persistent catalog stores for every file of the library.
+type Entry struct {
+	// Path is relative to the library folder, with forward slashes.
+	Path     string `json:"path"`
+	Size     int64  `json:"size"`
+	ModTime  int64  `json:"mod_time"`
+	Checksum string `json:"checksum"`
+	TrackID  int64  `json:"track_id,omitempty"`
+	AlbumID  int64  `json:"album_id,omitempty"`
+	// Downloaded is the size of the file when it was saved, zero for the files only
+	// known from a scan. Scans keep it, so files changed since can be told apart.
+	Downloaded int64 `json:"downloaded,omitempty"`
+}
+
+// directory remembers the modification time of a folder when it was last scanned and
+// its subfolders, so unchanged folders are walked without being listed again.
+type directory struct {
+	ModTime int64    `json:"mod_time"`
+	Subdirs []string `json:"subdirs"`
+}
+
+// BoltAlbumCatalog keeps the catalog in a bbolt database: tracks are known as soon as it
+// is opened and Generate only lists the folders modified since the previous scan. Queries
+// are read transactions, which bbolt runs concurrently with the writes of Update and
+// Generate, so the library can be scanned in the background.
+type BoltAlbumCatalog struct {
+	db         *bolt.DB
+	dbPath     string
+	baseFolder string
+}
+
+func NewBoltAlbumCatalog(baseFolder string, dbPath string) (*BoltAlbumCatalog, error) {
+	db, err := bolt.Open(dbPath, 0600, &bolt.Options{Timeout: time.Second})
+	if err != nil {
+		return nil, fmt.Errorf("opening album catalog %s: %w", dbPath, err)
+	}
+
+	err = db.Update(func(tx *bolt.Tx) error {
+		for _, name := range [][]byte{tracksBucket, directoriesBucket, trackIDsBucket} {
+			if _, err := tx.CreateBucketIfNotExists(name); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		db.Close()
+		return nil, fmt.Errorf("initializing album catalog %s: %w", dbPath, err)
+	}
+
+	return &BoltAlbumCatalog{
+		db:         db,
+		dbPath:     dbPath,
+		baseFolder: baseFolder,
+	}, nil
+}
+
+func (b *BoltAlbumCatalog) Close() error {
+	return b.db.Close()
+}
+
+// Generate brings the catalog up to date with folder, which has to be the library
+// folder or one of its subfolders. Every folder is committed on its own, an interrupted
+// scan keeps the folders it went through. Files are not read: their checksum comes from
+// the saver or the verifier.
+func (b *BoltAlbumCatalog) Generate(folder string) error {
+	if b.baseFolder == "" {
+		b.baseFolder = folder
+	}
+	rel, err := filepath.Rel(b.baseFolder, folder)
+	if err != nil {
+		log.Printf("BoltAlbumCatalog -> Generate -> folder %s is not in %s: %v\n", folder, b.baseFolder, err)
+		return err
+	}
+
+	return b.scan(filepath.ToSlash(rel))
+}
+
+func (b *BoltAlbumCatalog) Update(path string, track *model.Track) {
+	entry := Entry{Path: path}
+	if track != nil {
+		entry.TrackID = track.TrackID
+		entry.AlbumID = track.AlbumID
+		entry.Checksum = track.Checksum
+	}
+	if err := b.describe(&entry); err != nil {
+		// still recorded, the next scan of its folder fills in the file details
+		log.Printf("BoltAlbumCatalog -> Update -> error reading %s: %v\n", path, err)
+	}
+	entry.Downloaded = entry.Size
+
+	key := sanitize.Key(path)
+	err := b.db.Update(func(tx *bolt.Tx) error {
+		if err := putJSON(tx.Bucket(tracksBucket), []byte(key), entry); err != nil {
+			return err
+		}
+		if entry.TrackID == 0 {
+			return nil
+		}
+		return tx.Bucket(trackIDsBucket).Put(trackIDKey(entry.TrackID), []byte(key))
+	})
+	if err != nil {
+		log.Printf("BoltAlbumCatalog -> Update -> error storing %s: %v\n", path, err)
+	}
+}
+
+// SetChecksum records the checksum of path computed by the verifier, for the files only
+// known from a scan.
+func (b *BoltAlbumCatalog) SetChecksum(path string, checksum string) {
+	key := []byte(sanitize.Key(path))
+	err := b.db.Update(func(tx *bolt.Tx) error {
+		var entry Entry
+		if !getJSON(tx.Bucket(tracksBucket), key, &entry) {
+			return nil
+		}
+		entry.Checksum = checksum
+		return putJSON(tx.Bucket(tracksBucket), key, entry)
+	})
+	if err != nil {
+		log.Printf("BoltAlbumCatalog -> SetChecksum -> error storing %s: %v\n", path, err)
+	}
+}
+
+func (b *BoltAlbumCatalog) Remove(path string) {
+	key := []byte(sanitize.Key(path))
+	err := b.db.Update(func(tx *bolt.Tx) error {
+		return forget(tx, key)
+	})
+	if err != nil {
+		log.Printf("BoltAlbumCatalog -> Remove -> error removing %s: %v\n", path, err)
+	}
+}
+
+// Entry returns what the catalog stores for path, relative to the library folder.
+func (b *BoltAlbumCatalog) Entry(path string) (Entry, bool) {
+	var entry Entry
+	var found bool
+	b.db.View(func(tx *bolt.Tx) error {
+		found = getJSON(tx.Bucket(tracksBucket), []byte(sanitize.Key(path)), &entry)
+		return nil
+	})
+	return entry, found
+}
+
+func (b *BoltAlbumCatalog) Lookup(trackID int64) (string, bool) {
+	var entry Entry
+	var found bool
+	b.db.View(func(tx *bolt.Tx) error {
+		if key := tx.Bucket(trackIDsBucket).Get(trackIDKey(trackID)); key != nil {
+			found = getJSON(tx.Bucket(tracksBucket), key, &entry) && entry.TrackID == trackID
+		}
+		return nil
+	})
+	return entry.Path, found
+}
+
+func (b *BoltAlbumCatalog) Contains(path string) bool {
+	var found bool
+	b.db.View(func(tx *bolt.Tx) error {
+		found = tx.Bucket(tracksBucket).Get([]byte(sanitize.Key(path))) != nil
+		return nil
+	})
+	return found
+}
+
+func (b *BoltAlbumCatalog) ListArtists() []string {
+	return listArtists(b.paths())
+}
+
+func (b *BoltAlbumCatalog) ListAlbums(artist string) []Album {
+	return listAlbums(b.paths(), artist)
+}
+
+func (b *BoltAlbumCatalog) ListTracks(album Album) []string {
+	return listTracks(b.paths(), album)
+}
+
+func (b *BoltAlbumCatalog) Stats() Stats {
+	return stats(b.paths())
+}
+
+func (b *BoltAlbumCatalog) paths() []string {
+	var paths []string
+	err := b.db.View(func(tx *bolt.Tx) error {
+		return tx.Bucket(tracksBucket).ForEach(func(key, value []byte) error {
+			var entry Entry
+			if err := json.Unmarshal(value, &entry); err != nil {
+				log.Printf("BoltAlbumCatalog -> error decoding %s: %v\n", key, err)
+				return nil
+			}
+			paths = append(paths, entry.Path)
+			return nil
+		})
+	})
+	if err != nil {
+		log.Printf("BoltAlbumCatalog -> error reading %s: %v\n", b.dbPath, err)
+	}
+	return paths
+}
+
+// scan indexes the folder rel. Adding, removing or renaming an entry updates the
+// modification time of its folder, so a folder that kept it is only walked through.
+// A subfolder that cannot be read is skipped, its files stay in the catalog.
+func (b *BoltAlbumCatalog) scan(rel string) error {
+	folder := b.fullPath(rel)
+	info, err := os.Stat(folder)
+	if err != nil {
+		log.Printf("BoltAlbumCatalog -> Generate -> error reading folder: %s: %v\n", folder, err)
+		return err
+	}
+
+	var known directory
+	var found bool
+	b.db.View(func(tx *bolt.Tx) error {
+		found = getJSON(tx.Bucket(directoriesBucket), []byte(rel), &known)
+		return nil
+	})
+	subdirs := known.Subdirs
+	if !found || known.ModTime != info.ModTime().UnixNano() {
+		if subdirs, err = b.list(rel, info, known); err != nil {
+			return err
+		}
+	}
+
+	for _, subdir := range subdirs {
+		b.scan(path.Join(rel, subdir))
+	}
+	return nil
+}
+
+// list indexes the files of the folder rel in one transaction and returns its subfolders.
+func (b *BoltAlbumCatalog) list(rel string, info os.FileInfo, known directory) ([]string, error) {
+	folder := b.fullPath(rel)
+	entries, err := os.ReadDir(folder)
+	if err != nil {
+		log.Printf("BoltAlbumCatalog -> Generate -> error iterating over folder: %s: %v\n", folder, err)
+		return nil, err
+	}
+
+	current := directory{ModTime: info.ModTime().UnixNano()}
+	var files []string
+	for _, entry := range entries {
+		entryPath := path.Join(rel, entry.Name())
+		switch {
+		case saver.Internal(entry.Name()):
+			// such as the quarantine of the saver and the databases of the API
+		case entry.IsDir():
+			current.Subdirs = append(current.Subdirs, entry.Name())
+		case saver.IsPartial(entry.Name()) || b.isDatabase(entryPath):
+		default:
+			files = append(files, entryPath)
+		}
+	}
+
+	err = b.db.Update(func(tx *bolt.Tx) error {
+		indexed := make(map[string]bool)
+		for _, file := range files {
+			if err := b.index(tx, file); err != nil {
+				log.Printf("BoltAlbumCatalog -> Generate -> error indexing %s: %v\n", file, err)
+				continue
+			}
+			indexed[sanitize.Key(file)] = true
+		}
+		if err := b.forgetMissing(tx, rel, indexed, known.Subdirs, current.Subdirs); err != nil {
+			return err
+		}
+		return putJSON(tx.Bucket(directoriesBucket), []byte(rel), current)
+	})
+	if err != nil {
+		log.Printf("BoltAlbumCatalog -> Generate -> error storing folder: %s: %v\n", folder, err)
+		return nil, err
+	}
+	return current.Subdirs, nil
+}
+
+// index stores the file at rel. A file whose size or modification time changed loses
+// its checksum, it no longer tells whether the file rots.
+func (b *BoltAlbumCatalog) index(tx *bolt.Tx, rel string) error {
+	tracks := tx.Bucket(tracksBucket)
+	key := []byte(sanitize.Key(rel))
+
+	var stored Entry
+	getJSON(tracks, key, &stored)
+	entry := Entry{Path: rel, TrackID: stored.TrackID, AlbumID: stored.AlbumID, Downloaded: stored.Downloaded}
+	if err := b.describe(&entry); err != nil {
+		return err
+	}
+	if stored.Path == rel && stored.Size == entry.Size && stored.ModTime == entry.ModTime {
+		return nil
+	}
+	return putJSON(tracks, key, entry)
+}
+
+// forgetMissing removes the files of folder rel that are not in files anymore, and
+// everything below the subfolders that disappeared.
+func (b *BoltAlbumCatalog) forgetMissing(tx *bolt.Tx, rel string, files map[string]bool, knownSubdirs []string, subdirs []string) error {
+	tracks := tx.Bucket(tracksBucket)
+	prefix := ""
+	if rel != "." {
+		prefix = sanitize.Key(rel) + "/"
+	}
+
+	var stale [][]byte
+	cursor := tracks.Cursor()
+	for key, _ := cursor.Seek([]byte(prefix)); key != nil && bytes.HasPrefix(key, []byte(prefix)); key, _ = cursor.Next() {
+		if name := strings.TrimPrefix(string(key), prefix); !strings.Contains(name, "/") && !files[string(key)] {
+			stale = append(stale, slices.Clone(key))
+		}
+	}
+
+	directories := tx.Bucket(directoriesBucket)
+	for _, subdir := range knownSubdirs {
+		if slices.Contains(subdirs, subdir) {
+			continue
+		}
+		removed := path.Join(rel, subdir)
+		stale = append(stale, withPrefix(tracks, sanitize.Key(removed)+"/")...)
+		for _, key := range append(withPrefix(directories, removed+"/"), []byte(removed)) {
+			if err := directories.Delete(key); err != nil {
+				return err
+			}
+		}
+	}
+
+	for _, key := range stale {
+		if err := forget(tx, key); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// forget deletes the entry stored at key along with its track id, unless the track
+// was saved again at another path since.
+func forget(tx *bolt.Tx, key []byte) error {
+	tracks := tx.Bucket(tracksBucket)
+	trackIDs := tx.Bucket(trackIDsBucket)
+	var entry Entry
+	if getJSON(tracks, key, &entry) && entry.TrackID != 0 && bytes.Equal(trackIDs.Get(trackIDKey(entry.TrackID)), key) {
+		if err := trackIDs.Delete(trackIDKey(entry.TrackID)); err != nil {
+			return err
+		}
+	}
+	return tracks.Delete(key)
+}
+
+// describe fills in the size and modification time of the file of entry.
+func (b *BoltAlbumCatalog) describe(entry *Entry) error {
+	info, err := os.Stat(b.fullPath(entry.Path))
+	if err != nil {
+		return err
+	}
+	entry.Size = info.Size()
+	entry.ModTime = info.ModTime().UnixNano()
+	return nil
+}
+
+func (b *BoltAlbumCatalog) fullPath(rel string) string {
+	return filepath.Join(b.baseFolder, filepath.FromSlash(rel))
+}
+
+// isDatabase reports whether rel is the catalog database itself, which may live in the library.
+func (b *BoltAlbumCatalog) isDatabase(rel string) bool {
+	dbPath, err := filepath.Abs(b.dbPath)
+	if err != nil {
+		return false
+	}
+	filePath, err := filepath.Abs(b.fullPath(rel))
+	return err == nil && filePath == dbPath
+}
+
+func trackIDKey(trackID int64) []byte {
+	return []byte(strconv.FormatInt(trackID, 10))
+}
+
+func withPrefix(bucket *bolt.Bucket, prefix string) [][]byte {
+	var keys [][]byte
+	cursor := bucket.Cursor()
+	for key, _ := cursor.Seek([]byte(prefix)); key != nil && bytes.HasPrefix(key, []byte(prefix)); key, _ = cursor.Next() {
+		keys = append(keys, slices.Clone(key))
+	}
+	return keys
+}
+
+func getJSON(bucket *bolt.Bucket, key []byte, value any) bool {
+	data := bucket.Get(key)
+	if data == nil {
+		return false
+	}
+	if err := json.Unmarshal(data, value); err != nil {
+		log.Printf("BoltAlbumCatalog -> error decoding %s: %v\n", key, err)
+		return false
+	}
+	return true
+}
+
+func putJSON(bucket *bolt.Bucket, key []byte, value any) error {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return err
+	}
+	return bucket.Put(key, data)
+}
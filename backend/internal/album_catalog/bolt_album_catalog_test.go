@@ -0,0 +1,295 @@
+package album_catalog
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/josedelrio85/bndcmp_downloader/internal/model"
+	"github.com/josedelrio85/bndcmp_downloader/internal/saver"
+	"github.com/stretchr/testify/suite"
+	bolt "go.etcd.io/bbolt"
+)
+
+type BoltAlbumCatalogTestSuite struct {
+	suite.Suite
+	tempDir string
+	dbPath  string
+	catalog *BoltAlbumCatalog
+}
+
+func TestBoltAlbumCatalogSuite(t *testing.T) {
+	suite.Run(t, new(BoltAlbumCatalogTestSuite))
+}
+
+func (s *BoltAlbumCatalogTestSuite) SetupTest() {
+	s.tempDir = s.T().TempDir()
+	s.dbPath = filepath.Join(s.tempDir, ".catalog.db")
+	s.open()
+}
+
+func (s *BoltAlbumCatalogTestSuite) TearDownTest() {
+	s.catalog.Close()
+}
+
+func (s *BoltAlbumCatalogTestSuite) open() {
+	catalog, err := NewBoltAlbumCatalog(s.tempDir, s.dbPath)
+	s.Require().NoError(err)
+	s.catalog = catalog
+}
+
+func (s *BoltAlbumCatalogTestSuite) writeFile(rel string, content string) {
+	filePath := filepath.Join(s.tempDir, filepath.FromSlash(rel))
+	s.Require().NoError(os.MkdirAll(filepath.Dir(filePath), 0755))
+	s.Require().NoError(os.WriteFile(filePath, []byte(content), 0644))
+}
+
+func checksum(content string) string {
+	sum := sha256.Sum256([]byte(content))
+	return hex.EncodeToString(sum[:])
+}
+
+func (s *BoltAlbumCatalogTestSuite) TestGenerate_PersistsAcrossRestarts() {
+	s.writeFile("Artist/Album/01 - Track.mp3", "audio")
+
+	s.Require().NoError(s.catalog.Generate(s.tempDir))
+	s.Require().NoError(s.catalog.Close())
+	s.open()
+
+	s.True(s.catalog.Contains("Artist/Album/01 - Track.mp3"))
+	entry, found := s.catalog.Entry("Artist/Album/01 - Track.mp3")
+	s.True(found)
+	s.Equal("Artist/Album/01 - Track.mp3", entry.Path)
+	s.Equal(int64(5), entry.Size)
+	s.Empty(entry.Checksum, "a scan should not read the files")
+}
+
+func (s *BoltAlbumCatalogTestSuite) TestGenerate_OnlyListsChangedDirectories() {
+	s.writeFile("Artist/Album/01 - Track.mp3", "audio")
+	s.Require().NoError(s.catalog.Generate(s.tempDir))
+
+	albumDir := filepath.Join(s.tempDir, "Artist", "Album")
+	info, err := os.Stat(albumDir)
+	s.Require().NoError(err)
+	s.writeFile("Artist/Album/02 - Track.mp3", "audio")
+	s.Require().NoError(os.Chtimes(albumDir, info.ModTime(), info.ModTime()))
+
+	s.Require().NoError(s.catalog.Generate(s.tempDir))
+	s.False(s.catalog.Contains("Artist/Album/02 - Track.mp3"), "a folder with the same modification time should not be listed again")
+
+	later := info.ModTime().Add(time.Second)
+	s.Require().NoError(os.Chtimes(albumDir, later, later))
+
+	s.Require().NoError(s.catalog.Generate(s.tempDir))
+	s.True(s.catalog.Contains("Artist/Album/02 - Track.mp3"))
+}
+
+func (s *BoltAlbumCatalogTestSuite) TestGenerate_ForgetsRemovedFiles() {
+	s.writeFile("Artist/Album/01 - Track.mp3", "audio")
+	s.writeFile("Artist/Album/02 - Track.mp3", "audio")
+	s.writeFile("Other/Album/01 - Track.mp3", "audio")
+	s.Require().NoError(s.catalog.Generate(s.tempDir))
+
+	s.Require().NoError(os.Remove(filepath.Join(s.tempDir, "Artist", "Album", "02 - Track.mp3")))
+	s.Require().NoError(os.RemoveAll(filepath.Join(s.tempDir, "Other")))
+
+	s.Require().NoError(s.catalog.Generate(s.tempDir))
+	s.Equal([]string{"Artist/Album/01 - Track.mp3"}, s.catalog.paths())
+}
+
+func (s *BoltAlbumCatalogTestSuite) TestGenerate_UpdatesChangedFiles() {
+	s.writeFile("Artist/01 - Track.mp3", "audio")
+	s.Require().NoError(s.catalog.Generate(s.tempDir))
+
+	s.writeFile("Artist/01 - Track.mp3", "tagged audio")
+	later := time.Now().Add(time.Minute)
+	s.Require().NoError(os.Chtimes(filepath.Join(s.tempDir, "Artist"), later, later))
+
+	s.Require().NoError(s.catalog.Generate(s.tempDir))
+	entry, found := s.catalog.Entry("Artist/01 - Track.mp3")
+	s.True(found)
+	s.Equal(int64(12), entry.Size)
+	s.Empty(entry.Checksum)
+}
+
+func (s *BoltAlbumCatalogTestSuite) TestGenerate_SkipsDatabaseAndPartialDownloads() {
+	s.writeFile("Artist/01 - Track.mp3", "audio")
+	s.writeFile("Artist/02 - Track.mp3"+saver.PartialExtension, "aud")
+	s.writeFile(saver.QuarantineFolder+"/Artist/03 - Track.mp3", "html")
+	s.writeFile(".jobs.db", "jobs")
+
+	s.Require().NoError(s.catalog.Generate(s.tempDir))
+
+	s.Equal([]string{"Artist/01 - Track.mp3"}, s.catalog.paths())
+}
+
+func (s *BoltAlbumCatalogTestSuite) TestGenerate_LeadingDots() {
+	s.writeFile("Queens of the Stone Age/...Like Clockwork/01 - Keep Your Eyes Peeled.mp3", "audio")
+
+	s.Require().NoError(s.catalog.Generate(s.tempDir))
+
+	s.True(s.catalog.Contains("Queens of the Stone Age/...Like Clockwork/01 - Keep Your Eyes Peeled.mp3"))
+}
+
+func (s *BoltAlbumCatalogTestSuite) TestUpdate_StoresBandcampIDs() {
+	s.writeFile("Artist/Album/01 - Track.mp3", "audio")
+
+	s.catalog.Update("Artist/Album/01 - Track.mp3", &model.Track{TrackID: 3749823254, AlbumID: 2765388374, Checksum: checksum("audio")})
+
+	s.True(s.catalog.Contains("Artist/Album/01 - Track.mp3"))
+	entry, found := s.catalog.Entry("Artist/Album/01 - Track.mp3")
+	s.True(found)
+	s.Equal(Entry{
+		Path:       "Artist/Album/01 - Track.mp3",
+		Size:       5,
+		ModTime:    entry.ModTime,
+		Checksum:   checksum("audio"),
+		TrackID:    3749823254,
+		AlbumID:    2765388374,
+		Downloaded: 5,
+	}, entry)
+
+	s.writeFile("Artist/Album/01 - Track.mp3", "tagged audio")
+	s.Require().NoError(s.catalog.Generate(s.tempDir))
+
+	entry, _ = s.catalog.Entry("Artist/Album/01 - Track.mp3")
+	s.Empty(entry.Checksum, "the checksum of a changed file should be dropped")
+	s.Equal(int64(3749823254), entry.TrackID, "a rescan should keep the Bandcamp ids")
+}
+
+func (s *BoltAlbumCatalogTestSuite) TestGenerate_SkipsUnreadableFolders() {
+	s.writeFile("Artist/Album/01 - Track.mp3", "audio")
+	s.writeFile("Other/Album/01 - Track.mp3", "audio")
+	s.Require().NoError(s.catalog.Generate(s.tempDir))
+	// a folder listed by the previous scan that disappears while this one runs
+	s.Require().NoError(s.catalog.db.Update(func(tx *bolt.Tx) error {
+		var root directory
+		getJSON(tx.Bucket(directoriesBucket), []byte("."), &root)
+		root.Subdirs = append([]string{"Gone"}, root.Subdirs...)
+		return putJSON(tx.Bucket(directoriesBucket), []byte("."), root)
+	}))
+	s.writeFile("Other/Album/02 - Track.mp3", "audio")
+	later := time.Now().Add(time.Minute)
+	s.Require().NoError(os.Chtimes(filepath.Join(s.tempDir, "Other", "Album"), later, later))
+
+	err := s.catalog.Generate(s.tempDir)
+
+	s.NoError(err, "a subfolder that cannot be read should not fail the scan")
+	s.True(s.catalog.Contains("Other/Album/02 - Track.mp3"), "the folders after it should still be scanned")
+	s.True(s.catalog.Contains("Artist/Album/01 - Track.mp3"))
+}
+
+func (s *BoltAlbumCatalogTestSuite) TestSetChecksum() {
+	s.writeFile("Artist/Album/01 - Track.mp3", "audio")
+	s.Require().NoError(s.catalog.Generate(s.tempDir))
+
+	s.catalog.SetChecksum("Artist/Album/01 - Track.mp3", checksum("audio"))
+	s.catalog.SetChecksum("Artist/Album/02 - Missing.mp3", checksum("audio"))
+
+	entry, _ := s.catalog.Entry("Artist/Album/01 - Track.mp3")
+	s.Equal(checksum("audio"), entry.Checksum)
+	s.Require().NoError(s.catalog.Generate(s.tempDir))
+	entry, _ = s.catalog.Entry("Artist/Album/01 - Track.mp3")
+	s.Equal(checksum("audio"), entry.Checksum, "an unchanged file should keep its checksum")
+	s.False(s.catalog.Contains("Artist/Album/02 - Missing.mp3"), "only cataloged files get a checksum")
+}
+
+func (s *BoltAlbumCatalogTestSuite) TestNewBoltAlbumCatalog_Locked() {
+	_, err := NewBoltAlbumCatalog(s.tempDir, s.dbPath)
+
+	s.Error(err, "the database should only be opened by one process at a time")
+}
+
+func (s *BoltAlbumCatalogTestSuite) TestLookup() {
+	s.writeFile("Artist/Album/01 - Track.mp3", "audio")
+	s.catalog.Update("Artist/Album/01 - Track.mp3", &model.Track{TrackID: 3749823254, AlbumID: 2765388374})
+	s.Require().NoError(s.catalog.Close())
+	s.open()
+
+	path, found := s.catalog.Lookup(3749823254)
+	s.True(found)
+	s.Equal("Artist/Album/01 - Track.mp3", path)
+
+	_, found = s.catalog.Lookup(1090418036)
+	s.False(found)
+}
+
+func (s *BoltAlbumCatalogTestSuite) TestLookup_ForgetsRemovedTracks() {
+	s.writeFile("Artist/Album/01 - Track.mp3", "audio")
+	s.Require().NoError(s.catalog.Generate(s.tempDir))
+	s.catalog.Update("Artist/Album/01 - Track.mp3", &model.Track{TrackID: 3749823254})
+
+	s.Require().NoError(os.RemoveAll(filepath.Join(s.tempDir, "Artist", "Album")))
+	s.Require().NoError(s.catalog.Generate(s.tempDir))
+
+	_, found := s.catalog.Lookup(3749823254)
+	s.False(found)
+}
+
+func (s *BoltAlbumCatalogTestSuite) TestLookup_PathReused() {
+	s.writeFile("Artist/Album/01 - Track.mp3", "audio")
+	s.catalog.Update("Artist/Album/01 - Track.mp3", &model.Track{TrackID: 3749823254})
+	s.catalog.Update("Artist/Album/01 - Track.mp3", &model.Track{TrackID: 1090418036})
+
+	_, found := s.catalog.Lookup(3749823254)
+	s.False(found, "the file now holds another track")
+	path, found := s.catalog.Lookup(1090418036)
+	s.True(found)
+	s.Equal("Artist/Album/01 - Track.mp3", path)
+}
+
+func (s *BoltAlbumCatalogTestSuite) TestRemove() {
+	s.writeFile("Artist/Album/01 - Track.mp3", "audio")
+	s.catalog.Update("Artist/Album/01 - Track.mp3", &model.Track{TrackID: 3749823254})
+	s.catalog.Update("Artist/Album/01 - Track (1).mp3", &model.Track{TrackID: 1090418036})
+
+	s.catalog.Remove("artist/album/01 - track.mp3")
+
+	s.False(s.catalog.Contains("Artist/Album/01 - Track.mp3"))
+	_, found := s.catalog.Lookup(3749823254)
+	s.False(found)
+	_, found = s.catalog.Lookup(1090418036)
+	s.True(found)
+}
+
+func (s *BoltAlbumCatalogTestSuite) TestRemove_TrackSavedAgain() {
+	s.writeFile("Artist/Album/01 - Track.mp3", "audio")
+	s.writeFile("Artist/Album/01 - Renamed.mp3", "audio")
+	s.catalog.Update("Artist/Album/01 - Track.mp3", &model.Track{TrackID: 3749823254})
+	s.catalog.Update("Artist/Album/01 - Renamed.mp3", &model.Track{TrackID: 3749823254})
+
+	s.catalog.Remove("Artist/Album/01 - Track.mp3")
+
+	path, found := s.catalog.Lookup(3749823254)
+	s.True(found, "the track id now belongs to the other file")
+	s.Equal("Artist/Album/01 - Renamed.mp3", path)
+}
+
+func (s *BoltAlbumCatalogTestSuite) TestUpdate_KeepsDownloadedSize() {
+	s.writeFile("Artist/Album/01 - Track.mp3", "audio")
+	s.catalog.Update("Artist/Album/01 - Track.mp3", &model.Track{TrackID: 3749823254})
+
+	s.writeFile("Artist/Album/01 - Track.mp3", "aud")
+	later := time.Now().Add(time.Minute)
+	s.Require().NoError(os.Chtimes(filepath.Join(s.tempDir, "Artist", "Album"), later, later))
+	s.Require().NoError(s.catalog.Generate(s.tempDir))
+
+	entry, found := s.catalog.Entry("Artist/Album/01 - Track.mp3")
+	s.True(found)
+	s.Equal(int64(3), entry.Size)
+	s.Equal(int64(5), entry.Downloaded)
+}
+
+func (s *BoltAlbumCatalogTestSuite) TestUpdate_UsesSaverChecksum() {
+	s.writeFile("Artist/Album/01 - Track.mp3", "audio")
+
+	s.catalog.Update("Artist/Album/01 - Track.mp3", &model.Track{TrackID: 3749823254, Checksum: checksum("hashed while saving")})
+
+	entry, found := s.catalog.Entry("Artist/Album/01 - Track.mp3")
+	s.True(found)
+	s.Equal(checksum("hashed while saving"), entry.Checksum, "the file should not be read again")
+	s.Equal(int64(5), entry.Size)
+}
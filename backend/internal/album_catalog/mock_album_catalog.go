@@ -8,6 +8,7 @@ import (
 	reflect "reflect"
 
 	gomock "github.com/golang/mock/gomock"
+	model "github.com/josedelrio85/bndcmp_downloader/internal/model"
 )
 
 // MockAlbumCatalog is a mock of AlbumCatalog interface.
@@ -33,6 +34,20 @@ func (m *MockAlbumCatalog) EXPECT() *MockAlbumCatalogMockRecorder {
 	return m.recorder
 }
 
+// Contains mocks base method.
+func (m *MockAlbumCatalog) Contains(path string) bool {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Contains", path)
+	ret0, _ := ret[0].(bool)
+	return ret0
+}
+
+// Contains indicates an expected call of Contains.
+func (mr *MockAlbumCatalogMockRecorder) Contains(path interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Contains", reflect.TypeOf((*MockAlbumCatalog)(nil).Contains), path)
+}
+
 // Generate mocks base method.
 func (m *MockAlbumCatalog) Generate(folder string) error {
 	m.ctrl.T.Helper()
@@ -47,28 +62,97 @@ func (mr *MockAlbumCatalogMockRecorder) Generate(folder interface{}) *gomock.Cal
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Generate", reflect.TypeOf((*MockAlbumCatalog)(nil).Generate), folder)
 }
 
-// GetMapDir mocks base method.
-func (m *MockAlbumCatalog) GetMapDir() *map[string]bool {
+// ListAlbums mocks base method.
+func (m *MockAlbumCatalog) ListAlbums(artist string) []Album {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ListAlbums", artist)
+	ret0, _ := ret[0].([]Album)
+	return ret0
+}
+
+// ListAlbums indicates an expected call of ListAlbums.
+func (mr *MockAlbumCatalogMockRecorder) ListAlbums(artist interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListAlbums", reflect.TypeOf((*MockAlbumCatalog)(nil).ListAlbums), artist)
+}
+
+// ListArtists mocks base method.
+func (m *MockAlbumCatalog) ListArtists() []string {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ListArtists")
+	ret0, _ := ret[0].([]string)
+	return ret0
+}
+
+// ListArtists indicates an expected call of ListArtists.
+func (mr *MockAlbumCatalogMockRecorder) ListArtists() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListArtists", reflect.TypeOf((*MockAlbumCatalog)(nil).ListArtists))
+}
+
+// ListTracks mocks base method.
+func (m *MockAlbumCatalog) ListTracks(album Album) []string {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ListTracks", album)
+	ret0, _ := ret[0].([]string)
+	return ret0
+}
+
+// ListTracks indicates an expected call of ListTracks.
+func (mr *MockAlbumCatalogMockRecorder) ListTracks(album interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListTracks", reflect.TypeOf((*MockAlbumCatalog)(nil).ListTracks), album)
+}
+
+// Lookup mocks base method.
+func (m *MockAlbumCatalog) Lookup(trackID int64) (string, bool) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Lookup", trackID)
+	ret0, _ := ret[0].(string)
+	ret1, _ := ret[1].(bool)
+	return ret0, ret1
+}
+
+// Lookup indicates an expected call of Lookup.
+func (mr *MockAlbumCatalogMockRecorder) Lookup(trackID interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Lookup", reflect.TypeOf((*MockAlbumCatalog)(nil).Lookup), trackID)
+}
+
+// Remove mocks base method.
+func (m *MockAlbumCatalog) Remove(path string) {
+	m.ctrl.T.Helper()
+	m.ctrl.Call(m, "Remove", path)
+}
+
+// Remove indicates an expected call of Remove.
+func (mr *MockAlbumCatalogMockRecorder) Remove(path interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Remove", reflect.TypeOf((*MockAlbumCatalog)(nil).Remove), path)
+}
+
+// Stats mocks base method.
+func (m *MockAlbumCatalog) Stats() Stats {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "GetMapDir")
-	ret0, _ := ret[0].(*map[string]bool)
+	ret := m.ctrl.Call(m, "Stats")
+	ret0, _ := ret[0].(Stats)
 	return ret0
 }
 
-// GetMapDir indicates an expected call of GetMapDir.
-func (mr *MockAlbumCatalogMockRecorder) GetMapDir() *gomock.Call {
+// Stats indicates an expected call of Stats.
+func (mr *MockAlbumCatalogMockRecorder) Stats() *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetMapDir", reflect.TypeOf((*MockAlbumCatalog)(nil).GetMapDir))
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Stats", reflect.TypeOf((*MockAlbumCatalog)(nil).Stats))
 }
 
 // Update mocks base method.
-func (m *MockAlbumCatalog) Update(path string) {
+func (m *MockAlbumCatalog) Update(path string, track *model.Track) {
 	m.ctrl.T.Helper()
-	m.ctrl.Call(m, "Update", path)
+	m.ctrl.Call(m, "Update", path, track)
 }
 
 // Update indicates an expected call of Update.
-func (mr *MockAlbumCatalogMockRecorder) Update(path interface{}) *gomock.Call {
+func (mr *MockAlbumCatalogMockRecorder) Update(path, track interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Update", reflect.TypeOf((*MockAlbumCatalog)(nil).Update), path)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Update", reflect.TypeOf((*MockAlbumCatalog)(nil).Update), path, track)
 }
@@ -0,0 +1,113 @@
+package album_catalog
+
+import (
+	"path"
+	"slices"
+	"strings"
+
+	"github.com/josedelrio85/bndcmp_downloader/internal/sanitize"
+)
+
+const trackExtension = ".mp3"
+
+// Album is a release folder of the library. Title is the path between the artist
+// folder and the tracks, e.g. "2012 - 12 Bar Bruise" with the navidrome layout.
+type Album struct {
+	Artist string
+	Title  string
+}
+
+type Stats struct {
+	Artists int
+	Albums  int
+	Tracks  int
+}
+
+// listArtists returns the top level folders holding tracks. Names only differing by
+// case or normalization are listed once.
+func listArtists(paths []string) []string {
+	var artists []string
+	seen := make(map[string]bool)
+	for _, track := range sortedTracks(paths) {
+		if album, ok := albumOf(track); ok && !seen[sanitize.Key(album.Artist)] {
+			seen[sanitize.Key(album.Artist)] = true
+			artists = append(artists, album.Artist)
+		}
+	}
+	return artists
+}
+
+// listAlbums returns the albums of artist, matched like catalog paths.
+func listAlbums(paths []string, artist string) []Album {
+	var albums []Album
+	seen := make(map[string]bool)
+	artistKey := sanitize.Key(artist)
+	for _, track := range sortedTracks(paths) {
+		album, ok := albumOf(track)
+		if !ok || album.Title == "" || sanitize.Key(album.Artist) != artistKey {
+			continue
+		}
+		if key := sanitize.Key(album.Title); !seen[key] {
+			seen[key] = true
+			albums = append(albums, album)
+		}
+	}
+	return albums
+}
+
+// listTracks returns the paths of the tracks of album, relative to the library folder.
+func listTracks(paths []string, album Album) []string {
+	var tracks []string
+	for _, track := range sortedTracks(paths) {
+		if trackAlbum, ok := albumOf(track); ok && sameAlbum(trackAlbum, album) {
+			tracks = append(tracks, track)
+		}
+	}
+	return tracks
+}
+
+func stats(paths []string) Stats {
+	var result Stats
+	artists := make(map[string]bool)
+	albums := make(map[Album]bool)
+	for _, track := range sortedTracks(paths) {
+		result.Tracks++
+		album, ok := albumOf(track)
+		if !ok {
+			continue
+		}
+		artists[sanitize.Key(album.Artist)] = true
+		if album.Title != "" {
+			albums[Album{Artist: sanitize.Key(album.Artist), Title: sanitize.Key(album.Title)}] = true
+		}
+	}
+	result.Artists = len(artists)
+	result.Albums = len(albums)
+	return result
+}
+
+// sortedTracks keeps the audio files of paths, the library also holds cover art.
+func sortedTracks(paths []string) []string {
+	var tracks []string
+	for _, p := range paths {
+		if strings.EqualFold(path.Ext(p), trackExtension) {
+			tracks = append(tracks, p)
+		}
+	}
+	slices.Sort(tracks)
+	return tracks
+}
+
+// albumOf splits a track path into its artist and album folders. Tracks saved directly in
+// the artist folder have an empty album title, tracks at the root have no album at all.
+func albumOf(track string) (Album, bool) {
+	folders := strings.Split(path.Dir(track), "/")
+	if folders[0] == "." {
+		return Album{}, false
+	}
+	return Album{Artist: folders[0], Title: strings.Join(folders[1:], "/")}, true
+}
+
+func sameAlbum(a Album, b Album) bool {
+	return sanitize.Key(a.Artist) == sanitize.Key(b.Artist) && sanitize.Key(a.Title) == sanitize.Key(b.Title)
+}
@@ -0,0 +1,114 @@
+package album_catalog
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+
+	"github.com/josedelrio85/bndcmp_downloader/internal/model"
+	"github.com/stretchr/testify/suite"
+)
+
+type QueryTestSuite struct {
+	suite.Suite
+	paths []string
+}
+
+func TestQuerySuite(t *testing.T) {
+	suite.Run(t, new(QueryTestSuite))
+}
+
+func (s *QueryTestSuite) SetupTest() {
+	s.paths = []string{
+		"King Gizzard/12 Bar Bruise/02 - Muckraker.mp3",
+		"King Gizzard/12 Bar Bruise/01 - Elbow.mp3",
+		"King Gizzard/12 Bar Bruise/cover.jpg",
+		"king gizzard/Eyes Like the Sky/01 - Eyes Like the Sky.mp3",
+		"King Gizzard/01 - Single.mp3",
+		"Björk/Debut/01 - Human Behaviour.mp3",
+		"01 - Loose Track.mp3",
+	}
+}
+
+func (s *QueryTestSuite) Test_listArtists() {
+	s.Equal([]string{"Björk", "King Gizzard"}, listArtists(s.paths))
+}
+
+func (s *QueryTestSuite) Test_listAlbums() {
+	s.Equal([]Album{
+		{Artist: "King Gizzard", Title: "12 Bar Bruise"},
+		{Artist: "king gizzard", Title: "Eyes Like the Sky"},
+	}, listAlbums(s.paths, "KING GIZZARD"))
+	s.Empty(listAlbums(s.paths, "Unknown"))
+}
+
+func (s *QueryTestSuite) Test_listTracks() {
+	s.Equal([]string{
+		"King Gizzard/12 Bar Bruise/01 - Elbow.mp3",
+		"King Gizzard/12 Bar Bruise/02 - Muckraker.mp3",
+	}, listTracks(s.paths, Album{Artist: "king gizzard", Title: "12 bar bruise"}))
+	s.Equal([]string{"King Gizzard/01 - Single.mp3"}, listTracks(s.paths, Album{Artist: "King Gizzard"}))
+}
+
+func (s *QueryTestSuite) Test_stats() {
+	s.Equal(Stats{Artists: 2, Albums: 3, Tracks: 6}, stats(s.paths))
+	s.Equal(Stats{}, stats(nil))
+}
+
+func (s *QueryTestSuite) Test_albumOf() {
+	tests := []struct {
+		track    string
+		expected Album
+		ok       bool
+	}{
+		{"Artist/Album/01 - Track.mp3", Album{Artist: "Artist", Title: "Album"}, true},
+		{"Artist/2012/Album/01 - Track.mp3", Album{Artist: "Artist", Title: "2012/Album"}, true},
+		{"Artist/01 - Track.mp3", Album{Artist: "Artist"}, true},
+		{"01 - Track.mp3", Album{}, false},
+	}
+
+	for _, tt := range tests {
+		s.Run(tt.track, func() {
+			album, ok := albumOf(tt.track)
+			s.Equal(tt.ok, ok)
+			s.Equal(tt.expected, album)
+		})
+	}
+}
+
+// Test_ConcurrentAccess is meant for go test -race: scrappers query the catalog while
+// other downloads record their tracks.
+func (s *QueryTestSuite) Test_ConcurrentAccess() {
+	boltCatalog, err := NewBoltAlbumCatalog(s.T().TempDir(), s.T().TempDir()+"/catalog.db")
+	s.Require().NoError(err)
+	defer boltCatalog.Close()
+
+	catalogs := map[string]AlbumCatalog{
+		"InMemory": NewInMemoryAlbumCatalog(s.T().TempDir()),
+		"Bolt":     boltCatalog,
+	}
+	for name, catalog := range catalogs {
+		s.Run(name, func() {
+			var wg sync.WaitGroup
+			for i := 0; i < 8; i++ {
+				wg.Add(1)
+				go func(i int) {
+					defer wg.Done()
+					for j := 0; j < 20; j++ {
+						path := fmt.Sprintf("Artist %d/Album/%02d - Track.mp3", i, j)
+						catalog.Update(path, &model.Track{TrackID: int64(i*100 + j + 1)})
+						s.True(catalog.Contains(path))
+						catalog.Lookup(int64(j + 1))
+						catalog.ListArtists()
+						catalog.ListTracks(Album{Artist: fmt.Sprintf("Artist %d", i), Title: "Album"})
+						catalog.Stats()
+					}
+				}(i)
+			}
+			wg.Wait()
+
+			s.Equal(Stats{Artists: 8, Albums: 8, Tracks: 160}, catalog.Stats())
+			s.Len(catalog.ListAlbums("Artist 3"), 1)
+		})
+	}
+}
@@ -0,0 +1,190 @@
+package album_catalog
+
+import (
+	"context"
+	"errors"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/josedelrio85/bndcmp_downloader/internal/saver"
+)
+
+type WatchConfig struct {
+	Enabled bool
+	// Debounce is how long the library has to stay quiet before its changes are applied,
+	// so moving a whole discography is handled as a single batch.
+	Debounce time.Duration
+}
+
+func DefaultWatchConfig() WatchConfig {
+	return WatchConfig{
+		Enabled:  false,
+		Debounce: 2 * time.Second,
+	}
+}
+
+// Watcher keeps a catalog in sync with the changes made to the library by other
+// programs, e.g. albums deleted from Plex or copied through Samba.
+type Watcher struct {
+	catalog   AlbumCatalog
+	folder    string
+	debounce  time.Duration
+	fsWatcher *fsnotify.Watcher
+}
+
+func NewWatcher(catalog AlbumCatalog, folder string, config WatchConfig) (*Watcher, error) {
+	fsWatcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return nil, err
+	}
+
+	watcher := &Watcher{
+		catalog:   catalog,
+		folder:    filepath.Clean(folder),
+		debounce:  config.Debounce,
+		fsWatcher: fsWatcher,
+	}
+	if err := watcher.watchTree(watcher.folder); err != nil {
+		fsWatcher.Close()
+		return nil, err
+	}
+	return watcher, nil
+}
+
+// Run applies the library changes to the catalog until ctx is done.
+func (w *Watcher) Run(ctx context.Context) error {
+	defer w.fsWatcher.Close()
+
+	changed := make(map[string]bool)
+	timer := time.NewTimer(w.debounce)
+	timer.Stop()
+	defer timer.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case event, ok := <-w.fsWatcher.Events:
+			if !ok {
+				return nil
+			}
+			if ignored(event) {
+				continue
+			}
+			if event.Has(fsnotify.Create) {
+				// folders moved into the library need watches before their content changes
+				if info, err := os.Stat(event.Name); err == nil && info.IsDir() {
+					w.watchTree(event.Name)
+				}
+			}
+			changed[event.Name] = true
+			timer.Reset(w.debounce)
+		case err, ok := <-w.fsWatcher.Errors:
+			if !ok {
+				return nil
+			}
+			log.Printf("Watcher -> Run -> error watching %s: %v\n", w.folder, err)
+			if errors.Is(err, fsnotify.ErrEventOverflow) {
+				// events were dropped, only a full rescan tells what changed
+				changed[w.folder] = true
+				timer.Reset(w.debounce)
+			}
+		case <-timer.C:
+			w.apply(changed)
+			changed = make(map[string]bool)
+		}
+	}
+}
+
+// apply rescans the folders holding the changed paths. Generate forgets whatever
+// is gone from them, so deletions and moves out of the library are covered too.
+// Their watches are added again as fsnotify drops the watch of a moved folder once
+// it handles the move, which may happen after the folder was watched at its new path.
+func (w *Watcher) apply(changed map[string]bool) {
+	folders := make(map[string]bool)
+	for name := range changed {
+		if name != w.folder {
+			name = filepath.Dir(name)
+		}
+		folders[w.existingAncestor(name)] = true
+	}
+
+	for _, folder := range outermost(folders) {
+		log.Printf("Watcher -> updating album catalog for %s\n", folder)
+		if err := w.watchTree(folder); err != nil {
+			log.Printf("Watcher -> apply -> error watching %s: %v\n", folder, err)
+		}
+		if err := w.catalog.Generate(folder); err != nil {
+			log.Printf("Watcher -> apply -> error updating album catalog for %s: %v\n", folder, err)
+		}
+	}
+}
+
+func (w *Watcher) watchTree(root string) error {
+	return filepath.WalkDir(root, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			log.Printf("Watcher -> watchTree -> error reading %s: %v\n", path, err)
+			return nil
+		}
+		if !entry.IsDir() {
+			return nil
+		}
+		if path != root && saver.Internal(entry.Name()) {
+			return filepath.SkipDir
+		}
+		if err := w.fsWatcher.Add(path); err != nil {
+			if path == root {
+				return err
+			}
+			log.Printf("Watcher -> watchTree -> error watching %s: %v\n", path, err)
+		}
+		return nil
+	})
+}
+
+// existingAncestor returns the closest folder of the library still holding folder, a
+// change may be reported for folders that were removed or moved away since.
+func (w *Watcher) existingAncestor(folder string) string {
+	for strings.HasPrefix(folder, w.folder+string(os.PathSeparator)) {
+		if _, err := os.Stat(folder); err == nil {
+			return folder
+		}
+		folder = filepath.Dir(folder)
+	}
+	return w.folder
+}
+
+// outermost drops the folders below another one of folders, Generate already covers them.
+func outermost(folders map[string]bool) []string {
+	var result []string
+	for folder := range folders {
+		if !hasAncestor(folders, folder) {
+			result = append(result, folder)
+		}
+	}
+	slices.Sort(result)
+	return result
+}
+
+func hasAncestor(folders map[string]bool, folder string) bool {
+	for parent := filepath.Dir(folder); parent != folder; folder, parent = parent, filepath.Dir(parent) {
+		if folders[parent] {
+			return true
+		}
+	}
+	return false
+}
+
+// ignored filters the writes of the downloader itself: partial downloads, tagging
+// temporary files and the catalog database. Content changes do not affect the catalog.
+func ignored(event fsnotify.Event) bool {
+	name := filepath.Base(event.Name)
+	return !event.Has(fsnotify.Create) && !event.Has(fsnotify.Remove) && !event.Has(fsnotify.Rename) ||
+		saver.Internal(name) || saver.IsPartial(name)
+}
@@ -0,0 +1,187 @@
+package album_catalog
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/josedelrio85/bndcmp_downloader/internal/saver"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/stretchr/testify/suite"
+)
+
+type WatcherTestSuite struct {
+	suite.Suite
+	tempDir string
+	catalog *countingCatalog
+	cancel  context.CancelFunc
+	done    chan struct{}
+}
+
+func TestWatcherSuite(t *testing.T) {
+	suite.Run(t, new(WatcherTestSuite))
+}
+
+// countingCatalog records the folders the watcher asks to rescan.
+type countingCatalog struct {
+	*InMemoryAlbumCatalog
+	mutex     sync.Mutex
+	generated []string
+}
+
+func (c *countingCatalog) Generate(folder string) error {
+	c.mutex.Lock()
+	c.generated = append(c.generated, folder)
+	c.mutex.Unlock()
+	return c.InMemoryAlbumCatalog.Generate(folder)
+}
+
+func (c *countingCatalog) Generated() []string {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return append([]string(nil), c.generated...)
+}
+
+func (s *WatcherTestSuite) SetupTest() {
+	s.tempDir = s.T().TempDir()
+	s.writeFile("Artist/Album/01 - Track.mp3")
+	s.catalog = &countingCatalog{InMemoryAlbumCatalog: NewInMemoryAlbumCatalog(s.tempDir)}
+	s.Require().NoError(s.catalog.InMemoryAlbumCatalog.Generate(s.tempDir))
+
+	watcher, err := NewWatcher(s.catalog, s.tempDir, WatchConfig{Enabled: true, Debounce: 50 * time.Millisecond})
+	s.Require().NoError(err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	s.cancel = cancel
+	s.done = make(chan struct{})
+	go func() {
+		defer close(s.done)
+		watcher.Run(ctx)
+	}()
+}
+
+func (s *WatcherTestSuite) TearDownTest() {
+	s.cancel()
+	<-s.done
+}
+
+func (s *WatcherTestSuite) writeFile(rel string) {
+	filePath := filepath.Join(s.tempDir, filepath.FromSlash(rel))
+	s.Require().NoError(os.MkdirAll(filepath.Dir(filePath), 0755))
+	s.Require().NoError(os.WriteFile(filePath, []byte("audio"), 0644))
+}
+
+func (s *WatcherTestSuite) eventually(condition func() bool, message string) {
+	s.Eventually(condition, 5*time.Second, 10*time.Millisecond, message)
+}
+
+func (s *WatcherTestSuite) TestRun_AddedAlbum() {
+	s.writeFile("Other Artist/Album/01 - Track.mp3")
+
+	s.eventually(func() bool {
+		return s.catalog.Contains("Other Artist/Album/01 - Track.mp3")
+	}, "albums added by hand should be cataloged")
+}
+
+func (s *WatcherTestSuite) TestRun_AddedTrackInWatchedFolder() {
+	s.writeFile("Artist/Album/02 - Track.mp3")
+
+	s.eventually(func() bool {
+		return s.catalog.Contains("Artist/Album/02 - Track.mp3")
+	}, "tracks added to an existing album should be cataloged")
+}
+
+func (s *WatcherTestSuite) TestRun_DeletedAlbum() {
+	s.Require().NoError(os.RemoveAll(filepath.Join(s.tempDir, "Artist", "Album")))
+
+	s.eventually(func() bool {
+		return !s.catalog.Contains("Artist/Album/01 - Track.mp3")
+	}, "deleted albums should be forgotten")
+}
+
+func (s *WatcherTestSuite) TestRun_MovedAlbum() {
+	s.Require().NoError(os.MkdirAll(filepath.Join(s.tempDir, "Renamed Artist"), 0755))
+	s.Require().NoError(os.Rename(filepath.Join(s.tempDir, "Artist", "Album"), filepath.Join(s.tempDir, "Renamed Artist", "Album")))
+
+	s.eventually(func() bool {
+		return !s.catalog.Contains("Artist/Album/01 - Track.mp3") && s.catalog.Contains("Renamed Artist/Album/01 - Track.mp3")
+	}, "moved albums should be cataloged at their new path")
+
+	// the moved folder is watched at its new place
+	s.writeFile("Renamed Artist/Album/02 - Track.mp3")
+	s.eventually(func() bool {
+		return s.catalog.Contains("Renamed Artist/Album/02 - Track.mp3")
+	}, "tracks added to a moved album should be cataloged")
+}
+
+func (s *WatcherTestSuite) TestRun_DebouncesBulkMoves() {
+	staging := s.T().TempDir()
+	for i := 0; i < 20; i++ {
+		filePath := filepath.Join(staging, fmt.Sprintf("Album %02d", i), "01 - Track.mp3")
+		s.Require().NoError(os.MkdirAll(filepath.Dir(filePath), 0755))
+		s.Require().NoError(os.WriteFile(filePath, []byte("audio"), 0644))
+		s.Require().NoError(os.Rename(filepath.Dir(filePath), filepath.Join(s.tempDir, "Artist", fmt.Sprintf("Album %02d", i))))
+	}
+
+	s.eventually(func() bool {
+		return len(s.catalog.ListAlbums("Artist")) == 21
+	}, "all moved albums should be cataloged")
+	s.Equal([]string{filepath.Join(s.tempDir, "Artist")}, s.catalog.Generated(), "a bulk move should be rescanned once")
+}
+
+func (s *WatcherTestSuite) TestRun_IgnoresDownloaderFiles() {
+	s.writeFile("Artist/Album/02 - Track.mp3" + saver.PartialExtension)
+	s.writeFile("Artist/Album/.tagging-123" + saver.PartialExtension)
+	s.writeFile(".catalog.db")
+
+	time.Sleep(200 * time.Millisecond)
+	s.Empty(s.catalog.Generated())
+}
+
+func (s *WatcherTestSuite) Test_outermost() {
+	folders := map[string]bool{
+		"/library/Artist":              true,
+		"/library/Artist/Album":        true,
+		"/library/Other/Album":         true,
+		"/library/Other/Album/Disc 1":  true,
+		"/library/Another Artist/Live": true,
+	}
+
+	s.Equal([]string{"/library/Another Artist/Live", "/library/Artist", "/library/Other/Album"}, outermost(folders))
+}
+
+func TestWatcher_BoltCatalog(t *testing.T) {
+	tempDir := t.TempDir()
+	filePath := filepath.Join(tempDir, "Artist", "Album", "01 - Track.mp3")
+	require.NoError(t, os.MkdirAll(filepath.Dir(filePath), 0755))
+	require.NoError(t, os.WriteFile(filePath, []byte("audio"), 0644))
+
+	catalog, err := NewBoltAlbumCatalog(tempDir, filepath.Join(tempDir, ".catalog.db"))
+	require.NoError(t, err)
+	defer catalog.Close()
+	require.NoError(t, catalog.Generate(tempDir))
+
+	watcher, err := NewWatcher(catalog, tempDir, WatchConfig{Enabled: true, Debounce: 50 * time.Millisecond})
+	require.NoError(t, err)
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		watcher.Run(ctx)
+	}()
+	defer func() {
+		cancel()
+		<-done
+	}()
+
+	require.NoError(t, os.RemoveAll(filepath.Join(tempDir, "Artist", "Album")))
+
+	assert.Eventually(t, func() bool {
+		return !catalog.Contains("Artist/Album/01 - Track.mp3")
+	}, 5*time.Second, 10*time.Millisecond, "deleted albums should be forgotten by the persistent catalog")
+}
@@ -0,0 +1,12 @@
+package bandcamp
+
+import "fmt"
+
+// DefaultArtworkSize is Bandcamp's 1200x1200 JPEG. Other common formats are
+// 0 (original upload), 16 (700x700), 2 (350x350) and 7 (150x150).
+const DefaultArtworkSize = 10
+
+// ArtworkURL returns the image URL of an art ID at the given Bandcamp image format.
+func ArtworkURL(artID int64, size int) string {
+	return fmt.Sprintf("https://f4.bcbits.com/img/a%010d_%d.jpg", artID, size)
+}
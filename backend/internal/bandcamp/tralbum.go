@@ -1,7 +1,9 @@
 package bandcamp
 
 import (
+	"net/url"
 	"strings"
+	"time"
 
 	"github.com/josedelrio85/bndcmp_downloader/internal/model"
 	"golang.org/x/text/cases"
@@ -41,6 +43,15 @@ type TrAlbum struct {
 	AlbumURL                   string      `json:"album_url"`
 	AlbumUpsellURL             string      `json:"album_upsell_url"`
 	URL                        string      `json:"url"`
+	// AlbumTitle is the title of the release a track page belongs to, taken from the page data-embed.
+	AlbumTitle string `json:"-"`
+}
+
+// Embed is the data-embed attribute published next to data-tralbum.
+type Embed struct {
+	AlbumTitle string `json:"album_title"`
+	Artist     string `json:"artist"`
+	Linkback   string `json:"linkback"`
 }
 
 type Current struct {
@@ -134,29 +145,88 @@ func (t *TrAlbum) ToTrack() *model.Track {
 		return nil
 	}
 
-	sanitizedTitle := strings.Replace(t.Current.Title, "/", "-", -1)
-
 	track := model.Track{
-		Title:       sanitizedTitle,
+		Title:       t.Current.Title,
 		TrackNumber: t.Current.TrackNumber,
 		Artist:      t.Artist,
 		Album:       t.getAlbumName(),
+		ReleaseYear: t.getReleaseYear(),
+		ISRC:        t.Current.Isrc,
+		ArtID:       t.ArtID,
+		TrackID:     t.ID,
+		AlbumID:     t.Current.AlbumID,
 		URL:         t.URL,
 	}
+	if track.Album != nil {
+		track.AlbumArtist = t.Artist
+	}
 
 	if len(t.Trackinfo) > 0 {
+		if t.Trackinfo[0].TrackID != 0 {
+			track.TrackID = t.Trackinfo[0].TrackID
+		}
 		track.DownloadURL = t.Trackinfo[0].File.Mp3128
+		track.Duration = t.Trackinfo[0].Duration
 	}
 
 	return &track
 }
 
+// ToTracks converts the payload of an album page into one track per trackinfo entry.
+// Tracks that are not streamable have an empty DownloadURL.
+func (t *TrAlbum) ToTracks() []*model.Track {
+	if t == nil {
+		return nil
+	}
+
+	tracks := make([]*model.Track, 0, len(t.Trackinfo))
+	for _, info := range t.Trackinfo {
+		tracks = append(tracks, &model.Track{
+			Title:       info.Title,
+			TrackNumber: info.TrackNum,
+			Artist:      t.Artist,
+			Album:       t.getAlbumName(),
+			AlbumArtist: t.Artist,
+			TrackTotal:  int64(len(t.Trackinfo)),
+			ReleaseYear: t.getReleaseYear(),
+			ArtID:       t.ArtID,
+			TrackID:     info.TrackID,
+			AlbumID:     t.ID,
+			Duration:    info.Duration,
+			URL:         t.resolveURL(info.TitleLink),
+			DownloadURL: info.File.Mp3128,
+		})
+	}
+	return tracks
+}
+
+// getAlbumName returns the release title: the album page title, the album a track page
+// belongs to or, as a last resort, the title-cased album slug. Standalone tracks have no album.
 func (t *TrAlbum) getAlbumName() *string {
 	if t == nil {
 		return nil
 	}
 
-	album := strings.Replace(t.AlbumURL, "/album/", "", -1)
+	if t.ItemType == "album" && t.Current.Title != "" {
+		album := t.Current.Title
+		return &album
+	}
+	if t.AlbumTitle != "" {
+		album := t.AlbumTitle
+		return &album
+	}
+
+	albumPath := t.AlbumURL
+	if albumPath == "" && t.ItemType == "album" {
+		if parsed, err := url.Parse(t.URL); err == nil {
+			albumPath = parsed.Path
+		}
+	}
+	if albumPath == "" {
+		return nil
+	}
+
+	album := strings.Replace(albumPath, "/album/", "", -1)
 	album = strings.Replace(album, "-", " ", -1)
 	words := strings.Fields(album)
 	caser := cases.Title(language.Und)
@@ -167,6 +237,33 @@ func (t *TrAlbum) getAlbumName() *string {
 	return &album
 }
 
+// getReleaseYear reads the year of the album release date, falling back to the publish date
+// of standalone tracks. Bandcamp dates look like "07 Sep 2012 00:00:00 GMT".
+func (t *TrAlbum) getReleaseYear() int {
+	for _, date := range []string{t.AlbumReleaseDate, t.Current.PublishDate} {
+		if parsed, err := time.Parse("02 Jan 2006 15:04:05 MST", date); err == nil {
+			return parsed.Year()
+		}
+	}
+	return 0
+}
+
+// resolveURL turns a relative link like /track/elbow into an absolute URL on the artist host
+func (t *TrAlbum) resolveURL(link string) string {
+	if link == "" {
+		return ""
+	}
+	base, err := url.Parse(t.URL)
+	if err != nil {
+		return link
+	}
+	reference, err := url.Parse(link)
+	if err != nil {
+		return link
+	}
+	return base.ResolveReference(reference).String()
+}
+
 // Album is the struct that represents an album as available in music page, ol tag
 type Album struct {
 	ArtID   int64  `json:"art_id"`
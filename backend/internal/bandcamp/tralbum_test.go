@@ -29,15 +29,17 @@ func (s *TestTrAlbumSuite) TestToTrack() {
 				URL:      "https://example.com/track",
 				AlbumURL: "/album/test-album",
 				Trackinfo: []TrackInfo{
-					{File: File{Mp3128: "https://example.com/download"}},
+					{Duration: 201.5, File: File{Mp3128: "https://example.com/download"}},
 				},
 			},
 			expected: &model.Track{
 				Title:       "Test Track",
 				Artist:      "Test Artist",
 				Album:       toPointer("Test Album"),
+				AlbumArtist: "Test Artist",
 				URL:         "https://example.com/track",
 				DownloadURL: "https://example.com/download",
+				Duration:    201.5,
 			},
 		},
 		{
@@ -45,6 +47,51 @@ func (s *TestTrAlbumSuite) TestToTrack() {
 			trAlbum:  nil,
 			expected: nil,
 		},
+		{
+			name: "Track with release metadata",
+			trAlbum: &TrAlbum{
+				Current:          Current{Title: "Elbow", TrackNumber: 1, Isrc: "AUW631100218", PublishDate: "29 Aug 2012 11:21:32 GMT", AlbumID: 2765388374},
+				Artist:           "King Gizzard & The Lizard Wizard",
+				ItemType:         "track",
+				ID:               3749823254,
+				URL:              "https://kinggizzard.bandcamp.com/track/elbow",
+				AlbumURL:         "/album/12-bar-bruise",
+				AlbumTitle:       "12 Bar Bruise",
+				AlbumReleaseDate: "07 Sep 2011 00:00:00 GMT",
+			},
+			expected: &model.Track{
+				Title:       "Elbow",
+				TrackNumber: 1,
+				Artist:      "King Gizzard & The Lizard Wizard",
+				Album:       toPointer("12 Bar Bruise"),
+				AlbumArtist: "King Gizzard & The Lizard Wizard",
+				ReleaseYear: 2011,
+				ISRC:        "AUW631100218",
+				TrackID:     3749823254,
+				AlbumID:     2765388374,
+				URL:         "https://kinggizzard.bandcamp.com/track/elbow",
+			},
+		},
+		{
+			name: "Standalone track",
+			trAlbum: &TrAlbum{
+				Current:  Current{Title: "Single", TrackNumber: 1, PublishDate: "01 Feb 2020 10:00:00 GMT"},
+				Artist:   "Test Artist",
+				ItemType: "track",
+				URL:      "https://example.com/track/single",
+				Trackinfo: []TrackInfo{
+					{File: File{Mp3128: "https://example.com/download"}},
+				},
+			},
+			expected: &model.Track{
+				Title:       "Single",
+				TrackNumber: 1,
+				Artist:      "Test Artist",
+				ReleaseYear: 2020,
+				URL:         "https://example.com/track/single",
+				DownloadURL: "https://example.com/download",
+			},
+		},
 		{
 			name: "Track with slash",
 			trAlbum: &TrAlbum{
@@ -57,9 +104,10 @@ func (s *TestTrAlbumSuite) TestToTrack() {
 				},
 			},
 			expected: &model.Track{
-				Title:       "Test - Track",
+				Title:       "Test / Track",
 				Artist:      "Test Artist",
 				Album:       toPointer("Test Album"),
+				AlbumArtist: "Test Artist",
 				URL:         "https://example.com/track",
 				DownloadURL: "https://example.com/download",
 			},
@@ -92,7 +140,43 @@ func (s *TestTrAlbumSuite) Test_getAlbumName() {
 			trAlbum: &TrAlbum{
 				AlbumURL: "",
 			},
-			expected: toPointer(""),
+			expected: nil,
+		},
+		{
+			name: "Album page title",
+			trAlbum: &TrAlbum{
+				Current:  Current{Title: "OK Computer"},
+				ItemType: "album",
+				URL:      "https://radiohead.bandcamp.com/album/ok-computer",
+			},
+			expected: toPointer("OK Computer"),
+		},
+		{
+			name: "Track page album title",
+			trAlbum: &TrAlbum{
+				Current:    Current{Title: "Airbag"},
+				ItemType:   "track",
+				AlbumURL:   "/album/ok-computer",
+				AlbumTitle: "OK Computer: OKNOTOK 1997/2017",
+			},
+			expected: toPointer("OK Computer: OKNOTOK 1997/2017"),
+		},
+		{
+			name: "Non latin album title",
+			trAlbum: &TrAlbum{
+				ItemType:   "track",
+				AlbumURL:   "/album/-",
+				AlbumTitle: "夢の中へ",
+			},
+			expected: toPointer("夢の中へ"),
+		},
+		{
+			name: "Album page",
+			trAlbum: &TrAlbum{
+				ItemType: "album",
+				URL:      "https://kinggizzard.bandcamp.com/album/12-bar-bruise",
+			},
+			expected: toPointer("12 Bar Bruise"),
 		},
 		{
 			name:     "Nil TrAlbum",
@@ -109,6 +193,58 @@ func (s *TestTrAlbumSuite) Test_getAlbumName() {
 	}
 }
 
+func (s *TestTrAlbumSuite) TestToTracks() {
+	trAlbum := &TrAlbum{
+		Current:          Current{Title: "12 Bar Bruise"},
+		Artist:           "King Gizzard & The Lizard Wizard",
+		ItemType:         "album",
+		ID:               2765388374,
+		URL:              "https://kinggizzard.bandcamp.com/album/12-bar-bruise",
+		AlbumReleaseDate: "07 Sep 2012 00:00:00 GMT",
+		Trackinfo: []TrackInfo{
+			{Title: "Elbow", TrackNum: 1, TrackID: 3749823254, TitleLink: "/track/elbow", Duration: 159.88, File: File{Mp3128: "https://example.com/elbow"}},
+			{Title: "Nein / Ja", TrackNum: 2, TrackID: 1090418036, TitleLink: "/track/nein-ja"},
+		},
+	}
+
+	tracks := trAlbum.ToTracks()
+
+	s.Equal([]*model.Track{
+		{
+			Title:       "Elbow",
+			TrackNumber: 1,
+			Artist:      "King Gizzard & The Lizard Wizard",
+			Album:       toPointer("12 Bar Bruise"),
+			AlbumArtist: "King Gizzard & The Lizard Wizard",
+			TrackTotal:  2,
+			ReleaseYear: 2012,
+			TrackID:     3749823254,
+			AlbumID:     2765388374,
+			URL:         "https://kinggizzard.bandcamp.com/track/elbow",
+			DownloadURL: "https://example.com/elbow",
+			Duration:    159.88,
+		},
+		{
+			Title:       "Nein / Ja",
+			TrackNumber: 2,
+			Artist:      "King Gizzard & The Lizard Wizard",
+			Album:       toPointer("12 Bar Bruise"),
+			AlbumArtist: "King Gizzard & The Lizard Wizard",
+			TrackTotal:  2,
+			ReleaseYear: 2012,
+			TrackID:     1090418036,
+			AlbumID:     2765388374,
+			URL:         "https://kinggizzard.bandcamp.com/track/nein-ja",
+		},
+	}, tracks)
+	s.Nil((*TrAlbum)(nil).ToTracks())
+}
+
+func (s *TestTrAlbumSuite) TestArtworkURL() {
+	s.Equal("https://f4.bcbits.com/img/a3529909906_10.jpg", ArtworkURL(3529909906, DefaultArtworkSize))
+	s.Equal("https://f4.bcbits.com/img/a0012345678_2.jpg", ArtworkURL(12345678, 2))
+}
+
 func toPointer(s string) *string {
 	return &s
 }
@@ -1,35 +1,45 @@
 package handler
 
 import (
+	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
 	"net/http"
 	"net/url"
 	"regexp"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/gorilla/mux"
+	"github.com/josedelrio85/bndcmp_downloader/internal/jobs"
+	"github.com/josedelrio85/bndcmp_downloader/internal/retriever"
 	"github.com/josedelrio85/bndcmp_downloader/internal/scrapper"
+	"github.com/josedelrio85/bndcmp_downloader/internal/verifier"
 )
 
+// HttpHandler serves the API. Scrappers keep the state of their run, so every request
+// gets its own from scrapperFactory.
 type HttpHandler struct {
-	baseFolder          string
-	discographyScrapper scrapper.Scrapper
-	albumScrapper       scrapper.Scrapper
-	trackScrapper       scrapper.Scrapper
+	baseFolder      string
+	scrapperFactory jobs.Factory
+	libraryVerifier verifier.Verifier
+	jobQueue        jobs.Queue
 }
 
 func NewHttpHandler(
 	baseFolder string,
-	discographyScrapper scrapper.Scrapper,
-	albumScrapper scrapper.Scrapper,
-	trackScrapper scrapper.Scrapper,
+	scrapperFactory jobs.Factory,
+	libraryVerifier verifier.Verifier,
+	jobQueue jobs.Queue,
 ) *HttpHandler {
 	return &HttpHandler{
-		baseFolder:          baseFolder,
-		discographyScrapper: discographyScrapper,
-		albumScrapper:       albumScrapper,
-		trackScrapper:       trackScrapper,
+		baseFolder:      baseFolder,
+		scrapperFactory: scrapperFactory,
+		libraryVerifier: libraryVerifier,
+		jobQueue:        jobQueue,
 	}
 }
 
@@ -53,11 +63,16 @@ func (h *HttpHandler) GetDiscography(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	err = h.discographyScrapper.Execute(discographyURL)
+	scrapper, err := h.getScrapper(discographyURL)
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
+	err = scrapper.Execute(r.Context(), discographyURL)
+	if err != nil {
+		writeScrappError(w, err)
+		return
+	}
 
 	w.WriteHeader(http.StatusOK)
 	w.Write([]byte("Discography of " + artist + " downloaded successfully"))
@@ -83,11 +98,16 @@ func (h *HttpHandler) GetAlbum(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	err = h.albumScrapper.Execute(albumURL)
+	scrapper, err := h.getScrapper(albumURL)
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
+	err = scrapper.Execute(r.Context(), albumURL)
+	if err != nil {
+		writeScrappError(w, err)
+		return
+	}
 
 	w.WriteHeader(http.StatusOK)
 	w.Write([]byte("Album " + album + " of " + artist + " downloaded successfully"))
@@ -113,11 +133,16 @@ func (h *HttpHandler) GetTrack(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	err = h.trackScrapper.Execute(trackURL)
+	scrapper, err := h.getScrapper(trackURL)
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
+	err = scrapper.Execute(r.Context(), trackURL)
+	if err != nil {
+		writeScrappError(w, err)
+		return
+	}
 
 	w.WriteHeader(http.StatusOK)
 	w.Write([]byte("Track " + track + " of " + artist + " downloaded successfully"))
@@ -148,9 +173,9 @@ func (h *HttpHandler) Scrapp(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
-	err = scrapper.Execute(scrapURL)
+	err = scrapper.Execute(r.Context(), scrapURL)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		writeScrappError(w, err)
 		return
 	}
 
@@ -158,21 +183,208 @@ func (h *HttpHandler) Scrapp(w http.ResponseWriter, r *http.Request) {
 	w.Write([]byte("Request processed successfully"))
 }
 
-func (h *HttpHandler) getScrapper(scrapURL *url.URL) (scrapper.Scrapper, error) {
-	path := scrapURL.Path
-	pathParts := strings.Split(path, "/")
-	if len(pathParts) < 2 {
-		return nil, fmt.Errorf("invalid URL")
+// Verify reports the damaged files of the library as JSON. A POST also repairs them,
+// so the next scrape downloads them again.
+func (h *HttpHandler) Verify(w http.ResponseWriter, r *http.Request) {
+	repair := r.Method == http.MethodPost
+	log.Println("Verifying library, repair: ", repair)
+	report, err := h.libraryVerifier.Verify(r.Context(), repair)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
 	}
 
-	if pathParts[1] == "music" {
-		return h.discographyScrapper, nil
-	} else if pathParts[1] == "album" {
-		return h.albumScrapper, nil
-	} else if pathParts[1] == "track" {
-		return h.trackScrapper, nil
+	writeJSON(w, http.StatusOK, report)
+}
+
+type createJobRequest struct {
+	URL string `json:"url"`
+}
+
+// CreateJob queues the download of the Bandcamp URL of the body, {"url": "..."}, and
+// answers with the job to follow at /jobs/{id}.
+func (h *HttpHandler) CreateJob(w http.ResponseWriter, r *http.Request) {
+	var request createJobRequest
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		http.Error(w, "Invalid job request: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	jobURL, err := url.Parse(request.URL)
+	if err != nil || !isValidBandcampURL(jobURL) {
+		http.Error(w, "Invalid Bandcamp URL", http.StatusBadRequest)
+		return
 	}
-	return nil, fmt.Errorf("invalid scrap type")
+
+	job, err := h.jobQueue.Enqueue(jobURL)
+	if err != nil {
+		writeJobError(w, err)
+		return
+	}
+
+	w.Header().Set("Location", "/api/v1/jobs/"+job.ID)
+	writeJSON(w, http.StatusAccepted, job)
+}
+
+func (h *HttpHandler) GetJob(w http.ResponseWriter, r *http.Request) {
+	job, found := h.jobQueue.Get(mux.Vars(r)["id"])
+	if !found {
+		http.Error(w, "Job not found", http.StatusNotFound)
+		return
+	}
+	writeJSON(w, http.StatusOK, job)
+}
+
+// ListJobs lists the jobs waiting, running and recently finished, newest first.
+func (h *HttpHandler) ListJobs(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, h.jobQueue.List())
+}
+
+// CancelJob stops a job for good, a running one once the track it is saving is aborted
+// and its partial file removed.
+func (h *HttpHandler) CancelJob(w http.ResponseWriter, r *http.Request) {
+	job, err := h.jobQueue.Cancel(mux.Vars(r)["id"])
+	if err != nil {
+		writeJobError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusAccepted, job)
+}
+
+// PauseJob stops a job until it is resumed, it then goes on where it stopped.
+func (h *HttpHandler) PauseJob(w http.ResponseWriter, r *http.Request) {
+	job, err := h.jobQueue.Pause(mux.Vars(r)["id"])
+	if err != nil {
+		writeJobError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusAccepted, job)
+}
+
+func (h *HttpHandler) ResumeJob(w http.ResponseWriter, r *http.Request) {
+	job, err := h.jobQueue.Unpause(mux.Vars(r)["id"])
+	if err != nil {
+		writeJobError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusAccepted, job)
+}
+
+func writeJobError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, jobs.ErrNotFound):
+		http.Error(w, "Job not found", http.StatusNotFound)
+	case errors.Is(err, jobs.ErrInvalidState):
+		http.Error(w, err.Error(), http.StatusConflict)
+	case errors.Is(err, jobs.ErrQueueFull):
+		http.Error(w, err.Error(), http.StatusServiceUnavailable)
+	default:
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+// eventsKeepAlive is how often a comment is sent to a quiet event stream, so proxies
+// do not close it.
+const eventsKeepAlive = 15 * time.Second
+
+// JobEvents streams the progress of a job as Server-Sent Events until it finishes. A
+// client reconnecting with the Last-Event-ID header only gets the events it missed.
+func (h *HttpHandler) JobEvents(w http.ResponseWriter, r *http.Request) {
+	id := mux.Vars(r)["id"]
+	lastEventID, _ := strconv.ParseInt(r.Header.Get("Last-Event-ID"), 10, 64)
+	events, changed, found := h.jobQueue.Events(id, lastEventID)
+	if !found {
+		http.Error(w, "Job not found", http.StatusNotFound)
+		return
+	}
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "Streaming not supported", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.WriteHeader(http.StatusOK)
+	keepAlive := time.NewTicker(eventsKeepAlive)
+	defer keepAlive.Stop()
+	for {
+		for _, event := range events {
+			if err := writeEvent(w, event); err != nil {
+				log.Println("Error writing event: ", err)
+				return
+			}
+			lastEventID = event.ID
+		}
+		flusher.Flush()
+		if changed == nil {
+			return
+		}
+
+		select {
+		case <-r.Context().Done():
+			return
+		case <-keepAlive.C:
+			fmt.Fprint(w, ": keep-alive\n\n")
+			events = nil
+			continue
+		case <-changed:
+		}
+		if events, changed, found = h.jobQueue.Events(id, lastEventID); !found {
+			return
+		}
+	}
+}
+
+func writeEvent(w http.ResponseWriter, event jobs.Event) error {
+	data, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(w, "id: %d\nevent: %s\ndata: %s\n\n", event.ID, event.Type, data)
+	return err
+}
+
+func writeJSON(w http.ResponseWriter, status int, value any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(value); err != nil {
+		log.Println("Error writing response: ", err)
+	}
+}
+
+// writeScrappError maps retrieval failures to the closest HTTP status instead of a blanket 500.
+func writeScrappError(w http.ResponseWriter, err error) {
+	status := scrappErrorStatus(err)
+	var statusErr *retriever.StatusError
+	if status == http.StatusServiceUnavailable && errors.As(err, &statusErr) && statusErr.RetryAfter > 0 {
+		w.Header().Set("Retry-After", strconv.Itoa(int(statusErr.RetryAfter.Seconds())))
+	}
+	http.Error(w, err.Error(), status)
+}
+
+func scrappErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, retriever.ErrNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, retriever.ErrGone):
+		return http.StatusGone
+	case errors.Is(err, retriever.ErrRateLimited):
+		return http.StatusServiceUnavailable
+	case errors.Is(err, retriever.ErrForbidden),
+		errors.Is(err, retriever.ErrServerError),
+		errors.Is(err, retriever.ErrUnexpectedStatus),
+		errors.Is(err, retriever.ErrUnexpectedContent):
+		return http.StatusBadGateway
+	case errors.Is(err, context.DeadlineExceeded):
+		return http.StatusGatewayTimeout
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+func (h *HttpHandler) getScrapper(scrapURL *url.URL) (scrapper.Executer, error) {
+	return h.scrapperFactory.New(scrapURL, nil)
 }
 
 func isValidBandcampURL(u *url.URL) bool {
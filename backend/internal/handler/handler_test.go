@@ -1,16 +1,27 @@
 package handler
 
 import (
+	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"net/http"
 	"net/http/httptest"
 	"net/url"
 	"strings"
+	"sync"
 	"testing"
+	"time"
 
 	"github.com/golang/mock/gomock"
 	"github.com/gorilla/mux"
+	"github.com/josedelrio85/bndcmp_downloader/internal/album_catalog"
+	"github.com/josedelrio85/bndcmp_downloader/internal/jobs"
+	"github.com/josedelrio85/bndcmp_downloader/internal/parser"
+	"github.com/josedelrio85/bndcmp_downloader/internal/retriever"
 	"github.com/josedelrio85/bndcmp_downloader/internal/scrapper"
+	"github.com/josedelrio85/bndcmp_downloader/internal/verifier"
 	"github.com/stretchr/testify/suite"
 )
 
@@ -21,6 +32,9 @@ type HandlerTestSuite struct {
 	mockDiscographyScrapper *scrapper.MockScrapper
 	mockAlbumScrapper       *scrapper.MockScrapper
 	mockTrackScrapper       *scrapper.MockScrapper
+	mockFactory             *jobs.MockFactory
+	mockVerifier            *verifier.MockVerifier
+	mockJobQueue            *jobs.MockQueue
 }
 
 func TestHandlerSuite(t *testing.T) {
@@ -34,11 +48,26 @@ func (s *HandlerTestSuite) SetupTest() {
 	s.mockDiscographyScrapper = scrapper.NewMockScrapper(s.ctrl)
 	s.mockAlbumScrapper = scrapper.NewMockScrapper(s.ctrl)
 	s.mockTrackScrapper = scrapper.NewMockScrapper(s.ctrl)
+	s.mockFactory = jobs.NewMockFactory(s.ctrl)
+	s.mockFactory.EXPECT().New(gomock.Any(), gomock.Any()).DoAndReturn(func(resourceURL *url.URL, observer scrapper.Observer) (scrapper.Executer, error) {
+		switch scrapper.TypeOf(resourceURL) {
+		case scrapper.Discography:
+			return s.mockDiscographyScrapper, nil
+		case scrapper.Album:
+			return s.mockAlbumScrapper, nil
+		case scrapper.Track:
+			return s.mockTrackScrapper, nil
+		default:
+			return nil, scrapper.ErrUnsupportedURL
+		}
+	}).AnyTimes()
+	s.mockVerifier = verifier.NewMockVerifier(s.ctrl)
+	s.mockJobQueue = jobs.NewMockQueue(s.ctrl)
 	s.handler = NewHttpHandler(
 		baseFolder,
-		s.mockDiscographyScrapper,
-		s.mockAlbumScrapper,
-		s.mockTrackScrapper,
+		s.mockFactory,
+		s.mockVerifier,
+		s.mockJobQueue,
 	)
 }
 
@@ -61,7 +90,7 @@ func (s *HandlerTestSuite) TestGetDiscography() {
 	// Use the router to create a new request
 	req = mux.SetURLVars(req, map[string]string{"artist": "testartist"})
 
-	s.mockDiscographyScrapper.EXPECT().Execute(gomock.Any()).Return(nil).AnyTimes()
+	s.mockDiscographyScrapper.EXPECT().Execute(gomock.Any(), gomock.Any()).Return(nil).AnyTimes()
 
 	rr := httptest.NewRecorder()
 	handler := http.HandlerFunc(s.handler.GetDiscography)
@@ -77,7 +106,7 @@ func (s *HandlerTestSuite) TestGetAlbum() {
 	s.Require().NoError(err)
 	req = mux.SetURLVars(req, map[string]string{"artist": "testartist", "album": "testalbum"})
 
-	s.mockAlbumScrapper.EXPECT().Execute(gomock.Any()).Return(nil).AnyTimes()
+	s.mockAlbumScrapper.EXPECT().Execute(gomock.Any(), gomock.Any()).Return(nil).AnyTimes()
 
 	rr := httptest.NewRecorder()
 	handler := http.HandlerFunc(s.handler.GetAlbum)
@@ -93,7 +122,7 @@ func (s *HandlerTestSuite) TestGetTrack() {
 	s.Require().NoError(err)
 	req = mux.SetURLVars(req, map[string]string{"artist": "testartist", "track": "testtrack"})
 
-	s.mockTrackScrapper.EXPECT().Execute(gomock.Any()).Return(nil).AnyTimes()
+	s.mockTrackScrapper.EXPECT().Execute(gomock.Any(), gomock.Any()).Return(nil).AnyTimes()
 
 	rr := httptest.NewRecorder()
 	handler := http.HandlerFunc(s.handler.GetTrack)
@@ -176,6 +205,7 @@ func (s *HandlerTestSuite) TestGetTrack_BadRequest_NoTrack() {
 }
 
 func (s *HandlerTestSuite) Test_getScrapper() {
+	s.handler.scrapperFactory = scrapper.NewFactory(nil, nil, nil, nil)
 
 	testCases := []struct {
 		desc           string
@@ -220,6 +250,9 @@ func (s *HandlerTestSuite) Test_getScrapper() {
 		if tt.expectedResult {
 			s.NoError(err)
 			s.NotNil(scrapper)
+			other, err := s.handler.getScrapper(scrapURL)
+			s.NoError(err)
+			s.NotSame(scrapper, other, "every request needs a scrapper of its own")
 		} else {
 			s.Error(err)
 			s.Nil(scrapper)
@@ -385,7 +418,7 @@ func (s *HandlerTestSuite) Test_Scrapp() {
 		req.URL.RawQuery = q.Encode()
 
 		if tc.scrapper != nil {
-			tc.scrapper.EXPECT().Execute(gomock.Any()).Return(nil).AnyTimes()
+			tc.scrapper.EXPECT().Execute(gomock.Any(), gomock.Any()).Return(nil).AnyTimes()
 		}
 
 		rr := httptest.NewRecorder()
@@ -397,3 +430,452 @@ func (s *HandlerTestSuite) Test_Scrapp() {
 		s.Equal(tc.expectedBody, strings.TrimSpace(rr.Body.String()))
 	}
 }
+
+// Test_Scrapp_Concurrent runs under go test -race: requests arriving at the same time
+// must not share the scrapper keeping the track of its run.
+func (s *HandlerTestSuite) Test_Scrapp_Concurrent() {
+	requests := 8
+	var arrived sync.WaitGroup
+	arrived.Add(requests)
+	mockRetriever := scrapper.NewMockRetriever(s.ctrl)
+	mockRetriever.EXPECT().Retrieve(gomock.Any(), gomock.Any(), gomock.Any()).DoAndReturn(func(ctx context.Context, trackURL string, accept string) (*retriever.Response, error) {
+		arrived.Done()
+		arrived.Wait()
+		slug := trackURL[strings.LastIndex(trackURL, "/")+1:]
+		page := fmt.Sprintf(`<html><body><script data-tralbum='{"artist": "Artist", "current": {"title": "%s"}}'></script></body></html>`, slug)
+		return &retriever.Response{ReadCloser: io.NopCloser(strings.NewReader(page)), ContentLength: int64(len(page))}, nil
+	}).AnyTimes()
+	folder := s.T().TempDir()
+	s.handler.scrapperFactory = scrapper.NewFactory(mockRetriever, parser.NewParseClient(), scrapper.NewMockSaver(s.ctrl), album_catalog.NewInMemoryAlbumCatalog(folder))
+
+	var wg sync.WaitGroup
+	for i := 1; i <= requests; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			req := httptest.NewRequest("GET", "/api/v1/scrapp?url="+url.QueryEscape(fmt.Sprintf("https://testartist.bandcamp.com/track/track-%d", i)), nil)
+			rr := httptest.NewRecorder()
+
+			s.handler.Scrapp(rr, req)
+
+			s.Equal(http.StatusOK, rr.Code)
+		}(i)
+	}
+	wg.Wait()
+}
+
+func (s *HandlerTestSuite) Test_Scrapp_ErrorMapping() {
+	testCases := []struct {
+		desc               string
+		err                error
+		expectedStatus     int
+		expectedRetryAfter string
+	}{
+		{
+			desc:           "Not found",
+			err:            &retriever.StatusError{URL: "https://testartist.bandcamp.com/album/testalbum", StatusCode: http.StatusNotFound},
+			expectedStatus: http.StatusNotFound,
+		},
+		{
+			desc:           "Gone",
+			err:            &retriever.StatusError{URL: "https://testartist.bandcamp.com/album/testalbum", StatusCode: http.StatusGone},
+			expectedStatus: http.StatusGone,
+		},
+		{
+			desc:               "Rate limited",
+			err:                &retriever.StatusError{URL: "https://testartist.bandcamp.com/album/testalbum", StatusCode: http.StatusTooManyRequests, RetryAfter: 30 * time.Second},
+			expectedStatus:     http.StatusServiceUnavailable,
+			expectedRetryAfter: "30",
+		},
+		{
+			desc:           "Upstream server error",
+			err:            &retriever.StatusError{URL: "https://testartist.bandcamp.com/album/testalbum", StatusCode: http.StatusBadGateway},
+			expectedStatus: http.StatusBadGateway,
+		},
+		{
+			desc:           "Unexpected content",
+			err:            &retriever.ContentTypeError{URL: "https://t4.bcbits.com/stream", Expected: "audio/mpeg", Actual: "text/html"},
+			expectedStatus: http.StatusBadGateway,
+		},
+		{
+			desc:           "Deadline exceeded",
+			err:            fmt.Errorf("retrieving album: %w", context.DeadlineExceeded),
+			expectedStatus: http.StatusGatewayTimeout,
+		},
+		{
+			desc:           "Unknown error",
+			err:            errors.New("disk full"),
+			expectedStatus: http.StatusInternalServerError,
+		},
+	}
+
+	for _, tc := range testCases {
+		s.Run(tc.desc, func() {
+			req, err := http.NewRequest("GET", "/api/v1/scrapp", nil)
+			s.Require().NoError(err)
+			q := req.URL.Query()
+			q.Add("url", "https://testartist.bandcamp.com/album/testalbum")
+			req.URL.RawQuery = q.Encode()
+
+			s.mockAlbumScrapper.EXPECT().Execute(gomock.Any(), gomock.Any()).Return(tc.err)
+
+			rr := httptest.NewRecorder()
+			handler := http.HandlerFunc(s.handler.Scrapp)
+
+			handler.ServeHTTP(rr, req)
+
+			s.Equal(tc.expectedStatus, rr.Code)
+			s.Equal(tc.err.Error(), strings.TrimSpace(rr.Body.String()))
+			s.Equal(tc.expectedRetryAfter, rr.Header().Get("Retry-After"))
+		})
+	}
+}
+
+func (s *HandlerTestSuite) TestVerify() {
+	testCases := []struct {
+		desc           string
+		method         string
+		expectedRepair bool
+	}{
+		{desc: "GET only reports", method: "GET", expectedRepair: false},
+		{desc: "POST repairs", method: "POST", expectedRepair: true},
+	}
+
+	for _, tc := range testCases {
+		s.Run(tc.desc, func() {
+			req, err := http.NewRequest(tc.method, "/api/v1/verify", nil)
+			s.Require().NoError(err)
+
+			s.mockVerifier.EXPECT().Verify(gomock.Any(), tc.expectedRepair).Return(&verifier.Report{
+				Checked: 12,
+				Issues: []verifier.Issue{
+					{Path: "Artist/Album/01 - Track.mp3", Problem: verifier.ZeroByte, Repaired: tc.expectedRepair},
+				},
+			}, nil)
+
+			rr := httptest.NewRecorder()
+			handler := http.HandlerFunc(s.handler.Verify)
+
+			handler.ServeHTTP(rr, req)
+
+			s.Equal(http.StatusOK, rr.Code)
+			s.Equal("application/json", rr.Header().Get("Content-Type"))
+			s.JSONEq(fmt.Sprintf(`{"checked":12,"issues":[{"path":"Artist/Album/01 - Track.mp3","problem":"zero_byte","repaired":%t}]}`, tc.expectedRepair), rr.Body.String())
+		})
+	}
+}
+
+func (s *HandlerTestSuite) TestVerify_Error() {
+	req, err := http.NewRequest("GET", "/api/v1/verify", nil)
+	s.Require().NoError(err)
+
+	s.mockVerifier.EXPECT().Verify(gomock.Any(), false).Return(nil, errors.New("permission denied"))
+
+	rr := httptest.NewRecorder()
+	handler := http.HandlerFunc(s.handler.Verify)
+
+	handler.ServeHTTP(rr, req)
+
+	s.Equal(http.StatusInternalServerError, rr.Code)
+	s.Equal("permission denied", strings.TrimSpace(rr.Body.String()))
+}
+
+func (s *HandlerTestSuite) TestCreateJob() {
+	createdAt := time.Date(2024, 10, 10, 12, 0, 0, 0, time.UTC)
+	req, err := http.NewRequest("POST", "/api/v1/jobs", strings.NewReader(`{"url":"https://kinggizzard.bandcamp.com/music"}`))
+	s.Require().NoError(err)
+
+	s.mockJobQueue.EXPECT().Enqueue(gomock.Any()).DoAndReturn(func(jobURL *url.URL) (jobs.Job, error) {
+		s.Equal("https://kinggizzard.bandcamp.com/music", jobURL.String())
+		return jobs.Job{ID: "0123456789abcdef", URL: jobURL.String(), State: jobs.Queued, Failures: []jobs.Failure{}, CreatedAt: createdAt}, nil
+	})
+
+	rr := httptest.NewRecorder()
+	handler := http.HandlerFunc(s.handler.CreateJob)
+
+	handler.ServeHTTP(rr, req)
+
+	s.Equal(http.StatusAccepted, rr.Code)
+	s.Equal("/api/v1/jobs/0123456789abcdef", rr.Header().Get("Location"))
+	s.JSONEq(`{"id":"0123456789abcdef","url":"https://kinggizzard.bandcamp.com/music","state":"queued",
+		"tracks":{"downloaded":0,"skipped":0,"failed":0},"failures":[],"created_at":"2024-10-10T12:00:00Z"}`, rr.Body.String())
+}
+
+func (s *HandlerTestSuite) TestCreateJob_BadRequest() {
+	testCases := []struct {
+		desc string
+		body string
+	}{
+		{desc: "Invalid JSON", body: `{"url":`},
+		{desc: "No URL", body: `{}`},
+		{desc: "Not Bandcamp", body: `{"url":"https://example.com/album/test"}`},
+	}
+
+	for _, tc := range testCases {
+		s.Run(tc.desc, func() {
+			req, err := http.NewRequest("POST", "/api/v1/jobs", strings.NewReader(tc.body))
+			s.Require().NoError(err)
+
+			rr := httptest.NewRecorder()
+			handler := http.HandlerFunc(s.handler.CreateJob)
+
+			handler.ServeHTTP(rr, req)
+
+			s.Equal(http.StatusBadRequest, rr.Code)
+		})
+	}
+}
+
+func (s *HandlerTestSuite) TestCreateJob_QueueFull() {
+	req, err := http.NewRequest("POST", "/api/v1/jobs", strings.NewReader(`{"url":"https://kinggizzard.bandcamp.com/music"}`))
+	s.Require().NoError(err)
+
+	s.mockJobQueue.EXPECT().Enqueue(gomock.Any()).Return(jobs.Job{}, jobs.ErrQueueFull)
+
+	rr := httptest.NewRecorder()
+	handler := http.HandlerFunc(s.handler.CreateJob)
+
+	handler.ServeHTTP(rr, req)
+
+	s.Equal(http.StatusServiceUnavailable, rr.Code)
+}
+
+func (s *HandlerTestSuite) TestGetJob() {
+	req, err := http.NewRequest("GET", "/api/v1/jobs/0123456789abcdef", nil)
+	s.Require().NoError(err)
+	req = mux.SetURLVars(req, map[string]string{"id": "0123456789abcdef"})
+
+	s.mockJobQueue.EXPECT().Get("0123456789abcdef").Return(jobs.Job{
+		ID:       "0123456789abcdef",
+		State:    jobs.Failed,
+		Tracks:   jobs.Counts{Downloaded: 3, Skipped: 1, Failed: 1},
+		Failures: []jobs.Failure{{Track: "Elbow", Error: "invalid MP3 stream"}},
+		Error:    "invalid MP3 stream",
+	}, true)
+
+	rr := httptest.NewRecorder()
+	handler := http.HandlerFunc(s.handler.GetJob)
+
+	handler.ServeHTTP(rr, req)
+
+	s.Equal(http.StatusOK, rr.Code)
+	s.Equal("application/json", rr.Header().Get("Content-Type"))
+	s.JSONEq(`{"id":"0123456789abcdef","url":"","state":"failed","tracks":{"downloaded":3,"skipped":1,"failed":1},
+		"failures":[{"track":"Elbow","error":"invalid MP3 stream"}],"error":"invalid MP3 stream","created_at":"0001-01-01T00:00:00Z"}`, rr.Body.String())
+}
+
+func (s *HandlerTestSuite) TestGetJob_NotFound() {
+	req, err := http.NewRequest("GET", "/api/v1/jobs/missing", nil)
+	s.Require().NoError(err)
+	req = mux.SetURLVars(req, map[string]string{"id": "missing"})
+
+	s.mockJobQueue.EXPECT().Get("missing").Return(jobs.Job{}, false)
+
+	rr := httptest.NewRecorder()
+	handler := http.HandlerFunc(s.handler.GetJob)
+
+	handler.ServeHTTP(rr, req)
+
+	s.Equal(http.StatusNotFound, rr.Code)
+}
+
+func (s *HandlerTestSuite) TestListJobs() {
+	req, err := http.NewRequest("GET", "/api/v1/jobs", nil)
+	s.Require().NoError(err)
+
+	s.mockJobQueue.EXPECT().List().Return([]jobs.Job{{ID: "second", State: jobs.Running}, {ID: "first", State: jobs.Completed}})
+
+	rr := httptest.NewRecorder()
+	handler := http.HandlerFunc(s.handler.ListJobs)
+
+	handler.ServeHTTP(rr, req)
+
+	s.Equal(http.StatusOK, rr.Code)
+	var listed []jobs.Job
+	s.Require().NoError(json.Unmarshal(rr.Body.Bytes(), &listed))
+	s.Require().Len(listed, 2)
+	s.Equal("second", listed[0].ID)
+	s.Equal(jobs.Completed, listed[1].State)
+}
+
+func (s *HandlerTestSuite) TestJobEvents() {
+	req, err := http.NewRequest("GET", "/api/v1/jobs/0123456789abcdef/events", nil)
+	s.Require().NoError(err)
+	req = mux.SetURLVars(req, map[string]string{"id": "0123456789abcdef"})
+
+	changed := make(chan struct{})
+	close(changed)
+	s.mockJobQueue.EXPECT().Events("0123456789abcdef", int64(0)).Return([]jobs.Event{
+		{ID: 1, Type: jobs.TrackStarted, Album: "12 Bar Bruise", Track: "Elbow"},
+	}, changed, true)
+	s.mockJobQueue.EXPECT().Events("0123456789abcdef", int64(1)).Return([]jobs.Event{
+		{ID: 2, Type: jobs.TrackProgress, Album: "12 Bar Bruise", Track: "Elbow", Written: 1024, Total: -1},
+		{ID: 3, Type: jobs.StateChanged, Job: &jobs.Job{ID: "0123456789abcdef", State: jobs.Completed, Failures: []jobs.Failure{}}},
+	}, nil, true)
+
+	rr := httptest.NewRecorder()
+	handler := http.HandlerFunc(s.handler.JobEvents)
+
+	handler.ServeHTTP(rr, req)
+
+	s.Equal(http.StatusOK, rr.Code)
+	s.Equal("text/event-stream", rr.Header().Get("Content-Type"))
+	s.True(rr.Flushed)
+	s.Equal(`id: 1
+event: track_started
+data: {"album":"12 Bar Bruise","track":"Elbow"}
+
+id: 2
+event: track_progress
+data: {"album":"12 Bar Bruise","track":"Elbow","written":1024,"total":-1}
+
+id: 3
+event: state
+data: {"job":{"id":"0123456789abcdef","url":"","state":"completed","tracks":{"downloaded":0,"skipped":0,"failed":0},"failures":[],"created_at":"0001-01-01T00:00:00Z"}}
+
+`, rr.Body.String())
+}
+
+func (s *HandlerTestSuite) TestJobEvents_LastEventID() {
+	req, err := http.NewRequest("GET", "/api/v1/jobs/0123456789abcdef/events", nil)
+	s.Require().NoError(err)
+	req = mux.SetURLVars(req, map[string]string{"id": "0123456789abcdef"})
+	req.Header.Set("Last-Event-ID", "41")
+
+	s.mockJobQueue.EXPECT().Events("0123456789abcdef", int64(41)).Return([]jobs.Event{{ID: 42, Type: jobs.TrackSkipped, Track: "Elbow"}}, nil, true)
+
+	rr := httptest.NewRecorder()
+	handler := http.HandlerFunc(s.handler.JobEvents)
+
+	handler.ServeHTTP(rr, req)
+
+	s.Equal(http.StatusOK, rr.Code)
+	s.Equal("id: 42\nevent: track_skipped\ndata: {\"track\":\"Elbow\"}\n\n", rr.Body.String())
+}
+
+func (s *HandlerTestSuite) TestJobEvents_ClientGone() {
+	ctx, cancel := context.WithCancel(context.Background())
+	req, err := http.NewRequestWithContext(ctx, "GET", "/api/v1/jobs/0123456789abcdef/events", nil)
+	s.Require().NoError(err)
+	req = mux.SetURLVars(req, map[string]string{"id": "0123456789abcdef"})
+
+	s.mockJobQueue.EXPECT().Events("0123456789abcdef", int64(0)).DoAndReturn(func(id string, after int64) ([]jobs.Event, <-chan struct{}, bool) {
+		cancel()
+		return nil, make(chan struct{}), true
+	})
+
+	rr := httptest.NewRecorder()
+	handler := http.HandlerFunc(s.handler.JobEvents)
+
+	handler.ServeHTTP(rr, req)
+
+	s.Equal(http.StatusOK, rr.Code)
+	s.Empty(rr.Body.String(), "the stream should stop once the client is gone")
+}
+
+func (s *HandlerTestSuite) TestJobEvents_NotFound() {
+	req, err := http.NewRequest("GET", "/api/v1/jobs/missing/events", nil)
+	s.Require().NoError(err)
+	req = mux.SetURLVars(req, map[string]string{"id": "missing"})
+
+	s.mockJobQueue.EXPECT().Events("missing", int64(0)).Return(nil, nil, false)
+
+	rr := httptest.NewRecorder()
+	handler := http.HandlerFunc(s.handler.JobEvents)
+
+	handler.ServeHTTP(rr, req)
+
+	s.Equal(http.StatusNotFound, rr.Code)
+}
+
+func (s *HandlerTestSuite) TestCancelJob() {
+	req, err := http.NewRequest("DELETE", "/api/v1/jobs/0123456789abcdef", nil)
+	s.Require().NoError(err)
+	req = mux.SetURLVars(req, map[string]string{"id": "0123456789abcdef"})
+
+	s.mockJobQueue.EXPECT().Cancel("0123456789abcdef").Return(jobs.Job{ID: "0123456789abcdef", State: jobs.Cancelled, Failures: []jobs.Failure{}}, nil)
+
+	rr := httptest.NewRecorder()
+	handler := http.HandlerFunc(s.handler.CancelJob)
+
+	handler.ServeHTTP(rr, req)
+
+	s.Equal(http.StatusAccepted, rr.Code)
+	var job jobs.Job
+	s.NoError(json.Unmarshal(rr.Body.Bytes(), &job))
+	s.Equal(jobs.Cancelled, job.State)
+}
+
+func (s *HandlerTestSuite) TestPauseJob() {
+	req, err := http.NewRequest("POST", "/api/v1/jobs/0123456789abcdef/pause", nil)
+	s.Require().NoError(err)
+	req = mux.SetURLVars(req, map[string]string{"id": "0123456789abcdef"})
+
+	s.mockJobQueue.EXPECT().Pause("0123456789abcdef").Return(jobs.Job{ID: "0123456789abcdef", State: jobs.Running, Failures: []jobs.Failure{}}, nil)
+
+	rr := httptest.NewRecorder()
+	handler := http.HandlerFunc(s.handler.PauseJob)
+
+	handler.ServeHTTP(rr, req)
+
+	s.Equal(http.StatusAccepted, rr.Code)
+}
+
+func (s *HandlerTestSuite) TestResumeJob() {
+	req, err := http.NewRequest("POST", "/api/v1/jobs/0123456789abcdef/resume", nil)
+	s.Require().NoError(err)
+	req = mux.SetURLVars(req, map[string]string{"id": "0123456789abcdef"})
+
+	s.mockJobQueue.EXPECT().Unpause("0123456789abcdef").Return(jobs.Job{ID: "0123456789abcdef", State: jobs.Queued, Failures: []jobs.Failure{}}, nil)
+
+	rr := httptest.NewRecorder()
+	handler := http.HandlerFunc(s.handler.ResumeJob)
+
+	handler.ServeHTTP(rr, req)
+
+	s.Equal(http.StatusAccepted, rr.Code)
+	var job jobs.Job
+	s.NoError(json.Unmarshal(rr.Body.Bytes(), &job))
+	s.Equal(jobs.Queued, job.State)
+}
+
+func (s *HandlerTestSuite) TestResumeJob_ErrorMapping() {
+	testCases := []struct {
+		desc           string
+		err            error
+		expectedStatus int
+	}{
+		{
+			desc:           "Not found",
+			err:            jobs.ErrNotFound,
+			expectedStatus: http.StatusNotFound,
+		},
+		{
+			desc:           "Not paused",
+			err:            jobs.ErrInvalidState,
+			expectedStatus: http.StatusConflict,
+		},
+		{
+			desc:           "Queue full",
+			err:            jobs.ErrQueueFull,
+			expectedStatus: http.StatusServiceUnavailable,
+		},
+	}
+
+	for _, tc := range testCases {
+		s.Run(tc.desc, func() {
+			req, err := http.NewRequest("POST", "/api/v1/jobs/0123456789abcdef/resume", nil)
+			s.Require().NoError(err)
+			req = mux.SetURLVars(req, map[string]string{"id": "0123456789abcdef"})
+
+			s.mockJobQueue.EXPECT().Unpause("0123456789abcdef").Return(jobs.Job{}, tc.err)
+
+			rr := httptest.NewRecorder()
+			handler := http.HandlerFunc(s.handler.ResumeJob)
+
+			handler.ServeHTTP(rr, req)
+
+			s.Equal(tc.expectedStatus, rr.Code)
+		})
+	}
+}
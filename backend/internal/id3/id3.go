@@ -0,0 +1,205 @@
+package id3
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+)
+
+const (
+	headerSize      = 10
+	flagFooter      = 0x10
+	encodingUTF8    = 0x03
+	maxSyncsafe     = 1<<28 - 1
+	versionMajor    = 4
+	versionRevision = 0
+
+	pictureFrontCover = 0x03
+)
+
+// tempPattern names the copy a file is tagged through. It ends like the partial files
+// of the saver, so the copy left by an interrupted rewrite is cleaned up with them.
+const tempPattern = ".tagging-*.part"
+
+var ErrTagTooLarge = errors.New("id3: tag too large")
+
+// Tag holds the ID3v2.4 frames written on every downloaded track. Empty fields are skipped.
+type Tag struct {
+	Title       string
+	Artist      string
+	AlbumArtist string
+	Album       string
+	TrackNumber int64
+	TrackTotal  int64
+	Year        int
+	ISRC        string
+	URL         string
+	// Picture is embedded as the front cover, PictureMIME defaults to image/jpeg.
+	Picture     []byte
+	PictureMIME string
+}
+
+// Bytes encodes the tag as an ID3v2.4 header followed by its frames.
+func (t *Tag) Bytes() ([]byte, error) {
+	var frames bytes.Buffer
+	textFrames := []struct {
+		id    string
+		value string
+	}{
+		{"TIT2", t.Title},
+		{"TPE1", t.Artist},
+		{"TPE2", t.AlbumArtist},
+		{"TALB", t.Album},
+		{"TRCK", t.trackPosition()},
+		{"TDRC", t.year()},
+		{"TSRC", t.ISRC},
+	}
+	for _, frame := range textFrames {
+		if frame.value == "" {
+			continue
+		}
+		if err := writeFrame(&frames, frame.id, append([]byte{encodingUTF8}, frame.value...)); err != nil {
+			return nil, err
+		}
+	}
+	// URL link frames are always ISO-8859-1 and carry no encoding byte
+	if t.URL != "" {
+		if err := writeFrame(&frames, "WOAF", []byte(t.URL)); err != nil {
+			return nil, err
+		}
+	}
+
+	if len(t.Picture) > 0 {
+		if err := writeFrame(&frames, "APIC", t.pictureFrame()); err != nil {
+			return nil, err
+		}
+	}
+
+	if frames.Len() > maxSyncsafe {
+		return nil, ErrTagTooLarge
+	}
+	tag := make([]byte, 0, headerSize+frames.Len())
+	tag = append(tag, 'I', 'D', '3', versionMajor, versionRevision, 0)
+	tag = append(tag, syncsafe(uint32(frames.Len()))...)
+	return append(tag, frames.Bytes()...), nil
+}
+
+// pictureFrame encodes an APIC payload: encoding, MIME type, picture type, empty description, data.
+func (t *Tag) pictureFrame() []byte {
+	mime := t.PictureMIME
+	if mime == "" {
+		mime = "image/jpeg"
+	}
+	payload := make([]byte, 0, len(mime)+len(t.Picture)+4)
+	payload = append(payload, encodingUTF8)
+	payload = append(payload, mime...)
+	payload = append(payload, 0, pictureFrontCover, 0)
+	return append(payload, t.Picture...)
+}
+
+func (t *Tag) trackPosition() string {
+	if t.TrackNumber <= 0 {
+		return ""
+	}
+	if t.TrackTotal <= 0 {
+		return strconv.FormatInt(t.TrackNumber, 10)
+	}
+	return fmt.Sprintf("%d/%d", t.TrackNumber, t.TrackTotal)
+}
+
+func (t *Tag) year() string {
+	if t.Year <= 0 {
+		return ""
+	}
+	return strconv.Itoa(t.Year)
+}
+
+func writeFrame(w *bytes.Buffer, id string, payload []byte) error {
+	if len(payload) > maxSyncsafe {
+		return ErrTagTooLarge
+	}
+	w.WriteString(id)
+	w.Write(syncsafe(uint32(len(payload))))
+	w.Write([]byte{0, 0})
+	w.Write(payload)
+	return nil
+}
+
+// WriteFile replaces the ID3v2 tag at the start of the file with tag, keeping the audio untouched.
+// The file is rewritten through a temporary file in the same folder so it is never left half tagged.
+func WriteFile(path string, tag *Tag) error {
+	encoded, err := tag.Bytes()
+	if err != nil {
+		return err
+	}
+
+	source, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer source.Close()
+
+	offset, err := TagSize(source)
+	if err != nil {
+		return err
+	}
+	if _, err := source.Seek(offset, io.SeekStart); err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), tempPattern)
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(encoded); err != nil {
+		tmp.Close()
+		return err
+	}
+	if _, err := io.Copy(tmp, source); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	source.Close()
+	return os.Rename(tmp.Name(), path)
+}
+
+// TagSize returns the size of the ID3v2 tag the file starts with, 0 when it has none.
+func TagSize(r io.Reader) (int64, error) {
+	header := make([]byte, headerSize)
+	if _, err := io.ReadFull(r, header); err != nil {
+		if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+			return 0, nil
+		}
+		return 0, err
+	}
+	if !bytes.Equal(header[:3], []byte("ID3")) {
+		return 0, nil
+	}
+
+	size := int64(headerSize) + int64(unsyncsafe(header[6:10]))
+	if header[5]&flagFooter != 0 {
+		size += headerSize
+	}
+	return size, nil
+}
+
+func syncsafe(n uint32) []byte {
+	return []byte{byte(n >> 21 & 0x7f), byte(n >> 14 & 0x7f), byte(n >> 7 & 0x7f), byte(n & 0x7f)}
+}
+
+func unsyncsafe(b []byte) uint32 {
+	return uint32(b[0]&0x7f)<<21 | uint32(b[1]&0x7f)<<14 | uint32(b[2]&0x7f)<<7 | uint32(b[3]&0x7f)
+}
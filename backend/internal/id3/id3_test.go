@@ -0,0 +1,158 @@
+package id3
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/suite"
+)
+
+func TestID3(t *testing.T) {
+	suite.Run(t, new(TestID3Suite))
+}
+
+type TestID3Suite struct {
+	suite.Suite
+	tempDir string
+}
+
+func (s *TestID3Suite) SetupTest() {
+	s.tempDir = s.T().TempDir()
+}
+
+func (s *TestID3Suite) TestBytes() {
+	tag := &Tag{
+		Title:       "Elbow",
+		Artist:      "King Gizzard & The Lizard Wizard",
+		AlbumArtist: "King Gizzard & The Lizard Wizard",
+		Album:       "12 Bar Bruise",
+		TrackNumber: 1,
+		TrackTotal:  12,
+		Year:        2012,
+		ISRC:        "AUW631100218",
+		URL:         "https://kinggizzard.bandcamp.com/track/elbow",
+	}
+
+	encoded, err := tag.Bytes()
+
+	s.NoError(err)
+	s.Equal([]byte{'I', 'D', '3', 4, 0, 0}, encoded[:6])
+	s.Equal(uint32(len(encoded)-headerSize), unsyncsafe(encoded[6:10]))
+
+	frames := parseFrames(encoded[headerSize:])
+	s.Equal(map[string]string{
+		"TIT2": "\x03Elbow",
+		"TPE1": "\x03King Gizzard & The Lizard Wizard",
+		"TPE2": "\x03King Gizzard & The Lizard Wizard",
+		"TALB": "\x0312 Bar Bruise",
+		"TRCK": "\x031/12",
+		"TDRC": "\x032012",
+		"TSRC": "\x03AUW631100218",
+		"WOAF": "https://kinggizzard.bandcamp.com/track/elbow",
+	}, frames)
+}
+
+func (s *TestID3Suite) TestBytes_SkipsEmptyFields() {
+	tag := &Tag{Title: "Elbow", TrackNumber: 3}
+
+	encoded, err := tag.Bytes()
+
+	s.NoError(err)
+	s.Equal(map[string]string{
+		"TIT2": "\x03Elbow",
+		"TRCK": "\x033",
+	}, parseFrames(encoded[headerSize:]))
+}
+
+func (s *TestID3Suite) TestBytes_Picture() {
+	tag := &Tag{Title: "Elbow", Picture: []byte{0xff, 0xd8, 0xff}}
+
+	encoded, err := tag.Bytes()
+
+	s.NoError(err)
+	frames := parseFrames(encoded[headerSize:])
+	s.Equal("\x03image/jpeg\x00\x03\x00\xff\xd8\xff", frames["APIC"])
+}
+
+func (s *TestID3Suite) TestWriteFile() {
+	tests := []struct {
+		name    string
+		content []byte
+		audio   []byte
+	}{
+		{
+			name:    "Untagged file",
+			content: []byte("audio frames"),
+			audio:   []byte("audio frames"),
+		},
+		{
+			name:    "Replaces existing tag",
+			content: append([]byte{'I', 'D', '3', 3, 0, 0, 0, 0, 0, 4, 'o', 'l', 'd', '!'}, "audio frames"...),
+			audio:   []byte("audio frames"),
+		},
+		{
+			name:    "Replaces existing tag with footer",
+			content: append([]byte{'I', 'D', '3', 4, 0, flagFooter, 0, 0, 0, 1, 'x', '3', 'D', 'I', 4, 0, flagFooter, 0, 0, 0, 1}, "audio frames"...),
+			audio:   []byte("audio frames"),
+		},
+		{
+			name:    "Empty file",
+			content: []byte{},
+			audio:   []byte{},
+		},
+	}
+
+	tag := &Tag{Title: "Elbow", Artist: "King Gizzard & The Lizard Wizard"}
+	encoded, err := tag.Bytes()
+	s.Require().NoError(err)
+
+	for _, tt := range tests {
+		s.Run(tt.name, func() {
+			path := filepath.Join(s.tempDir, "track.mp3")
+			s.Require().NoError(os.WriteFile(path, tt.content, 0644))
+
+			err := WriteFile(path, tag)
+
+			s.NoError(err)
+			content, err := os.ReadFile(path)
+			s.NoError(err)
+			s.Equal(append(append([]byte{}, encoded...), tt.audio...), content)
+
+			entries, err := os.ReadDir(s.tempDir)
+			s.NoError(err)
+			s.Len(entries, 1, "temporary file should be removed")
+		})
+	}
+}
+
+func (s *TestID3Suite) TestWriteFile_TemporaryName() {
+	s.True(strings.HasSuffix(tempPattern, ".part"), "an interrupted rewrite should be cleaned up as a partial file")
+}
+
+func (s *TestID3Suite) TestWriteFile_Missing() {
+	err := WriteFile(filepath.Join(s.tempDir, "missing.mp3"), &Tag{Title: "Elbow"})
+
+	s.True(os.IsNotExist(err))
+}
+
+func (s *TestID3Suite) Test_syncsafe() {
+	for _, n := range []uint32{0, 127, 128, 255, 1 << 20, maxSyncsafe} {
+		encoded := syncsafe(n)
+		for _, b := range encoded {
+			s.Zero(b & 0x80)
+		}
+		s.Equal(n, unsyncsafe(encoded))
+	}
+}
+
+func parseFrames(data []byte) map[string]string {
+	frames := make(map[string]string)
+	for len(data) >= headerSize {
+		size := int(unsyncsafe(data[4:8]))
+		frames[string(data[:4])] = string(data[headerSize : headerSize+size])
+		data = data[headerSize+size:]
+	}
+	return frames
+}
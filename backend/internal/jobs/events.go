@@ -0,0 +1,106 @@
+package jobs
+
+import (
+	"time"
+
+	"github.com/josedelrio85/bndcmp_downloader/internal/model"
+)
+
+// eventHistory is how many events of a job are kept for the clients reconnecting.
+const eventHistory = 500
+
+// eventsPerMillisecond spaces the first event ids of two runs of the API started a
+// millisecond apart, see eventBase.
+const eventsPerMillisecond = 1_000_000
+
+type EventType string
+
+const (
+	// StateChanged carries the whole job, it is the first event of every job.
+	StateChanged    EventType = "state"
+	AlbumStarted    EventType = "album_started"
+	AlbumCompleted  EventType = "album_completed"
+	AlbumFailed     EventType = "album_failed"
+	TrackStarted    EventType = "track_started"
+	TrackProgress   EventType = "track_progress"
+	TrackDownloaded EventType = "track_downloaded"
+	TrackSkipped    EventType = "track_skipped"
+	TrackFailed     EventType = "track_failed"
+)
+
+// Event is something that happened while a job ran, numbered in the order they happened
+// within the job. The numbers keep growing across restarts of the API.
+type Event struct {
+	ID       int64     `json:"-"`
+	Type     EventType `json:"-"`
+	Job      *Job      `json:"job,omitempty"`
+	AlbumURL string    `json:"album_url,omitempty"`
+	// Album is the title of the album of Track.
+	Album string `json:"album,omitempty"`
+	Track string `json:"track,omitempty"`
+	// Written and Total are the bytes of the track saved so far and its size, -1 when unknown.
+	Written int64  `json:"written,omitempty"`
+	Total   int64  `json:"total,omitempty"`
+	Error   string `json:"error,omitempty"`
+}
+
+// eventLog keeps the latest events of a job and wakes up the clients following it.
+type eventLog struct {
+	events []Event
+	lastID int64
+	// changed is closed on every new event, and replaced unless the job is finished
+	changed chan struct{}
+}
+
+// eventBase is where the event ids of a run of the API start, after every id given by
+// the runs started before.
+func eventBase() int64 {
+	return time.Now().UnixMilli() * eventsPerMillisecond
+}
+
+func newEventLog(base int64) *eventLog {
+	return &eventLog{lastID: base, changed: make(chan struct{})}
+}
+
+func (e *eventLog) publish(event Event, finished bool) {
+	e.lastID++
+	event.ID = e.lastID
+	e.events = append(e.events, event)
+	if len(e.events) > eventHistory {
+		e.events = e.events[len(e.events)-eventHistory:]
+	}
+
+	close(e.changed)
+	if finished {
+		e.changed = nil
+	} else {
+		e.changed = make(chan struct{})
+	}
+}
+
+// after returns the events following the event id, and the channel closed on the next
+// one, nil once the job is finished. An id this log never gave, e.g. from before the
+// clock of the host was set back, gets every event.
+func (e *eventLog) after(id int64) ([]Event, <-chan struct{}) {
+	if id > e.lastID {
+		id = 0
+	}
+	var events []Event
+	for _, event := range e.events {
+		if event.ID > id {
+			events = append(events, event)
+		}
+	}
+	return events, e.changed
+}
+
+func trackEvent(eventType EventType, track *model.Track) Event {
+	return Event{Type: eventType, Album: trackAlbum(track), Track: track.Title}
+}
+
+func trackAlbum(track *model.Track) string {
+	if track.Album == nil {
+		return ""
+	}
+	return *track.Album
+}
@@ -0,0 +1,64 @@
+package jobs
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/suite"
+)
+
+type EventLogTestSuite struct {
+	suite.Suite
+	events *eventLog
+}
+
+func TestEventLogSuite(t *testing.T) {
+	suite.Run(t, new(EventLogTestSuite))
+}
+
+func (s *EventLogTestSuite) SetupTest() {
+	s.events = newEventLog(0)
+}
+
+func (s *EventLogTestSuite) TestPublish_WakesUpFollowers() {
+	_, changed := s.events.after(0)
+
+	s.events.publish(Event{Type: TrackStarted, Track: "Elbow"}, false)
+
+	s.Require().NotNil(changed)
+	_, open := <-changed
+	s.False(open, "followers waiting for new events should be woken up")
+	events, next := s.events.after(0)
+	s.Equal([]Event{{ID: 1, Type: TrackStarted, Track: "Elbow"}}, events)
+	s.NotNil(next)
+}
+
+func (s *EventLogTestSuite) TestPublish_Finished() {
+	s.events.publish(Event{Type: TrackStarted, Track: "Elbow"}, false)
+	s.events.publish(Event{Type: StateChanged}, true)
+
+	events, changed := s.events.after(1)
+
+	s.Equal([]Event{{ID: 2, Type: StateChanged}}, events)
+	s.Nil(changed, "there is nothing more to wait for once the job is finished")
+}
+
+func (s *EventLogTestSuite) TestPublish_History() {
+	for i := 0; i < eventHistory+10; i++ {
+		s.events.publish(Event{Type: TrackProgress}, false)
+	}
+
+	events, _ := s.events.after(0)
+
+	s.Require().Len(events, eventHistory)
+	s.Equal(int64(11), events[0].ID, "only the latest events are kept")
+	s.Equal(int64(eventHistory+10), events[len(events)-1].ID)
+}
+
+func (s *EventLogTestSuite) TestAfter_UnknownID() {
+	s.events = newEventLog(100)
+	s.events.publish(Event{Type: TrackStarted, Track: "Elbow"}, false)
+
+	events, _ := s.events.after(500)
+
+	s.Equal([]Event{{ID: 101, Type: TrackStarted, Track: "Elbow"}}, events, "an id the log never gave should get every event")
+}
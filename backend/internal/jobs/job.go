@@ -0,0 +1,89 @@
+package jobs
+
+import (
+	"slices"
+	"strconv"
+	"time"
+
+	"github.com/josedelrio85/bndcmp_downloader/internal/model"
+)
+
+type State string
+
+const (
+	Queued    State = "queued"
+	Running   State = "running"
+	Paused    State = "paused"
+	Completed State = "completed"
+	Failed    State = "failed"
+	Cancelled State = "cancelled"
+)
+
+// Job is the download of a Bandcamp discography, album or track in the background.
+type Job struct {
+	ID     string `json:"id"`
+	URL    string `json:"url"`
+	State  State  `json:"state"`
+	Tracks Counts `json:"tracks"`
+	// Failures lists the tracks and albums that could not be downloaded, Error why the
+	// job stopped or how many of them failed.
+	Failures   []Failure  `json:"failures"`
+	Error      string     `json:"error,omitempty"`
+	CreatedAt  time.Time  `json:"created_at"`
+	StartedAt  *time.Time `json:"started_at,omitempty"`
+	FinishedAt *time.Time `json:"finished_at,omitempty"`
+	Done       Done       `json:"-"`
+	// stopping is the state a running job was asked to stop in, Paused or Cancelled.
+	stopping State
+}
+
+// Done is what a job went through, so it resumes where it stopped after a restart.
+type Done struct {
+	// Albums are the URLs of the albums of a discography with all their tracks done.
+	Albums []string `json:"albums"`
+	// Tracks are the keys of the tracks downloaded or skipped, counted once.
+	Tracks []string `json:"tracks"`
+	// Current is the track being saved, the partial file of which a paused job keeps.
+	Current *model.Track `json:"current,omitempty"`
+}
+
+type Counts struct {
+	Downloaded int `json:"downloaded"`
+	// Skipped are the tracks already in the library and the ones Bandcamp does not stream.
+	Skipped int `json:"skipped"`
+	Failed  int `json:"failed"`
+}
+
+// Failure is a track that could not be downloaded, or an album or track page that
+// could not be read, of which only the URL is known.
+type Failure struct {
+	Track string `json:"track"`
+	URL   string `json:"url,omitempty"`
+	Error string `json:"error"`
+}
+
+// Finished tells whether the job is done running, successfully or not.
+func (j *Job) Finished() bool {
+	return j.State == Completed || j.State == Failed || j.State == Cancelled
+}
+
+// snapshot copies the job so it can be read while its worker updates it.
+func (j *Job) snapshot() Job {
+	copied := *j
+	copied.Failures = slices.Clone(j.Failures)
+	copied.Done.Albums = slices.Clone(j.Done.Albums)
+	copied.Done.Tracks = slices.Clone(j.Done.Tracks)
+	return copied
+}
+
+// trackKey identifies a track within a job, by its Bandcamp id when known.
+func trackKey(track *model.Track) string {
+	switch {
+	case track.TrackID != 0:
+		return strconv.FormatInt(track.TrackID, 10)
+	case track.URL != "":
+		return track.URL
+	default:
+		return track.Artist + "/" + track.Title
+	}
+}
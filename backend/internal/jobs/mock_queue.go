@@ -0,0 +1,179 @@
+// Code generated by MockGen. DO NOT EDIT.
+// Source: queue.go
+
+// Package jobs is a generated GoMock package.
+package jobs
+
+import (
+	url "net/url"
+	reflect "reflect"
+
+	gomock "github.com/golang/mock/gomock"
+	scrapper "github.com/josedelrio85/bndcmp_downloader/internal/scrapper"
+)
+
+// MockQueue is a mock of Queue interface.
+type MockQueue struct {
+	ctrl     *gomock.Controller
+	recorder *MockQueueMockRecorder
+}
+
+// MockQueueMockRecorder is the mock recorder for MockQueue.
+type MockQueueMockRecorder struct {
+	mock *MockQueue
+}
+
+// NewMockQueue creates a new mock instance.
+func NewMockQueue(ctrl *gomock.Controller) *MockQueue {
+	mock := &MockQueue{ctrl: ctrl}
+	mock.recorder = &MockQueueMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockQueue) EXPECT() *MockQueueMockRecorder {
+	return m.recorder
+}
+
+// Cancel mocks base method.
+func (m *MockQueue) Cancel(id string) (Job, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Cancel", id)
+	ret0, _ := ret[0].(Job)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Cancel indicates an expected call of Cancel.
+func (mr *MockQueueMockRecorder) Cancel(id interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Cancel", reflect.TypeOf((*MockQueue)(nil).Cancel), id)
+}
+
+// Enqueue mocks base method.
+func (m *MockQueue) Enqueue(resourceURL *url.URL) (Job, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Enqueue", resourceURL)
+	ret0, _ := ret[0].(Job)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Enqueue indicates an expected call of Enqueue.
+func (mr *MockQueueMockRecorder) Enqueue(resourceURL interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Enqueue", reflect.TypeOf((*MockQueue)(nil).Enqueue), resourceURL)
+}
+
+// Events mocks base method.
+func (m *MockQueue) Events(id string, after int64) ([]Event, <-chan struct{}, bool) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Events", id, after)
+	ret0, _ := ret[0].([]Event)
+	ret1, _ := ret[1].(<-chan struct{})
+	ret2, _ := ret[2].(bool)
+	return ret0, ret1, ret2
+}
+
+// Events indicates an expected call of Events.
+func (mr *MockQueueMockRecorder) Events(id, after interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Events", reflect.TypeOf((*MockQueue)(nil).Events), id, after)
+}
+
+// Get mocks base method.
+func (m *MockQueue) Get(id string) (Job, bool) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Get", id)
+	ret0, _ := ret[0].(Job)
+	ret1, _ := ret[1].(bool)
+	return ret0, ret1
+}
+
+// Get indicates an expected call of Get.
+func (mr *MockQueueMockRecorder) Get(id interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Get", reflect.TypeOf((*MockQueue)(nil).Get), id)
+}
+
+// List mocks base method.
+func (m *MockQueue) List() []Job {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "List")
+	ret0, _ := ret[0].([]Job)
+	return ret0
+}
+
+// List indicates an expected call of List.
+func (mr *MockQueueMockRecorder) List() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "List", reflect.TypeOf((*MockQueue)(nil).List))
+}
+
+// Pause mocks base method.
+func (m *MockQueue) Pause(id string) (Job, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Pause", id)
+	ret0, _ := ret[0].(Job)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Pause indicates an expected call of Pause.
+func (mr *MockQueueMockRecorder) Pause(id interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Pause", reflect.TypeOf((*MockQueue)(nil).Pause), id)
+}
+
+// Unpause mocks base method.
+func (m *MockQueue) Unpause(id string) (Job, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Unpause", id)
+	ret0, _ := ret[0].(Job)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Unpause indicates an expected call of Unpause.
+func (mr *MockQueueMockRecorder) Unpause(id interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Unpause", reflect.TypeOf((*MockQueue)(nil).Unpause), id)
+}
+
+// MockFactory is a mock of Factory interface.
+type MockFactory struct {
+	ctrl     *gomock.Controller
+	recorder *MockFactoryMockRecorder
+}
+
+// MockFactoryMockRecorder is the mock recorder for MockFactory.
+type MockFactoryMockRecorder struct {
+	mock *MockFactory
+}
+
+// NewMockFactory creates a new mock instance.
+func NewMockFactory(ctrl *gomock.Controller) *MockFactory {
+	mock := &MockFactory{ctrl: ctrl}
+	mock.recorder = &MockFactoryMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockFactory) EXPECT() *MockFactoryMockRecorder {
+	return m.recorder
+}
+
+// New mocks base method.
+func (m *MockFactory) New(resourceURL *url.URL, observer scrapper.Observer) (scrapper.Executer, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "New", resourceURL, observer)
+	ret0, _ := ret[0].(scrapper.Executer)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// New indicates an expected call of New.
+func (mr *MockFactoryMockRecorder) New(resourceURL, observer interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "New", reflect.TypeOf((*MockFactory)(nil).New), resourceURL, observer)
+}
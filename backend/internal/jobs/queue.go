@@ -0,0 +1,537 @@
+package jobs
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"net/url"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/josedelrio85/bndcmp_downloader/internal/model"
+	"github.com/josedelrio85/bndcmp_downloader/internal/scrapper"
+)
+
+var (
+	ErrQueueFull    = errors.New("too many jobs waiting, try again later")
+	ErrNotFound     = errors.New("job not found")
+	ErrInvalidState = errors.New("job cannot do that in its current state")
+)
+
+// errCancelled and errPaused stop a running job, telling its scrapper whether to keep
+// the partial file of the track it was saving.
+var (
+	errCancelled = fmt.Errorf("job cancelled: %w", scrapper.ErrCancelled)
+	errPaused    = fmt.Errorf("job paused: %w", scrapper.ErrPaused)
+)
+
+type QueueConfig struct {
+	// Workers is how many jobs run at the same time.
+	Workers int
+	// Size is how many jobs may wait for a worker.
+	Size int
+	// History is how many finished jobs are kept to be listed.
+	History int
+}
+
+func DefaultQueueConfig() QueueConfig {
+	return QueueConfig{
+		Workers: 1,
+		Size:    100,
+		History: 50,
+	}
+}
+
+//go:generate mockgen -source=$GOFILE -package=$GOPACKAGE -destination=mock_$GOFILE
+type Queue interface {
+	Enqueue(resourceURL *url.URL) (Job, error)
+	Get(id string) (Job, bool)
+	// List returns the jobs waiting, running and recently finished, newest first.
+	List() []Job
+	// Events returns the events of job id following the event after, and a channel closed
+	// once there are newer ones, nil when the job is finished.
+	Events(id string, after int64) ([]Event, <-chan struct{}, bool)
+	// Cancel stops a job for good, Pause until Unpause queues it again. A running job
+	// stops after its scrapper returns.
+	Cancel(id string) (Job, error)
+	Pause(id string) (Job, error)
+	Unpause(id string) (Job, error)
+}
+
+// Factory creates the scrapper of a job, reporting its tracks to observer.
+type Factory interface {
+	New(resourceURL *url.URL, observer scrapper.Observer) (scrapper.Executer, error)
+}
+
+// Manager runs the queued jobs with a pool of workers. Every change of a job is saved
+// in the store, so the unfinished ones can be resumed after a restart.
+type Manager struct {
+	factory Factory
+	store   Store
+	config  QueueConfig
+	pending chan string
+	mutex   sync.Mutex
+	jobs    map[string]*Job
+	events  map[string]*eventLog
+	// eventBase is where the event ids of the jobs start in this run
+	eventBase int64
+	// stops cancels the context of the running jobs
+	stops map[string]context.CancelCauseFunc
+	// order holds the job ids, oldest first
+	order []string
+}
+
+func NewManager(factory Factory, store Store, config QueueConfig) *Manager {
+	return &Manager{
+		factory:   factory,
+		store:     store,
+		config:    config,
+		pending:   make(chan string, config.Size),
+		jobs:      make(map[string]*Job),
+		events:    make(map[string]*eventLog),
+		eventBase: eventBase(),
+		stops:     make(map[string]context.CancelCauseFunc),
+	}
+}
+
+func (m *Manager) Enqueue(resourceURL *url.URL) (Job, error) {
+	job := &Job{
+		ID:        newID(),
+		URL:       resourceURL.String(),
+		State:     Queued,
+		Failures:  []Failure{},
+		CreatedAt: time.Now(),
+	}
+
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	select {
+	case m.pending <- job.ID:
+	default:
+		log.Printf("Manager -> Enqueue -> queue full, rejecting %s\n", job.URL)
+		return Job{}, ErrQueueFull
+	}
+	m.jobs[job.ID] = job
+	m.order = append(m.order, job.ID)
+	m.events[job.ID] = newEventLog(m.eventBase)
+	m.persist(job)
+	m.publishState(job)
+	log.Printf("Job %s queued for %s", job.ID, job.URL)
+	return job.snapshot(), nil
+}
+
+// Resume loads the stored jobs, queueing again the ones a restart interrupted. Paused
+// jobs stay paused. It is meant to be called once, before Run.
+func (m *Manager) Resume() error {
+	stored, err := m.store.Load()
+	if err != nil {
+		log.Printf("Manager -> Resume -> error loading jobs: %v\n", err)
+		return err
+	}
+	slices.SortFunc(stored, func(a, b Job) int {
+		return a.CreatedAt.Compare(b.CreatedAt)
+	})
+
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	for _, job := range stored {
+		m.jobs[job.ID] = &job
+		m.order = append(m.order, job.ID)
+		m.events[job.ID] = newEventLog(m.eventBase)
+		if job.Finished() || job.State == Paused {
+			m.publishState(&job)
+			continue
+		}
+
+		job.State = Queued
+		select {
+		case m.pending <- job.ID:
+			log.Printf("Job %s for %s resumed, %d albums and %d tracks already done", job.ID, job.URL, len(job.Done.Albums), len(job.Done.Tracks))
+		default:
+			now := time.Now()
+			job.State = Failed
+			job.Error = ErrQueueFull.Error()
+			job.FinishedAt = &now
+			log.Printf("Manager -> Resume -> queue full, not resuming job %s\n", job.ID)
+		}
+		m.persist(&job)
+		m.publishState(&job)
+	}
+	m.prune()
+	return nil
+}
+
+func (m *Manager) Get(id string) (Job, bool) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	job, ok := m.jobs[id]
+	if !ok {
+		return Job{}, false
+	}
+	return job.snapshot(), true
+}
+
+func (m *Manager) List() []Job {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	jobs := make([]Job, 0, len(m.order))
+	for i := len(m.order) - 1; i >= 0; i-- {
+		jobs = append(jobs, m.jobs[m.order[i]].snapshot())
+	}
+	return jobs
+}
+
+func (m *Manager) Events(id string, after int64) ([]Event, <-chan struct{}, bool) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	events, ok := m.events[id]
+	if !ok {
+		return nil, nil, false
+	}
+	eventList, changed := events.after(after)
+	return eventList, changed, true
+}
+
+func (m *Manager) Cancel(id string) (Job, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	job, ok := m.jobs[id]
+	if !ok {
+		return Job{}, ErrNotFound
+	}
+
+	switch job.State {
+	case Queued, Paused:
+		now := time.Now()
+		job.State = Cancelled
+		job.FinishedAt = &now
+		m.discard(job)
+		m.persist(job)
+		m.publishState(job)
+		log.Printf("Job %s cancelled", id)
+	case Running:
+		job.stopping = Cancelled
+		m.stops[id](errCancelled)
+		log.Printf("Job %s cancelling", id)
+	default:
+		return Job{}, ErrInvalidState
+	}
+	snapshot := job.snapshot()
+	m.prune()
+	return snapshot, nil
+}
+
+func (m *Manager) Pause(id string) (Job, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	job, ok := m.jobs[id]
+	if !ok {
+		return Job{}, ErrNotFound
+	}
+
+	switch job.State {
+	case Queued:
+		// its worker skips it when its turn comes
+		job.State = Paused
+		m.persist(job)
+		m.publishState(job)
+		log.Printf("Job %s paused", id)
+	case Running:
+		if job.stopping == Cancelled {
+			return Job{}, ErrInvalidState
+		}
+		job.stopping = Paused
+		m.stops[id](errPaused)
+		log.Printf("Job %s pausing", id)
+	default:
+		return Job{}, ErrInvalidState
+	}
+	return job.snapshot(), nil
+}
+
+// Unpause queues a paused job again, it goes on with the albums and tracks it did not do.
+func (m *Manager) Unpause(id string) (Job, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	job, ok := m.jobs[id]
+	if !ok {
+		return Job{}, ErrNotFound
+	}
+	if job.State != Paused {
+		return Job{}, ErrInvalidState
+	}
+
+	select {
+	case m.pending <- id:
+	default:
+		log.Printf("Manager -> Unpause -> queue full, job %s stays paused\n", id)
+		return Job{}, ErrQueueFull
+	}
+	job.State = Queued
+	m.persist(job)
+	m.publishState(job)
+	log.Printf("Job %s queued again", id)
+	return job.snapshot(), nil
+}
+
+// Run starts the workers and blocks until ctx is done.
+func (m *Manager) Run(ctx context.Context) {
+	var wg sync.WaitGroup
+	for i := 0; i < m.config.Workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			m.work(ctx)
+		}()
+	}
+	wg.Wait()
+}
+
+func (m *Manager) work(ctx context.Context) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case id := <-m.pending:
+			m.run(ctx, id)
+		}
+	}
+}
+
+func (m *Manager) run(ctx context.Context, id string) {
+	ctx, stop := context.WithCancelCause(ctx)
+	defer stop(nil)
+	rawURL, ok := m.start(id, stop)
+	if !ok {
+		return
+	}
+
+	resourceURL, err := url.Parse(rawURL)
+	if err == nil {
+		var executer scrapper.Executer
+		executer, err = m.factory.New(resourceURL, &jobObserver{manager: m, id: id})
+		if err == nil {
+			err = executer.Execute(ctx, resourceURL)
+		}
+	}
+	m.finish(id, err)
+}
+
+// start marks the job as running and returns its URL. Jobs paused or cancelled while
+// they waited are not started.
+func (m *Manager) start(id string, stop context.CancelCauseFunc) (string, bool) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	job, ok := m.jobs[id]
+	if !ok || job.State != Queued {
+		return "", false
+	}
+	m.stops[id] = stop
+	now := time.Now()
+	job.State = Running
+	if job.StartedAt == nil {
+		job.StartedAt = &now
+	}
+	m.persist(job)
+	m.publishState(job)
+	log.Printf("Job %s started", id)
+	return job.URL, true
+}
+
+// finish records how the job ended. A job asked to stop that still completed is
+// recorded as completed, a cancel asked after a pause wins over it.
+func (m *Manager) finish(id string, err error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	delete(m.stops, id)
+	job := m.jobs[id]
+	stopping := job.stopping
+	job.stopping = ""
+	now := time.Now()
+	switch {
+	case err == nil:
+		job.State = Completed
+		job.FinishedAt = &now
+		log.Printf("Job %s completed: %d tracks downloaded, %d skipped, %d failed", id, job.Tracks.Downloaded, job.Tracks.Skipped, job.Tracks.Failed)
+	case stopping == Cancelled:
+		job.State = Cancelled
+		job.FinishedAt = &now
+		log.Printf("Job %s cancelled", id)
+	case stopping == Paused:
+		job.State = Paused
+		log.Printf("Job %s paused", id)
+	default:
+		job.State = Failed
+		job.Error = err.Error()
+		job.FinishedAt = &now
+		log.Printf("Job %s failed: %v", id, err)
+	}
+	m.persist(job)
+	m.publishState(job)
+	m.prune()
+}
+
+// discard removes the partial file kept for the track job was saving when it was paused,
+// it will not be resumed.
+func (m *Manager) discard(job *Job) {
+	if job.Done.Current == nil {
+		return
+	}
+	if discarder, ok := m.factory.(scrapper.Discarder); ok {
+		discarder.Discard(job.Done.Current)
+	}
+	job.Done.Current = nil
+}
+
+// prune forgets the oldest finished jobs beyond the configured history.
+func (m *Manager) prune() {
+	finished := 0
+	for i := len(m.order) - 1; i >= 0; i-- {
+		if id := m.order[i]; m.jobs[id].Finished() {
+			finished++
+			if finished > m.config.History {
+				delete(m.jobs, id)
+				delete(m.events, id)
+				if err := m.store.Delete(id); err != nil {
+					log.Printf("Manager -> prune -> error deleting job %s: %v\n", id, err)
+				}
+			}
+		}
+	}
+	m.order = slices.DeleteFunc(m.order, func(id string) bool {
+		_, ok := m.jobs[id]
+		return !ok
+	})
+}
+
+// persist saves the job, a job that cannot be saved still runs.
+func (m *Manager) persist(job *Job) {
+	if err := m.store.Save(job.snapshot()); err != nil {
+		log.Printf("Manager -> persist -> error saving job %s: %v\n", job.ID, err)
+	}
+}
+
+// publishState tells the clients following the job about its new state.
+func (m *Manager) publishState(job *Job) {
+	snapshot := job.snapshot()
+	m.events[job.ID].publish(Event{Type: StateChanged, Job: &snapshot}, job.Finished())
+}
+
+// update changes the job id while it runs, telling the clients following it about event.
+func (m *Manager) update(id string, event Event, change func(job *Job)) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	job := m.jobs[id]
+	change(job)
+	m.persist(job)
+	m.events[id].publish(event, false)
+}
+
+// notify tells the clients following the job id about event, which does not change it.
+func (m *Manager) notify(id string, event Event) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	m.events[id].publish(event, false)
+}
+
+// jobObserver counts the tracks of a job and records the albums and tracks it is done
+// with. Tracks done before a restart are reported again by the resumed job, they are
+// only counted once. It also publishes the progress of the job as events.
+type jobObserver struct {
+	manager *Manager
+	id      string
+}
+
+func (o *jobObserver) TrackDownloaded(track *model.Track) {
+	o.manager.update(o.id, trackEvent(TrackDownloaded, track), func(job *Job) {
+		o.saved(job, track)
+		if o.done(job, track) {
+			job.Tracks.Downloaded++
+		}
+	})
+}
+
+func (o *jobObserver) TrackSkipped(track *model.Track) {
+	o.manager.update(o.id, trackEvent(TrackSkipped, track), func(job *Job) {
+		o.saved(job, track)
+		if o.done(job, track) {
+			job.Tracks.Skipped++
+		}
+	})
+}
+
+func (o *jobObserver) TrackFailed(track *model.Track, err error) {
+	event := trackEvent(TrackFailed, track)
+	event.Error = err.Error()
+	o.manager.update(o.id, event, func(job *Job) {
+		o.saved(job, track)
+		job.Tracks.Failed++
+		job.Failures = append(job.Failures, Failure{Track: track.Title, URL: track.URL, Error: err.Error()})
+	})
+}
+
+func (o *jobObserver) AlbumFailed(albumURL string, err error) {
+	o.manager.update(o.id, Event{Type: AlbumFailed, AlbumURL: albumURL, Error: err.Error()}, func(job *Job) {
+		job.Failures = append(job.Failures, Failure{URL: albumURL, Error: err.Error()})
+	})
+}
+
+func (o *jobObserver) AlbumCompleted(albumURL string) {
+	o.manager.update(o.id, Event{Type: AlbumCompleted, AlbumURL: albumURL}, func(job *Job) {
+		job.Done.Albums = append(job.Done.Albums, albumURL)
+	})
+}
+
+func (o *jobObserver) AlbumStarted(albumURL string) {
+	o.manager.notify(o.id, Event{Type: AlbumStarted, AlbumURL: albumURL})
+}
+
+// TrackStarted records the track being saved, so its partial file can be discarded
+// when the job is cancelled after a pause.
+func (o *jobObserver) TrackStarted(track *model.Track) {
+	o.manager.update(o.id, trackEvent(TrackStarted, track), func(job *Job) {
+		current := *track
+		current.Artwork = nil
+		job.Done.Current = &current
+	})
+}
+
+func (o *jobObserver) TrackProgress(track *model.Track, written int64, total int64) {
+	event := trackEvent(TrackProgress, track)
+	event.Written, event.Total = written, total
+	o.manager.notify(o.id, event)
+}
+
+// Completed tells the discography scrapper which albums it can skip.
+func (o *jobObserver) Completed(albumURL string) bool {
+	o.manager.mutex.Lock()
+	defer o.manager.mutex.Unlock()
+	return slices.Contains(o.manager.jobs[o.id].Done.Albums, albumURL)
+}
+
+// saved forgets track as the one being saved by job, once it is done with.
+func (o *jobObserver) saved(job *Job, track *model.Track) {
+	if job.Done.Current != nil && trackKey(job.Done.Current) == trackKey(track) {
+		job.Done.Current = nil
+	}
+}
+
+// done records track as done by job, telling whether it was not already.
+func (o *jobObserver) done(job *Job, track *model.Track) bool {
+	key := trackKey(track)
+	if slices.Contains(job.Done.Tracks, key) {
+		return false
+	}
+	job.Done.Tracks = append(job.Done.Tracks, key)
+	return true
+}
+
+func newID() string {
+	id := make([]byte, 8)
+	rand.Read(id)
+	return hex.EncodeToString(id)
+}
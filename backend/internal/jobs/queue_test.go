@@ -0,0 +1,537 @@
+package jobs
+
+import (
+	"context"
+	"errors"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	"github.com/josedelrio85/bndcmp_downloader/internal/model"
+	"github.com/josedelrio85/bndcmp_downloader/internal/scrapper"
+	"github.com/stretchr/testify/suite"
+)
+
+type ManagerTestSuite struct {
+	suite.Suite
+	ctrl        *gomock.Controller
+	mockFactory *MockFactory
+	manager     *Manager
+	albumURL    *url.URL
+}
+
+func TestManagerSuite(t *testing.T) {
+	suite.Run(t, new(ManagerTestSuite))
+}
+
+func (s *ManagerTestSuite) SetupTest() {
+	s.ctrl = gomock.NewController(s.T())
+	s.mockFactory = NewMockFactory(s.ctrl)
+	s.manager = NewManager(s.mockFactory, NewMemoryStore(), DefaultQueueConfig())
+	s.albumURL, _ = url.Parse("https://kinggizzard.bandcamp.com/album/12-bar-bruise")
+}
+
+// runUntilFinished runs the workers until the job id is done.
+func (s *ManagerTestSuite) runUntilFinished(id string) Job {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go s.manager.Run(ctx)
+
+	var job Job
+	s.Require().Eventually(func() bool {
+		job, _ = s.manager.Get(id)
+		return job.Finished()
+	}, time.Second, 5*time.Millisecond)
+	return job
+}
+
+// expectScrapper makes the factory return a scrapper running execute for resourceURL.
+func (s *ManagerTestSuite) expectScrapper(resourceURL *url.URL, execute func(ctx context.Context, observer scrapper.Observer) error) {
+	executer := scrapper.NewMockExecuter(s.ctrl)
+	s.mockFactory.EXPECT().New(resourceURL, gomock.Any()).DoAndReturn(func(resourceURL *url.URL, observer scrapper.Observer) (scrapper.Executer, error) {
+		executer.EXPECT().Execute(gomock.Any(), resourceURL).DoAndReturn(func(ctx context.Context, resourceURL *url.URL) error {
+			return execute(ctx, observer)
+		})
+		return executer, nil
+	})
+}
+
+func (s *ManagerTestSuite) TestEnqueue() {
+	job, err := s.manager.Enqueue(s.albumURL)
+
+	s.Require().NoError(err)
+	s.Len(job.ID, 16)
+	s.Equal(Queued, job.State)
+	s.Equal(s.albumURL.String(), job.URL)
+	s.NotNil(job.Failures)
+
+	stored, found := s.manager.Get(job.ID)
+	s.True(found)
+	s.Equal(job, stored)
+	_, found = s.manager.Get("missing")
+	s.False(found)
+}
+
+func (s *ManagerTestSuite) TestEnqueue_QueueFull() {
+	s.manager = NewManager(s.mockFactory, NewMemoryStore(), QueueConfig{Workers: 1, Size: 1, History: 1})
+	_, err := s.manager.Enqueue(s.albumURL)
+	s.Require().NoError(err)
+
+	_, err = s.manager.Enqueue(s.albumURL)
+
+	s.ErrorIs(err, ErrQueueFull)
+	s.Len(s.manager.List(), 1, "the rejected job should not be listed")
+}
+
+func (s *ManagerTestSuite) TestRun_Completed() {
+	s.expectScrapper(s.albumURL, func(ctx context.Context, observer scrapper.Observer) error {
+		observer.TrackDownloaded(&model.Track{Title: "Elbow"})
+		observer.TrackSkipped(&model.Track{Title: "Muckraker"})
+		observer.TrackDownloaded(&model.Track{Title: "Nein"})
+		return nil
+	})
+	job, err := s.manager.Enqueue(s.albumURL)
+	s.Require().NoError(err)
+
+	job = s.runUntilFinished(job.ID)
+
+	s.Equal(Completed, job.State)
+	s.Equal(Counts{Downloaded: 2, Skipped: 1}, job.Tracks)
+	s.Empty(job.Failures)
+	s.Empty(job.Error)
+	s.NotNil(job.StartedAt)
+	s.NotNil(job.FinishedAt)
+}
+
+func (s *ManagerTestSuite) TestRun_Failed() {
+	trackErr := errors.New("invalid MP3 stream")
+	s.expectScrapper(s.albumURL, func(ctx context.Context, observer scrapper.Observer) error {
+		observer.TrackDownloaded(&model.Track{Title: "Elbow"})
+		observer.TrackFailed(&model.Track{Title: "Muckraker"}, trackErr)
+		return trackErr
+	})
+	job, err := s.manager.Enqueue(s.albumURL)
+	s.Require().NoError(err)
+
+	job = s.runUntilFinished(job.ID)
+
+	s.Equal(Failed, job.State)
+	s.Equal(Counts{Downloaded: 1, Failed: 1}, job.Tracks)
+	s.Equal([]Failure{{Track: "Muckraker", Error: "invalid MP3 stream"}}, job.Failures)
+	s.Equal("invalid MP3 stream", job.Error)
+}
+
+func (s *ManagerTestSuite) TestRun_FailedAlbums() {
+	discographyURL, err := url.Parse("https://kinggizzard.bandcamp.com/music")
+	s.Require().NoError(err)
+	albumErr := errors.New("album page not found")
+	s.expectScrapper(discographyURL, func(ctx context.Context, observer scrapper.Observer) error {
+		observer.AlbumFailed("https://kinggizzard.bandcamp.com/album/gone", albumErr)
+		observer.TrackFailed(&model.Track{URL: "https://kinggizzard.bandcamp.com/track/gone"}, albumErr)
+		observer.AlbumCompleted("https://kinggizzard.bandcamp.com/album/12-bar-bruise")
+		return albumErr
+	})
+	job, err := s.manager.Enqueue(discographyURL)
+	s.Require().NoError(err)
+
+	job = s.runUntilFinished(job.ID)
+
+	s.Equal(Failed, job.State)
+	s.Equal(Counts{Failed: 1}, job.Tracks)
+	s.Equal([]Failure{
+		{URL: "https://kinggizzard.bandcamp.com/album/gone", Error: "album page not found"},
+		{URL: "https://kinggizzard.bandcamp.com/track/gone", Error: "album page not found"},
+	}, job.Failures)
+}
+
+func (s *ManagerTestSuite) TestRun_UnsupportedURL() {
+	merchURL, _ := url.Parse("https://kinggizzard.bandcamp.com/merch")
+	s.mockFactory.EXPECT().New(merchURL, gomock.Any()).Return(nil, scrapper.ErrUnsupportedURL)
+	job, err := s.manager.Enqueue(merchURL)
+	s.Require().NoError(err)
+
+	job = s.runUntilFinished(job.ID)
+
+	s.Equal(Failed, job.State)
+	s.Equal(scrapper.ErrUnsupportedURL.Error(), job.Error)
+}
+
+func (s *ManagerTestSuite) TestList() {
+	s.manager = NewManager(s.mockFactory, NewMemoryStore(), QueueConfig{Workers: 1, Size: 10, History: 1})
+	s.mockFactory.EXPECT().New(gomock.Any(), gomock.Any()).Return(nil, scrapper.ErrUnsupportedURL).Times(2)
+	first, err := s.manager.Enqueue(s.albumURL)
+	s.Require().NoError(err)
+	second, err := s.manager.Enqueue(s.albumURL)
+	s.Require().NoError(err)
+
+	s.runUntilFinished(second.ID)
+	third, err := s.manager.Enqueue(s.albumURL)
+	s.Require().NoError(err)
+
+	jobs := s.manager.List()
+	s.Require().Len(jobs, 2, "only the last finished job is kept")
+	s.Equal(third.ID, jobs[0].ID)
+	s.Equal(second.ID, jobs[1].ID)
+	_, found := s.manager.Get(first.ID)
+	s.False(found)
+}
+
+func (s *ManagerTestSuite) TestRun_Persisted() {
+	store := NewMemoryStore()
+	s.manager = NewManager(s.mockFactory, store, DefaultQueueConfig())
+	s.expectScrapper(s.albumURL, func(ctx context.Context, observer scrapper.Observer) error {
+		observer.TrackDownloaded(&model.Track{Title: "Elbow", TrackID: 1})
+		return nil
+	})
+	job, err := s.manager.Enqueue(s.albumURL)
+	s.Require().NoError(err)
+
+	stored, err := store.Load()
+	s.Require().NoError(err)
+	s.Require().Len(stored, 1)
+	s.Equal(Queued, stored[0].State)
+
+	s.runUntilFinished(job.ID)
+
+	stored, err = store.Load()
+	s.Require().NoError(err)
+	s.Require().Len(stored, 1)
+	s.Equal(Completed, stored[0].State)
+	s.Equal(Counts{Downloaded: 1}, stored[0].Tracks)
+	s.Equal([]string{"1"}, stored[0].Done.Tracks)
+}
+
+func (s *ManagerTestSuite) TestResume() {
+	discographyURL, _ := url.Parse("https://kinggizzard.bandcamp.com/music")
+	store := NewMemoryStore()
+	createdAt := time.Now().Add(-time.Hour)
+	interrupted := Job{
+		ID:        "interrupted",
+		URL:       discographyURL.String(),
+		State:     Running,
+		Tracks:    Counts{Downloaded: 2, Skipped: 1},
+		Failures:  []Failure{},
+		CreatedAt: createdAt,
+		StartedAt: &createdAt,
+		Done: Done{
+			Albums: []string{"https://kinggizzard.bandcamp.com/album/12-bar-bruise"},
+			Tracks: []string{"1", "2", "3"},
+		},
+	}
+	waiting := Job{ID: "waiting", URL: s.albumURL.String(), State: Queued, Failures: []Failure{}, CreatedAt: createdAt.Add(time.Minute)}
+	finished := Job{ID: "finished", URL: s.albumURL.String(), State: Completed, Failures: []Failure{}, CreatedAt: createdAt.Add(-time.Minute)}
+	for _, job := range []Job{interrupted, waiting, finished} {
+		s.Require().NoError(store.Save(job))
+	}
+	s.manager = NewManager(s.mockFactory, store, DefaultQueueConfig())
+
+	s.Require().NoError(s.manager.Resume())
+
+	jobs := s.manager.List()
+	s.Require().Len(jobs, 3)
+	s.Equal([]string{"waiting", "interrupted", "finished"}, []string{jobs[0].ID, jobs[1].ID, jobs[2].ID})
+	s.Equal(Queued, jobs[1].State)
+	s.Equal(Completed, jobs[2].State)
+
+	s.expectScrapper(discographyURL, func(ctx context.Context, observer scrapper.Observer) error {
+		checkpoint, ok := observer.(scrapper.Checkpoint)
+		s.Require().True(ok, "resumed jobs should tell which albums are done")
+		s.True(checkpoint.Completed("https://kinggizzard.bandcamp.com/album/12-bar-bruise"))
+		s.False(checkpoint.Completed("https://kinggizzard.bandcamp.com/album/willoughbys-beach"))
+		// the interrupted album is scraped again, the catalog skips its saved tracks
+		observer.TrackSkipped(&model.Track{TrackID: 3})
+		observer.TrackDownloaded(&model.Track{TrackID: 4})
+		observer.AlbumCompleted("https://kinggizzard.bandcamp.com/album/willoughbys-beach")
+		return nil
+	})
+	s.expectScrapper(s.albumURL, func(ctx context.Context, observer scrapper.Observer) error {
+		return nil
+	})
+
+	job := s.runUntilFinished("interrupted")
+	s.Equal(Completed, job.State)
+	s.Equal(Counts{Downloaded: 3, Skipped: 1}, job.Tracks, "tracks done before the restart should be counted once")
+	s.Equal(createdAt.Unix(), job.StartedAt.Unix())
+	s.runUntilFinished("waiting")
+
+	stored, err := store.Load()
+	s.Require().NoError(err)
+	for _, job := range stored {
+		if job.ID == "interrupted" {
+			s.Equal([]string{"https://kinggizzard.bandcamp.com/album/12-bar-bruise", "https://kinggizzard.bandcamp.com/album/willoughbys-beach"}, job.Done.Albums)
+		}
+	}
+}
+
+func (s *ManagerTestSuite) TestEvents() {
+	album := "12 Bar Bruise"
+	elbow := &model.Track{Title: "Elbow", Album: &album}
+	s.expectScrapper(s.albumURL, func(ctx context.Context, observer scrapper.Observer) error {
+		progress, ok := observer.(scrapper.Progress)
+		s.Require().True(ok, "jobs should publish their progress")
+		progress.AlbumStarted(s.albumURL.String())
+		progress.TrackStarted(elbow)
+		progress.TrackProgress(elbow, 1024, 2048)
+		observer.TrackDownloaded(elbow)
+		observer.TrackFailed(&model.Track{Title: "Muckraker", Album: &album}, errors.New("invalid MP3 stream"))
+		return nil
+	})
+	job, err := s.manager.Enqueue(s.albumURL)
+	s.Require().NoError(err)
+	events, changed, found := s.manager.Events(job.ID, 0)
+	s.Require().True(found)
+	s.Require().Len(events, 1)
+	s.Equal(Queued, events[0].Job.State)
+	s.NotNil(changed)
+
+	s.runUntilFinished(job.ID)
+	events, changed, _ = s.manager.Events(job.ID, 0)
+
+	types := []EventType{}
+	for i, event := range events {
+		s.Equal(events[0].ID+int64(i), event.ID)
+		types = append(types, event.Type)
+	}
+	s.Equal([]EventType{StateChanged, StateChanged, AlbumStarted, TrackStarted, TrackProgress, TrackDownloaded, TrackFailed, StateChanged}, types)
+	s.Equal(Event{ID: events[2].ID, Type: AlbumStarted, AlbumURL: s.albumURL.String()}, events[2])
+	s.Equal(Event{ID: events[4].ID, Type: TrackProgress, Album: album, Track: "Elbow", Written: 1024, Total: 2048}, events[4])
+	s.Equal("invalid MP3 stream", events[6].Error)
+	s.Equal(Completed, events[7].Job.State)
+	s.Nil(changed)
+
+	events, _, _ = s.manager.Events(job.ID, events[5].ID)
+	s.Len(events, 2, "a client reconnecting only gets the events it missed")
+	_, _, found = s.manager.Events("missing", 0)
+	s.False(found)
+}
+
+func (s *ManagerTestSuite) TestEvents_AcrossRestarts() {
+	store := NewMemoryStore()
+	s.manager = NewManager(s.mockFactory, store, DefaultQueueConfig())
+	s.manager.eventBase = eventBase() - time.Hour.Milliseconds()*eventsPerMillisecond
+	job, err := s.manager.Enqueue(s.albumURL)
+	s.Require().NoError(err)
+	before, _, _ := s.manager.Events(job.ID, 0)
+	lastEventID := before[len(before)-1].ID
+
+	s.manager = NewManager(s.mockFactory, store, DefaultQueueConfig())
+	s.Require().NoError(s.manager.Resume())
+	events, _, found := s.manager.Events(job.ID, lastEventID)
+
+	s.Require().True(found)
+	s.Require().Len(events, 1, "a client reconnecting after a restart should get the events of the new run")
+	s.Greater(events[0].ID, lastEventID)
+	s.Equal(Queued, events[0].Job.State)
+}
+
+// startBlocked runs the workers with a scrapper for the job of resourceURL that blocks
+// until it is stopped with cause, returning the id of the job once it runs.
+func (s *ManagerTestSuite) startBlocked(resourceURL *url.URL, cause error) string {
+	started := make(chan struct{})
+	s.expectScrapper(resourceURL, func(ctx context.Context, observer scrapper.Observer) error {
+		close(started)
+		<-ctx.Done()
+		s.ErrorIs(context.Cause(ctx), cause, "the scrapper should know why it was stopped")
+		return ctx.Err()
+	})
+	job, err := s.manager.Enqueue(resourceURL)
+	s.Require().NoError(err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	s.T().Cleanup(cancel)
+	go s.manager.Run(ctx)
+	<-started
+	return job.ID
+}
+
+func (s *ManagerTestSuite) TestCancel_Running() {
+	id := s.startBlocked(s.albumURL, scrapper.ErrCancelled)
+
+	job, err := s.manager.Cancel(id)
+
+	s.Require().NoError(err)
+	s.Equal(Running, job.State, "a running job stops once its scrapper returns")
+	s.Require().Eventually(func() bool {
+		job, _ = s.manager.Get(id)
+		return job.Finished()
+	}, time.Second, 5*time.Millisecond)
+	s.Equal(Cancelled, job.State)
+	s.Empty(job.Error)
+	s.NotNil(job.FinishedAt)
+}
+
+func (s *ManagerTestSuite) TestCancel_WhilePausing() {
+	started, release := make(chan struct{}), make(chan struct{})
+	s.expectScrapper(s.albumURL, func(ctx context.Context, observer scrapper.Observer) error {
+		close(started)
+		<-ctx.Done()
+		// still aborting the track in flight when the cancel comes
+		<-release
+		return ctx.Err()
+	})
+	job, err := s.manager.Enqueue(s.albumURL)
+	s.Require().NoError(err)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go s.manager.Run(ctx)
+	<-started
+
+	_, err = s.manager.Pause(job.ID)
+	s.Require().NoError(err)
+	_, err = s.manager.Cancel(job.ID)
+	s.Require().NoError(err)
+	_, err = s.manager.Pause(job.ID)
+	s.ErrorIs(err, ErrInvalidState, "a job being cancelled cannot be paused")
+	close(release)
+
+	s.Require().Eventually(func() bool {
+		job, _ = s.manager.Get(job.ID)
+		return job.Finished()
+	}, time.Second, 5*time.Millisecond)
+	s.Equal(Cancelled, job.State)
+}
+
+func (s *ManagerTestSuite) TestCancel_Queued() {
+	cancelled, err := s.manager.Enqueue(s.albumURL)
+	s.Require().NoError(err)
+
+	job, err := s.manager.Cancel(cancelled.ID)
+
+	s.Require().NoError(err)
+	s.Equal(Cancelled, job.State)
+	s.NotNil(job.FinishedAt)
+	discographyURL, _ := url.Parse("https://kinggizzard.bandcamp.com/music")
+	s.expectScrapper(discographyURL, func(ctx context.Context, observer scrapper.Observer) error {
+		return nil
+	})
+	next, err := s.manager.Enqueue(discographyURL)
+	s.Require().NoError(err)
+	s.runUntilFinished(next.ID)
+	job, _ = s.manager.Get(cancelled.ID)
+	s.Equal(Cancelled, job.State, "a cancelled job should not run")
+}
+
+func (s *ManagerTestSuite) TestCancel_Errors() {
+	_, err := s.manager.Cancel("missing")
+	s.ErrorIs(err, ErrNotFound)
+
+	s.mockFactory.EXPECT().New(s.albumURL, gomock.Any()).Return(nil, scrapper.ErrUnsupportedURL)
+	job, err := s.manager.Enqueue(s.albumURL)
+	s.Require().NoError(err)
+	s.runUntilFinished(job.ID)
+
+	_, err = s.manager.Cancel(job.ID)
+	s.ErrorIs(err, ErrInvalidState)
+	_, err = s.manager.Pause(job.ID)
+	s.ErrorIs(err, ErrInvalidState)
+	_, err = s.manager.Unpause(job.ID)
+	s.ErrorIs(err, ErrInvalidState)
+}
+
+func (s *ManagerTestSuite) TestPause_Running() {
+	id := s.startBlocked(s.albumURL, scrapper.ErrPaused)
+
+	job, err := s.manager.Pause(id)
+
+	s.Require().NoError(err)
+	s.Require().Eventually(func() bool {
+		job, _ = s.manager.Get(id)
+		return job.State == Paused
+	}, time.Second, 5*time.Millisecond)
+	s.Nil(job.FinishedAt)
+	s.Empty(job.Error)
+	_, changed, _ := s.manager.Events(id, 0)
+	s.NotNil(changed, "the events of a paused job should still be followed")
+
+	s.expectScrapper(s.albumURL, func(ctx context.Context, observer scrapper.Observer) error {
+		observer.TrackDownloaded(&model.Track{TrackID: 1})
+		return nil
+	})
+	job, err = s.manager.Unpause(id)
+
+	s.Require().NoError(err)
+	s.Equal(Queued, job.State)
+	s.Require().Eventually(func() bool {
+		job, _ = s.manager.Get(id)
+		return job.Finished()
+	}, time.Second, 5*time.Millisecond)
+	s.Equal(Completed, job.State)
+	s.Equal(Counts{Downloaded: 1}, job.Tracks)
+}
+
+func (s *ManagerTestSuite) TestPause_Queued() {
+	job, err := s.manager.Enqueue(s.albumURL)
+	s.Require().NoError(err)
+
+	job, err = s.manager.Pause(job.ID)
+
+	s.Require().NoError(err)
+	s.Equal(Paused, job.State)
+	_, err = s.manager.Pause(job.ID)
+	s.ErrorIs(err, ErrInvalidState)
+
+	job, err = s.manager.Cancel(job.ID)
+
+	s.Require().NoError(err)
+	s.Equal(Cancelled, job.State)
+}
+
+func (s *ManagerTestSuite) TestCancel_PausedDiscardsPartial() {
+	discarder := scrapper.NewMockDiscarder(s.ctrl)
+	store := NewMemoryStore()
+	s.manager = NewManager(struct {
+		*MockFactory
+		*scrapper.MockDiscarder
+	}{s.mockFactory, discarder}, store, DefaultQueueConfig())
+	elbow := &model.Track{TrackID: 1, Title: "Elbow", Artwork: []byte("artwork")}
+	started := make(chan struct{})
+	s.expectScrapper(s.albumURL, func(ctx context.Context, observer scrapper.Observer) error {
+		observer.(scrapper.Progress).TrackStarted(&model.Track{TrackID: 2, Title: "Muckraker"})
+		observer.TrackDownloaded(&model.Track{TrackID: 2, Title: "Muckraker"})
+		observer.(scrapper.Progress).TrackStarted(elbow)
+		close(started)
+		<-ctx.Done()
+		return ctx.Err()
+	})
+	job, err := s.manager.Enqueue(s.albumURL)
+	s.Require().NoError(err)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go s.manager.Run(ctx)
+	<-started
+	_, err = s.manager.Pause(job.ID)
+	s.Require().NoError(err)
+	s.Require().Eventually(func() bool {
+		job, _ = s.manager.Get(job.ID)
+		return job.State == Paused
+	}, time.Second, 5*time.Millisecond)
+	stored, err := store.Load()
+	s.Require().NoError(err)
+	s.Require().NotNil(stored[0].Done.Current, "the track being saved should survive a restart")
+	s.Equal("Elbow", stored[0].Done.Current.Title)
+	s.Nil(stored[0].Done.Current.Artwork)
+
+	discarder.EXPECT().Discard(gomock.Any()).Do(func(track *model.Track) {
+		s.Equal(int64(1), track.TrackID)
+	})
+	job, err = s.manager.Cancel(job.ID)
+
+	s.Require().NoError(err)
+	s.Equal(Cancelled, job.State)
+	s.Nil(job.Done.Current)
+}
+
+func (s *ManagerTestSuite) TestResume_Paused() {
+	store := NewMemoryStore()
+	paused := Job{ID: "paused", URL: s.albumURL.String(), State: Paused, Failures: []Failure{}, CreatedAt: time.Now()}
+	s.Require().NoError(store.Save(paused))
+	s.manager = NewManager(s.mockFactory, store, DefaultQueueConfig())
+
+	s.Require().NoError(s.manager.Resume())
+
+	job, found := s.manager.Get("paused")
+	s.Require().True(found)
+	s.Equal(Paused, job.State, "paused jobs should wait to be resumed from the API")
+	s.Empty(s.manager.pending)
+}
@@ -0,0 +1,123 @@
+package jobs
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+var jobsBucket = []byte("jobs")
+
+// Store persists the jobs of a Manager.
+type Store interface {
+	Save(job Job) error
+	Delete(id string) error
+	// Load returns every stored job.
+	Load() ([]Job, error)
+}
+
+// storedJob keeps what a job went through along with it, which the API does not show.
+type storedJob struct {
+	Job
+	Done Done `json:"done"`
+}
+
+// BoltStore keeps the jobs in a bbolt database, so they survive a restart of the API.
+type BoltStore struct {
+	db *bolt.DB
+}
+
+func NewBoltStore(dbPath string) (*BoltStore, error) {
+	db, err := bolt.Open(dbPath, 0600, &bolt.Options{Timeout: time.Second})
+	if err != nil {
+		return nil, fmt.Errorf("opening job store %s: %w", dbPath, err)
+	}
+
+	err = db.Update(func(tx *bolt.Tx) error {
+		_, err := tx.CreateBucketIfNotExists(jobsBucket)
+		return err
+	})
+	if err != nil {
+		db.Close()
+		return nil, fmt.Errorf("initializing job store %s: %w", dbPath, err)
+	}
+	return &BoltStore{db: db}, nil
+}
+
+func (b *BoltStore) Close() error {
+	return b.db.Close()
+}
+
+func (b *BoltStore) Save(job Job) error {
+	data, err := json.Marshal(storedJob{Job: job, Done: job.Done})
+	if err != nil {
+		return err
+	}
+	return b.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(jobsBucket).Put([]byte(job.ID), data)
+	})
+}
+
+func (b *BoltStore) Delete(id string) error {
+	return b.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(jobsBucket).Delete([]byte(id))
+	})
+}
+
+func (b *BoltStore) Load() ([]Job, error) {
+	var jobs []Job
+	err := b.db.View(func(tx *bolt.Tx) error {
+		return tx.Bucket(jobsBucket).ForEach(func(key []byte, data []byte) error {
+			var stored storedJob
+			if err := json.Unmarshal(data, &stored); err != nil {
+				log.Printf("BoltStore -> Load -> error decoding job %s: %v\n", key, err)
+				return nil
+			}
+			stored.Job.Done = stored.Done
+			jobs = append(jobs, stored.Job)
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	return jobs, nil
+}
+
+// MemoryStore keeps the jobs until the API stops, for when the job store cannot be opened.
+type MemoryStore struct {
+	mutex sync.Mutex
+	jobs  map[string]Job
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{jobs: make(map[string]Job)}
+}
+
+func (m *MemoryStore) Save(job Job) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	m.jobs[job.ID] = job.snapshot()
+	return nil
+}
+
+func (m *MemoryStore) Delete(id string) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	delete(m.jobs, id)
+	return nil
+}
+
+func (m *MemoryStore) Load() ([]Job, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	jobs := make([]Job, 0, len(m.jobs))
+	for _, job := range m.jobs {
+		jobs = append(jobs, job.snapshot())
+	}
+	return jobs, nil
+}
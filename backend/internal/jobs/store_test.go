@@ -0,0 +1,74 @@
+package jobs
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/suite"
+)
+
+type BoltStoreTestSuite struct {
+	suite.Suite
+	dbPath string
+	store  *BoltStore
+}
+
+func TestBoltStoreSuite(t *testing.T) {
+	suite.Run(t, new(BoltStoreTestSuite))
+}
+
+func (s *BoltStoreTestSuite) SetupTest() {
+	s.dbPath = filepath.Join(s.T().TempDir(), ".jobs.db")
+	store, err := NewBoltStore(s.dbPath)
+	s.Require().NoError(err)
+	s.store = store
+}
+
+func (s *BoltStoreTestSuite) TearDownTest() {
+	s.store.Close()
+}
+
+func (s *BoltStoreTestSuite) TestSave_PersistsAcrossRestarts() {
+	createdAt := time.Date(2024, 10, 10, 12, 0, 0, 0, time.UTC)
+	job := Job{
+		ID:        "0123456789abcdef",
+		URL:       "https://kinggizzard.bandcamp.com/music",
+		State:     Running,
+		Tracks:    Counts{Downloaded: 1, Failed: 1},
+		Failures:  []Failure{{Track: "Elbow", Error: "invalid MP3 stream"}},
+		CreatedAt: createdAt,
+		StartedAt: &createdAt,
+		Done:      Done{Albums: []string{"https://kinggizzard.bandcamp.com/album/12-bar-bruise"}, Tracks: []string{"3749823254"}},
+	}
+	s.Require().NoError(s.store.Save(job))
+	s.Require().NoError(s.store.Close())
+
+	store, err := NewBoltStore(s.dbPath)
+	s.Require().NoError(err)
+	s.store = store
+	jobs, err := s.store.Load()
+
+	s.Require().NoError(err)
+	s.Require().Len(jobs, 1)
+	s.Equal(job, jobs[0], "what the job went through is kept too")
+}
+
+func (s *BoltStoreTestSuite) TestDelete() {
+	s.Require().NoError(s.store.Save(Job{ID: "first"}))
+	s.Require().NoError(s.store.Save(Job{ID: "second"}))
+
+	s.Require().NoError(s.store.Delete("first"))
+
+	jobs, err := s.store.Load()
+	s.Require().NoError(err)
+	s.Require().Len(jobs, 1)
+	s.Equal("second", jobs[0].ID)
+	s.NoError(s.store.Delete("missing"))
+}
+
+func (s *BoltStoreTestSuite) TestNewBoltStore_Locked() {
+	_, err := NewBoltStore(s.dbPath)
+
+	s.Error(err, "the store is held by the first API process")
+}
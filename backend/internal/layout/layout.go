@@ -0,0 +1,218 @@
+package layout
+
+import (
+	"errors"
+	"fmt"
+	"path"
+	"strconv"
+	"strings"
+
+	"github.com/josedelrio85/bndcmp_downloader/internal/model"
+	"github.com/josedelrio85/bndcmp_downloader/internal/sanitize"
+)
+
+// Default is the historical Artist/Album/NN - Title.mp3 layout.
+const Default = "{artist}/{album}/{track:02} - {title}.{ext}"
+
+// Presets are the layouts selectable by name instead of a template.
+var Presets = map[string]string{
+	"default": Default,
+	// Plex groups by album artist and matches albums by "Album (Year)" folders
+	"plex": "{album_artist}/{album} ({year})/{track:02} - {title}.{ext}",
+	// Navidrome reads the tags, the folders only have to be stable and sortable
+	"navidrome": "{album_artist}/{year} - {album}/{track:02} - {title}.{ext}",
+}
+
+const extension = "mp3"
+
+var (
+	ErrEmptyTemplate = errors.New("layout: empty template")
+	ErrMissingTitle  = errors.New("layout: template must contain {title}")
+)
+
+// Template renders the sanitized library path of a track, e.g. "{artist}/{year} - {album}/{track:02} - {title}.{ext}".
+// Directories whose fields are all empty, like {album} for standalone tracks, are left out.
+type Template struct {
+	pattern  string
+	segments [][]token
+}
+
+type token struct {
+	literal string
+	field   string
+	width   int
+}
+
+// Load returns the preset named value or, when there is none, parses value as a template.
+func Load(value string) (*Template, error) {
+	if preset, ok := Presets[strings.ToLower(strings.TrimSpace(value))]; ok {
+		return Parse(preset)
+	}
+	return Parse(value)
+}
+
+func MustParse(pattern string) *Template {
+	template, err := Parse(pattern)
+	if err != nil {
+		panic(err)
+	}
+	return template
+}
+
+func Parse(pattern string) (*Template, error) {
+	pattern = strings.Trim(strings.TrimSpace(pattern), "/")
+	if pattern == "" {
+		return nil, ErrEmptyTemplate
+	}
+
+	template := &Template{pattern: pattern}
+	hasTitle := false
+	for _, segment := range strings.Split(pattern, "/") {
+		tokens, err := parseSegment(segment)
+		if err != nil {
+			return nil, err
+		}
+		if len(tokens) == 0 {
+			return nil, fmt.Errorf("layout: empty folder in %q", pattern)
+		}
+		for _, token := range tokens {
+			hasTitle = hasTitle || token.field == "title"
+		}
+		template.segments = append(template.segments, tokens)
+	}
+	if !hasTitle {
+		return nil, ErrMissingTitle
+	}
+	return template, nil
+}
+
+func parseSegment(segment string) ([]token, error) {
+	var tokens []token
+	for segment != "" {
+		start := strings.IndexByte(segment, '{')
+		if start < 0 {
+			tokens = append(tokens, token{literal: segment})
+			break
+		}
+		if start > 0 {
+			tokens = append(tokens, token{literal: segment[:start]})
+		}
+		end := strings.IndexByte(segment[start:], '}')
+		if end < 0 {
+			return nil, fmt.Errorf("layout: unclosed placeholder in %q", segment)
+		}
+
+		field, err := parseField(segment[start+1 : start+end])
+		if err != nil {
+			return nil, err
+		}
+		tokens = append(tokens, field)
+		segment = segment[start+end+1:]
+	}
+	return tokens, nil
+}
+
+func parseField(placeholder string) (token, error) {
+	name, format, hasFormat := strings.Cut(placeholder, ":")
+	if _, ok := fields[name]; !ok {
+		return token{}, fmt.Errorf("layout: unknown placeholder {%s}", placeholder)
+	}
+	if !hasFormat {
+		return token{field: name}, nil
+	}
+	if !numericFields[name] {
+		return token{}, fmt.Errorf("layout: {%s} does not take a width", name)
+	}
+	width, err := strconv.Atoi(format)
+	if err != nil || width < 0 {
+		return token{}, fmt.Errorf("layout: invalid width in {%s}", placeholder)
+	}
+	return token{field: name, width: width}, nil
+}
+
+var fields = map[string]func(*model.Track) string{
+	"artist": func(track *model.Track) string { return track.Artist },
+	"album_artist": func(track *model.Track) string {
+		if track.AlbumArtist != "" {
+			return track.AlbumArtist
+		}
+		return track.Artist
+	},
+	"album": func(track *model.Track) string {
+		if track.Album == nil {
+			return ""
+		}
+		return *track.Album
+	},
+	"title":       func(track *model.Track) string { return track.Title },
+	"track":       func(track *model.Track) string { return strconv.FormatInt(track.TrackNumber, 10) },
+	"track_total": func(track *model.Track) string { return formatPositive(track.TrackTotal) },
+	"year":        func(track *model.Track) string { return formatPositive(int64(track.ReleaseYear)) },
+	"ext":         func(track *model.Track) string { return extension },
+}
+
+var numericFields = map[string]bool{"track": true, "track_total": true, "year": true}
+
+func formatPositive(value int64) string {
+	if value <= 0 {
+		return ""
+	}
+	return strconv.FormatInt(value, 10)
+}
+
+func (t *Template) String() string {
+	return t.pattern
+}
+
+// Path returns the slash separated path of the track relative to the library folder.
+func (t *Template) Path(track *model.Track) string {
+	last := len(t.segments) - 1
+	parts := make([]string, 0, len(t.segments))
+	for i, tokens := range t.segments {
+		rendered, empty := render(tokens, track, i == last)
+		if empty && i != last {
+			continue
+		}
+		parts = append(parts, rendered)
+	}
+	return strings.Join(parts, "/")
+}
+
+// Dir returns the folder of the track relative to the library folder, "." when it has none.
+func (t *Template) Dir(track *model.Track) string {
+	return path.Dir(t.Path(track))
+}
+
+// render fills a path segment and reports whether all of its fields were empty. The
+// file name is kept short enough for the saver to append its suffixes.
+func render(tokens []token, track *model.Track, file bool) (string, bool) {
+	var builder strings.Builder
+	empty := true
+	for _, token := range tokens {
+		if token.field == "" {
+			builder.WriteString(token.literal)
+			continue
+		}
+		value := fields[token.field](track)
+		if value != "" {
+			empty = false
+			if token.width > 0 && len(value) < token.width {
+				value = strings.Repeat("0", token.width-len(value)) + value
+			}
+		}
+		builder.WriteString(value)
+	}
+	// a value must never add folders to the layout, sanitize.Name takes care of "/" too
+	if file {
+		return sanitize.FileName(cleanSegment(builder.String())), empty
+	}
+	return sanitize.Name(cleanSegment(builder.String())), empty
+}
+
+// cleanSegment removes what is left of optional fields, e.g. "Album ()" or " - Album".
+func cleanSegment(segment string) string {
+	segment = strings.ReplaceAll(segment, "()", "")
+	segment = strings.ReplaceAll(segment, "[]", "")
+	segment = strings.Join(strings.Fields(segment), " ")
+	return strings.Trim(segment, " -_")
+}
@@ -0,0 +1,102 @@
+package layout
+
+import (
+	"testing"
+
+	"github.com/josedelrio85/bndcmp_downloader/internal/model"
+	"github.com/stretchr/testify/suite"
+)
+
+func TestLayout(t *testing.T) {
+	suite.Run(t, new(TestLayoutSuite))
+}
+
+type TestLayoutSuite struct {
+	suite.Suite
+	albumTrack      *model.Track
+	standaloneTrack *model.Track
+}
+
+func (s *TestLayoutSuite) SetupTest() {
+	album := "12 Bar Bruise"
+	s.albumTrack = &model.Track{
+		Title:       "Elbow",
+		TrackNumber: 1,
+		Artist:      "King Gizzard & The Lizard Wizard",
+		Album:       &album,
+		AlbumArtist: "King Gizzard & The Lizard Wizard",
+		TrackTotal:  12,
+		ReleaseYear: 2012,
+	}
+	s.standaloneTrack = &model.Track{
+		Title:       "Single",
+		TrackNumber: 1,
+		Artist:      "AC/DC",
+	}
+}
+
+func (s *TestLayoutSuite) TestPath() {
+	tests := []struct {
+		name     string
+		pattern  string
+		track    *model.Track
+		expected string
+	}{
+		{"Default", Default, s.albumTrack, "King Gizzard & The Lizard Wizard/12 Bar Bruise/01 - Elbow.mp3"},
+		{"Default standalone track", Default, s.standaloneTrack, "AC-DC/01 - Single.mp3"},
+		{"Plex", Presets["plex"], s.albumTrack, "King Gizzard & The Lizard Wizard/12 Bar Bruise (2012)/01 - Elbow.mp3"},
+		{"Plex standalone track", Presets["plex"], s.standaloneTrack, "AC-DC/01 - Single.mp3"},
+		{"Navidrome", Presets["navidrome"], s.albumTrack, "King Gizzard & The Lizard Wizard/2012 - 12 Bar Bruise/01 - Elbow.mp3"},
+		{"Track total", "{album}/{track:03} of {track_total} {title}.{ext}", s.albumTrack, "12 Bar Bruise/001 of 12 Elbow.mp3"},
+		{"Sanitized", Default, &model.Track{Title: "What?: Part 1.", TrackNumber: 2, Artist: "Who*"}, "Who/02 - What - Part 1..mp3"},
+		{"Flat", "{artist} - {title}.{ext}", s.albumTrack, "King Gizzard & The Lizard Wizard - Elbow.mp3"},
+	}
+
+	for _, tt := range tests {
+		s.Run(tt.name, func() {
+			template, err := Parse(tt.pattern)
+			s.Require().NoError(err)
+			s.Equal(tt.expected, template.Path(tt.track))
+		})
+	}
+}
+
+func (s *TestLayoutSuite) TestDir() {
+	s.Equal("King Gizzard & The Lizard Wizard/12 Bar Bruise", MustParse(Default).Dir(s.albumTrack))
+	s.Equal(".", MustParse("{title}.{ext}").Dir(s.albumTrack))
+}
+
+func (s *TestLayoutSuite) TestParse_Errors() {
+	tests := []struct {
+		name    string
+		pattern string
+	}{
+		{"Empty", "  "},
+		{"Missing title", "{artist}/{album}.{ext}"},
+		{"Unknown placeholder", "{artist}/{genre}/{title}.{ext}"},
+		{"Unclosed placeholder", "{artist}/{title.{ext}"},
+		{"Width on text field", "{artist:02}/{title}.{ext}"},
+		{"Invalid width", "{track:xx} - {title}.{ext}"},
+		{"Empty folder", "{artist}//{title}.{ext}"},
+	}
+
+	for _, tt := range tests {
+		s.Run(tt.name, func() {
+			_, err := Parse(tt.pattern)
+			s.Error(err)
+		})
+	}
+}
+
+func (s *TestLayoutSuite) TestLoad() {
+	template, err := Load("Plex")
+	s.NoError(err)
+	s.Equal(Presets["plex"], template.String())
+
+	template, err = Load("{artist}/{title}.{ext}")
+	s.NoError(err)
+	s.Equal("{artist}/{title}.{ext}", template.String())
+
+	_, err = Load("unknown")
+	s.ErrorIs(err, ErrMissingTitle)
+}
@@ -5,6 +5,22 @@ type Track struct {
 	TrackNumber int64
 	Artist      string
 	Album       *string
+	AlbumArtist string
+	TrackTotal  int64
+	ReleaseYear int
+	ISRC        string
+	ArtID       int64
+	// TrackID and AlbumID are the Bandcamp item ids, AlbumID is 0 for standalone tracks.
+	TrackID     int64
+	AlbumID     int64
+	Artwork     []byte
 	URL         string
 	DownloadURL string
+	// Checksum is the SHA-256 of the saved file, hex encoded, set by the saver.
+	Checksum string
+	// Duration is the length of the track in seconds as Bandcamp reports it.
+	Duration float64
+	// ArtworkFetched is set once the cover was looked for, e.g. by the album of the track,
+	// so a track whose cover could not be fetched does not try again.
+	ArtworkFetched bool
 }
@@ -0,0 +1,237 @@
+package mp3
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"github.com/josedelrio85/bndcmp_downloader/internal/id3"
+)
+
+const headerSize = 4
+
+var (
+	ErrNoFrames  = errors.New("mp3: no MPEG audio frames")
+	ErrTruncated = errors.New("mp3: stream is truncated")
+)
+
+// trailingTags start the tags that may follow the last frame: ID3v1 and APEv2 ("APETAGEX").
+var trailingTags = [][]byte{[]byte("TAG"), []byte("APET")}
+
+const (
+	mpeg25 = 0
+	mpeg2  = 2
+	mpeg1  = 3
+
+	layer3 = 1
+	layer2 = 2
+	layer1 = 3
+)
+
+// bitrates in kbps by layer and index, MPEG 2.5 shares the MPEG 2 ones.
+var (
+	mpeg1Bitrates = map[int][15]int{
+		layer1: {0, 32, 64, 96, 128, 160, 192, 224, 256, 288, 320, 352, 384, 416, 448},
+		layer2: {0, 32, 48, 56, 64, 80, 96, 112, 128, 160, 192, 224, 256, 320, 384},
+		layer3: {0, 32, 40, 48, 56, 64, 80, 96, 112, 128, 160, 192, 224, 256, 320},
+	}
+	mpeg2Bitrates = map[int][15]int{
+		layer1: {0, 32, 48, 56, 64, 80, 96, 112, 128, 144, 160, 176, 192, 224, 256},
+		layer2: {0, 8, 16, 24, 32, 40, 48, 56, 64, 80, 96, 112, 128, 144, 160},
+		layer3: {0, 8, 16, 24, 32, 40, 48, 56, 64, 80, 96, 112, 128, 144, 160},
+	}
+)
+
+var sampleRates = map[int][3]int{
+	mpeg1:  {44100, 48000, 32000},
+	mpeg2:  {22050, 24000, 16000},
+	mpeg25: {11025, 12000, 8000},
+}
+
+// Stream describes the MPEG audio frames of a file.
+type Stream struct {
+	Frames   int
+	Duration time.Duration
+	// Skipped counts the bytes between frames that are not audio.
+	Skipped int64
+}
+
+type frame struct {
+	version    int
+	layer      int
+	sampleRate int
+	samples    int
+	length     int
+}
+
+// ReadFile reads the MPEG stream of the file at path.
+func ReadFile(path string) (*Stream, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	return Read(file)
+}
+
+// Read walks the frame headers of an MPEG audio stream, after its ID3v2 tag if any.
+// A stream whose last frame is cut short is truncated.
+func Read(r io.Reader) (*Stream, error) {
+	reader := bufio.NewReaderSize(r, 8192)
+	if err := skipTag(reader); err != nil {
+		return nil, err
+	}
+
+	stream := &Stream{}
+	synced := false
+	for {
+		header, _ := reader.Peek(headerSize)
+		if len(header) < headerSize {
+			stream.Skipped += int64(len(header))
+			break
+		}
+		if trailingTag(header) {
+			break
+		}
+
+		current, ok := parseHeader(header)
+		if ok && !synced {
+			// out of sync, a frame only counts when another one follows it
+			ok = confirmed(reader, current)
+		}
+		if !ok {
+			reader.Discard(1)
+			stream.Skipped++
+			synced = false
+			continue
+		}
+
+		if n, _ := reader.Discard(current.length); n < current.length {
+			return nil, fmt.Errorf("%w: frame %d has %d of %d bytes", ErrTruncated, stream.Frames+1, n, current.length)
+		}
+		stream.Frames++
+		stream.Duration += time.Duration(current.samples) * time.Second / time.Duration(current.sampleRate)
+		synced = true
+	}
+
+	if stream.Frames == 0 {
+		return nil, ErrNoFrames
+	}
+	return stream, nil
+}
+
+func skipTag(reader *bufio.Reader) error {
+	header, _ := reader.Peek(10)
+	tagSize, err := id3.TagSize(bytes.NewReader(header))
+	if err != nil {
+		return err
+	}
+	if n, _ := reader.Discard(int(tagSize)); int64(n) < tagSize {
+		return fmt.Errorf("%w: ID3 tag has %d of %d bytes", ErrTruncated, n, tagSize)
+	}
+	return nil
+}
+
+func parseHeader(header []byte) (frame, bool) {
+	if header[0] != 0xFF || header[1]&0xE0 != 0xE0 {
+		return frame{}, false
+	}
+	version := int(header[1]>>3) & 0x03
+	layer := int(header[1]>>1) & 0x03
+	bitrateIndex := int(header[2] >> 4)
+	sampleRateIndex := int(header[2]>>2) & 0x03
+	padding := int(header[2]>>1) & 0x01
+	// reserved values, and free format bitrates which frames do not tell the length of
+	if version == 1 || layer == 0 || bitrateIndex == 0 || bitrateIndex == 15 || sampleRateIndex == 3 {
+		return frame{}, false
+	}
+
+	f := frame{
+		version:    version,
+		layer:      layer,
+		sampleRate: sampleRates[version][sampleRateIndex],
+	}
+	bitrate := mpeg2Bitrates[layer][bitrateIndex] * 1000
+	if version == mpeg1 {
+		bitrate = mpeg1Bitrates[layer][bitrateIndex] * 1000
+	}
+	switch {
+	case layer == layer1:
+		f.samples = 384
+		f.length = (12*bitrate/f.sampleRate + padding) * 4
+	case layer == layer3 && version != mpeg1:
+		f.samples = 576
+		f.length = 72*bitrate/f.sampleRate + padding
+	default:
+		f.samples = 1152
+		f.length = 144*bitrate/f.sampleRate + padding
+	}
+	return f, true
+}
+
+// confirmed tells whether f is followed by another frame of the same stream, the end of
+// the stream or a trailing tag, so random bytes looking like a header are skipped.
+func confirmed(reader *bufio.Reader, f frame) bool {
+	data, _ := reader.Peek(f.length + headerSize)
+	if len(data) <= f.length {
+		// the stream ends within or right after this frame
+		return true
+	}
+	next := data[f.length:]
+	if len(next) < headerSize {
+		return false
+	}
+	if trailingTag(next) {
+		return true
+	}
+	following, ok := parseHeader(next)
+	return ok && following.version == f.version && following.layer == f.layer && following.sampleRate == f.sampleRate
+}
+
+func trailingTag(data []byte) bool {
+	for _, tag := range trailingTags {
+		if bytes.HasPrefix(data, tag) {
+			return true
+		}
+	}
+	return false
+}
+
+// Writer reads the MPEG stream written to it, so a file is checked while it is saved
+// instead of read again afterwards.
+type Writer struct {
+	pipe   *io.PipeWriter
+	result chan result
+}
+
+type result struct {
+	stream *Stream
+	err    error
+}
+
+func NewWriter() *Writer {
+	reader, writer := io.Pipe()
+	w := &Writer{pipe: writer, result: make(chan result, 1)}
+	go func() {
+		stream, err := Read(reader)
+		// an invalid stream still takes everything written to it
+		io.Copy(io.Discard, reader)
+		w.result <- result{stream: stream, err: err}
+	}()
+	return w
+}
+
+func (w *Writer) Write(p []byte) (int, error) {
+	return w.pipe.Write(p)
+}
+
+// Finish ends the stream and returns what Read found in it.
+func (w *Writer) Finish() (*Stream, error) {
+	w.pipe.Close()
+	r := <-w.result
+	return r.stream, r.err
+}
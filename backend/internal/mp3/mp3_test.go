@@ -0,0 +1,175 @@
+package mp3
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/josedelrio85/bndcmp_downloader/internal/id3"
+	"github.com/josedelrio85/bndcmp_downloader/internal/mp3/mp3test"
+	"github.com/stretchr/testify/suite"
+)
+
+type MP3TestSuite struct {
+	suite.Suite
+}
+
+func TestMP3Suite(t *testing.T) {
+	suite.Run(t, new(MP3TestSuite))
+}
+
+const frameDuration = 26122448 * time.Nanosecond
+
+func (s *MP3TestSuite) TestRead() {
+	tag, err := (&id3.Tag{Title: "Elbow"}).Bytes()
+	s.Require().NoError(err)
+	id3v1 := append([]byte("TAG"), make([]byte, 125)...)
+
+	tests := []struct {
+		name     string
+		data     []byte
+		expected Stream
+	}{
+		{
+			name:     "Frames only",
+			data:     mp3test.Frames(100),
+			expected: Stream{Frames: 100, Duration: 100 * frameDuration},
+		},
+		{
+			name:     "Tagged",
+			data:     append(append(append([]byte(nil), tag...), mp3test.Frames(10)...), id3v1...),
+			expected: Stream{Frames: 10, Duration: 10 * frameDuration},
+		},
+		{
+			name:     "Junk before the first frame",
+			data:     append([]byte{0x00, 0xFF, 0xFB}, mp3test.Frames(3)...),
+			expected: Stream{Frames: 3, Duration: 3 * frameDuration, Skipped: 3},
+		},
+		{
+			name:     "Single frame",
+			data:     mp3test.Frames(1),
+			expected: Stream{Frames: 1, Duration: frameDuration},
+		},
+	}
+
+	for _, tt := range tests {
+		s.Run(tt.name, func() {
+			stream, err := Read(bytes.NewReader(tt.data))
+
+			s.Require().NoError(err)
+			s.Equal(tt.expected, *stream)
+		})
+	}
+}
+
+func (s *MP3TestSuite) TestRead_Invalid() {
+	tests := []struct {
+		name     string
+		data     []byte
+		expected error
+	}{
+		{name: "Empty", data: nil, expected: ErrNoFrames},
+		{name: "HTML error page", data: []byte("<html><body>403 Forbidden</body></html>"), expected: ErrNoFrames},
+		{name: "Last frame cut short", data: mp3test.Frames(10)[:417*10-100], expected: ErrTruncated},
+		{name: "ID3 tag cut short", data: []byte{'I', 'D', '3', 4, 0, 0, 0, 0, 0x10, 0, 'x'}, expected: ErrTruncated},
+		{name: "ID3 tag without audio", data: []byte{'I', 'D', '3', 4, 0, 0, 0, 0, 0, 1, 'x'}, expected: ErrNoFrames},
+	}
+
+	for _, tt := range tests {
+		s.Run(tt.name, func() {
+			_, err := Read(bytes.NewReader(tt.data))
+
+			s.ErrorIs(err, tt.expected)
+		})
+	}
+}
+
+func (s *MP3TestSuite) TestReadFile() {
+	path := filepath.Join(s.T().TempDir(), "track.mp3")
+	s.Require().NoError(os.WriteFile(path, mp3test.Frames(5), 0644))
+
+	stream, err := ReadFile(path)
+
+	s.NoError(err)
+	s.Equal(5, stream.Frames)
+
+	_, err = ReadFile(filepath.Join(s.T().TempDir(), "missing.mp3"))
+	s.True(os.IsNotExist(err))
+}
+
+func (s *MP3TestSuite) TestWriter() {
+	tests := []struct {
+		name     string
+		data     []byte
+		frames   int
+		expected error
+	}{
+		{name: "Frames", data: mp3test.Frames(5), frames: 5},
+		{name: "Last frame cut short", data: mp3test.Frames(5)[:417*5-100], expected: ErrTruncated},
+		{name: "HTML error page", data: []byte("<html><body>403 Forbidden</body></html>"), expected: ErrNoFrames},
+		{name: "Nothing written", expected: ErrNoFrames},
+	}
+
+	for _, tt := range tests {
+		s.Run(tt.name, func() {
+			w := NewWriter()
+			// written in small chunks, like a download
+			for data := tt.data; len(data) > 0; data = data[min(len(data), 100):] {
+				n, err := w.Write(data[:min(len(data), 100)])
+				s.Require().NoError(err)
+				s.Equal(min(len(data), 100), n)
+			}
+
+			stream, err := w.Finish()
+
+			s.ErrorIs(err, tt.expected)
+			if tt.expected == nil {
+				s.Equal(tt.frames, stream.Frames)
+			}
+		})
+	}
+}
+
+func (s *MP3TestSuite) TestWriter_InvalidTag() {
+	w := NewWriter()
+
+	_, err := w.Write(append([]byte{'I', 'D', '3', 4, 0, 0, 0, 0, 0x10, 0, 'x'}, "rest of the download"...))
+	s.NoError(err, "the stream is read to the end even when it is invalid")
+	_, err = w.Finish()
+
+	s.ErrorIs(err, ErrTruncated)
+}
+
+func (s *MP3TestSuite) Test_parseHeader() {
+	tests := []struct {
+		name    string
+		header  []byte
+		ok      bool
+		samples int
+		length  int
+	}{
+		{name: "MPEG 1 Layer III", header: []byte{0xFF, 0xFB, 0x90, 0x00}, ok: true, samples: 1152, length: 417},
+		{name: "MPEG 1 Layer III padded", header: []byte{0xFF, 0xFB, 0x92, 0x00}, ok: true, samples: 1152, length: 418},
+		{name: "MPEG 2 Layer III", header: []byte{0xFF, 0xF3, 0x80, 0x00}, ok: true, samples: 576, length: 208},
+		{name: "MPEG 1 Layer I", header: []byte{0xFF, 0xFF, 0xC0, 0x00}, ok: true, samples: 384, length: 416},
+		{name: "MPEG 1 Layer II", header: []byte{0xFF, 0xFD, 0x90, 0x00}, ok: true, samples: 1152, length: 522},
+		{name: "Reserved version", header: []byte{0xFF, 0xEB, 0x90, 0x00}},
+		{name: "Bad bitrate", header: []byte{0xFF, 0xFB, 0xF0, 0x00}},
+		{name: "Free format bitrate", header: []byte{0xFF, 0xFB, 0x00, 0x00}},
+		{name: "Reserved sample rate", header: []byte{0xFF, 0xFB, 0x9C, 0x00}},
+		{name: "No sync", header: []byte(strings.Repeat("a", 4))},
+	}
+
+	for _, tt := range tests {
+		s.Run(tt.name, func() {
+			f, ok := parseHeader(tt.header)
+
+			s.Equal(tt.ok, ok)
+			s.Equal(tt.samples, f.samples)
+			s.Equal(tt.length, f.length)
+		})
+	}
+}
@@ -0,0 +1,11 @@
+// Package mp3test builds MPEG audio for the tests of the packages reading or saving tracks.
+package mp3test
+
+import "bytes"
+
+// Frames builds n MPEG 1 Layer III frames of 128 kbps at 44.1 kHz, 417 bytes each.
+func Frames(n int) []byte {
+	frame := make([]byte, 417)
+	copy(frame, []byte{0xFF, 0xFB, 0x90, 0x00})
+	return bytes.Repeat(frame, n)
+}
@@ -0,0 +1,97 @@
+package retriever
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+var (
+	ErrNotFound          = errors.New("resource not found")
+	ErrGone              = errors.New("resource gone")
+	ErrForbidden         = errors.New("access forbidden")
+	ErrRateLimited       = errors.New("rate limited")
+	ErrServerError       = errors.New("remote server error")
+	ErrUnexpectedStatus  = errors.New("unexpected status")
+	ErrUnexpectedContent = errors.New("unexpected content type")
+)
+
+// StatusError is returned when the server answers with a non-2xx status code.
+// It unwraps to one of the sentinel errors above so callers can use errors.Is.
+type StatusError struct {
+	URL        string
+	StatusCode int
+	RetryAfter time.Duration
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("unexpected status %d %s retrieving %s", e.StatusCode, http.StatusText(e.StatusCode), e.URL)
+}
+
+func (e *StatusError) Unwrap() error {
+	switch {
+	case e.StatusCode == http.StatusNotFound:
+		return ErrNotFound
+	case e.StatusCode == http.StatusGone:
+		return ErrGone
+	case e.StatusCode == http.StatusUnauthorized, e.StatusCode == http.StatusForbidden:
+		return ErrForbidden
+	case e.StatusCode == http.StatusTooManyRequests:
+		return ErrRateLimited
+	case e.StatusCode >= 500:
+		return ErrServerError
+	default:
+		return ErrUnexpectedStatus
+	}
+}
+
+// ContentTypeError is returned when the response media type is not the expected one,
+// e.g. an HTML error page served where an MP3 stream was requested.
+type ContentTypeError struct {
+	URL      string
+	Expected string
+	Actual   string
+}
+
+func (e *ContentTypeError) Error() string {
+	return fmt.Sprintf("unexpected content type %q retrieving %s, expected %q", e.Actual, e.URL, e.Expected)
+}
+
+func (e *ContentTypeError) Unwrap() error {
+	return ErrUnexpectedContent
+}
+
+// IsExpired reports whether err is how the CDN rejects a signed URL past its expiry,
+// retrying it is pointless but a freshly signed URL may work.
+func IsExpired(err error) bool {
+	return errors.Is(err, ErrForbidden) || errors.Is(err, ErrGone)
+}
+
+func newStatusError(url string, response *http.Response) *StatusError {
+	return &StatusError{
+		URL:        url,
+		StatusCode: response.StatusCode,
+		RetryAfter: parseRetryAfter(response.Header.Get("Retry-After"), time.Now()),
+	}
+}
+
+// parseRetryAfter accepts both forms allowed by RFC 9110: delay in seconds or an HTTP date.
+func parseRetryAfter(value string, now time.Time) time.Duration {
+	if value == "" {
+		return 0
+	}
+	if seconds, err := strconv.Atoi(value); err == nil {
+		if seconds < 0 {
+			return 0
+		}
+		return time.Duration(seconds) * time.Second
+	}
+	if date, err := http.ParseTime(value); err == nil {
+		if delay := date.Sub(now); delay > 0 {
+			return delay
+		}
+	}
+	return 0
+}
@@ -1,21 +1,131 @@
 package retriever
 
 import (
+	"context"
+	"fmt"
 	"io"
+	"mime"
+	"net"
 	"net/http"
+	"strconv"
+	"strings"
+	"time"
 )
 
 type HttpClient struct {
+	client *http.Client
 }
 
 func NewHttpClient() *HttpClient {
-	return &HttpClient{}
+	// No overall client timeout: MP3 downloads can legitimately take minutes,
+	// cancellation is driven by the caller's context instead.
+	transport := &http.Transport{
+		Proxy: http.ProxyFromEnvironment,
+		DialContext: (&net.Dialer{
+			Timeout:   30 * time.Second,
+			KeepAlive: 30 * time.Second,
+		}).DialContext,
+		TLSHandshakeTimeout:   10 * time.Second,
+		ResponseHeaderTimeout: 30 * time.Second,
+		IdleConnTimeout:       90 * time.Second,
+		MaxIdleConns:          100,
+	}
+	return &HttpClient{
+		client: &http.Client{Transport: transport},
+	}
+}
+
+// Retrieve fetches url and returns the open response. Non-2xx responses are turned
+// into a *StatusError; when accept is set, the response media type must match it.
+// The body is only left open on success, in which case the caller must close it.
+func (h *HttpClient) Retrieve(ctx context.Context, url string, accept string) (*Response, error) {
+	return h.RetrieveRange(ctx, url, accept, 0, "")
 }
 
-func (h *HttpClient) Retrieve(url string) (io.Reader, error) {
-	response, err := http.Get(url)
+// RetrieveRange asks for url from byte offset on, guarded by validator as If-Range.
+// The server answers 206 with the rest of the resource when it supports ranges and
+// the validator still matches, otherwise 200 with the whole resource; Response.Offset
+// tells both apart. An unsatisfiable range is retried as a full request.
+func (h *HttpClient) RetrieveRange(ctx context.Context, url string, accept string, offset int64, validator string) (*Response, error) {
+	request, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
 	if err != nil {
 		return nil, err
 	}
-	return response.Body, nil
+	if accept != "" {
+		request.Header.Set("Accept", accept)
+	}
+	if offset > 0 {
+		request.Header.Set("Range", fmt.Sprintf("bytes=%d-", offset))
+		if validator != "" {
+			request.Header.Set("If-Range", validator)
+		}
+	}
+
+	response, err := h.client.Do(request)
+	if err != nil {
+		return nil, err
+	}
+
+	if offset > 0 && response.StatusCode == http.StatusRequestedRangeNotSatisfiable {
+		io.Copy(io.Discard, io.LimitReader(response.Body, 4096))
+		response.Body.Close()
+		return h.RetrieveRange(ctx, url, accept, 0, "")
+	}
+
+	if response.StatusCode < 200 || response.StatusCode > 299 {
+		// drain so the connection can be reused
+		io.Copy(io.Discard, io.LimitReader(response.Body, 4096))
+		response.Body.Close()
+		return nil, newStatusError(url, response)
+	}
+
+	if contentType := response.Header.Get("Content-Type"); !matchContentType(contentType, accept) {
+		response.Body.Close()
+		return nil, &ContentTypeError{URL: url, Expected: accept, Actual: contentType}
+	}
+
+	result := newResponse(response)
+	if response.StatusCode == http.StatusPartialContent {
+		start, ok := parseContentRangeStart(response.Header.Get("Content-Range"))
+		if !ok || offset == 0 {
+			response.Body.Close()
+			return nil, fmt.Errorf("%w: unexpected Content-Range %q", ErrUnexpectedContent, response.Header.Get("Content-Range"))
+		}
+		result.Offset = start
+	}
+	return result, nil
+}
+
+// parseContentRangeStart reads the first byte position of "bytes start-end/total".
+func parseContentRangeStart(contentRange string) (int64, bool) {
+	unit, byteRange, found := strings.Cut(contentRange, " ")
+	if !found || unit != "bytes" {
+		return 0, false
+	}
+	start, _, found := strings.Cut(byteRange, "-")
+	if !found {
+		return 0, false
+	}
+	value, err := strconv.ParseInt(start, 10, 64)
+	if err != nil || value < 0 {
+		return 0, false
+	}
+	return value, true
+}
+
+// matchContentType reports whether the Content-Type header satisfies the expected
+// media type. Wildcards like "audio/*" are supported; a missing header is accepted
+// because some CDNs omit it.
+func matchContentType(contentType string, accept string) bool {
+	if accept == "" || contentType == "" {
+		return true
+	}
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return false
+	}
+	if strings.HasSuffix(accept, "/*") {
+		return strings.HasPrefix(mediaType, strings.TrimSuffix(accept, "*"))
+	}
+	return strings.EqualFold(mediaType, accept)
 }
@@ -1,10 +1,13 @@
 package retriever
 
 import (
+	"context"
 	"io"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/suite"
 )
@@ -34,7 +37,7 @@ func (hc *TestHttpClientSuite) TestHttpClient_Retrieve() {
 			name:           "Server error",
 			serverResponse: "Internal Server Error",
 			serverStatus:   http.StatusInternalServerError,
-			expectedError:  false, // The method doesn't check for non-200 status codes
+			expectedError:  true,
 		},
 		{
 			name:           "Empty response",
@@ -57,14 +60,16 @@ func (hc *TestHttpClientSuite) TestHttpClient_Retrieve() {
 			client := NewHttpClient()
 
 			// Call the Retrieve method
-			reader, err := client.Retrieve(server.URL)
+			reader, err := client.Retrieve(context.Background(), server.URL, "")
 
 			if tt.expectedError {
 				hc.Error(err)
-			}
-
-			hc.NoError(err)
-			if err == nil {
+				var statusErr *StatusError
+				hc.ErrorAs(err, &statusErr)
+				hc.Equal(tt.serverStatus, statusErr.StatusCode)
+				hc.Nil(reader)
+			} else {
+				hc.NoError(err)
 				hc.NotNil(reader)
 
 				body, err := io.ReadAll(reader)
@@ -79,7 +84,7 @@ func (hc *TestHttpClientSuite) TestHttpClient_Retrieve() {
 func (hc *TestHttpClientSuite) TestHttpClient_Retrieve_InvalidURL() {
 	client := NewHttpClient()
 
-	_, err := client.Retrieve("://invalid-url")
+	_, err := client.Retrieve(context.Background(), "://invalid-url", "")
 
 	hc.Error(err)
 	hc.Contains(err.Error(), "invalid-url")
@@ -88,7 +93,230 @@ func (hc *TestHttpClientSuite) TestHttpClient_Retrieve_InvalidURL() {
 func (hc *TestHttpClientSuite) TestHttpClient_Retrieve_NetworkError() {
 	client := NewHttpClient()
 
-	_, err := client.Retrieve("http://non-existent-server.com")
+	_, err := client.Retrieve(context.Background(), "http://non-existent-server.com", "")
 
 	hc.Error(err)
 }
+
+func (hc *TestHttpClientSuite) TestHttpClient_Retrieve_ContextCancelled() {
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release
+	}))
+	defer server.Close()
+	defer close(release)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	client := NewHttpClient()
+
+	_, err := client.Retrieve(ctx, server.URL, "")
+
+	hc.Error(err)
+	hc.ErrorIs(err, context.DeadlineExceeded)
+}
+
+func (hc *TestHttpClientSuite) TestHttpClient_Retrieve_StatusErrors() {
+	tests := []struct {
+		name          string
+		serverStatus  int
+		expectedError error
+	}{
+		{"Not found", http.StatusNotFound, ErrNotFound},
+		{"Gone", http.StatusGone, ErrGone},
+		{"Forbidden", http.StatusForbidden, ErrForbidden},
+		{"Rate limited", http.StatusTooManyRequests, ErrRateLimited},
+		{"Server error", http.StatusBadGateway, ErrServerError},
+		{"Other client error", http.StatusTeapot, ErrUnexpectedStatus},
+	}
+
+	for _, tt := range tests {
+		hc.Run(tt.name, func() {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(tt.serverStatus)
+				w.Write([]byte("<html>error page</html>"))
+			}))
+			defer server.Close()
+
+			client := NewHttpClient()
+
+			reader, err := client.Retrieve(context.Background(), server.URL, "text/html")
+
+			hc.Nil(reader)
+			hc.ErrorIs(err, tt.expectedError)
+		})
+	}
+}
+
+func (hc *TestHttpClientSuite) TestHttpClient_Retrieve_ContentType() {
+	tests := []struct {
+		name          string
+		contentType   string
+		accept        string
+		expectedError bool
+	}{
+		{"No expectation", "text/html; charset=utf-8", "", false},
+		{"Matching HTML", "text/html; charset=utf-8", "text/html", false},
+		{"Matching MP3", "audio/mpeg", "audio/mpeg", false},
+		{"Wildcard", "audio/mpeg", "audio/*", false},
+		{"HTML instead of MP3", "text/html; charset=utf-8", "audio/mpeg", true},
+		{"Wildcard mismatch", "text/html", "audio/*", true},
+		{"Malformed header", "/;", "audio/mpeg", true},
+	}
+
+	for _, tt := range tests {
+		hc.Run(tt.name, func() {
+			var acceptHeader string
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				acceptHeader = r.Header.Get("Accept")
+				w.Header().Set("Content-Type", tt.contentType)
+				w.Write([]byte("content"))
+			}))
+			defer server.Close()
+
+			client := NewHttpClient()
+
+			reader, err := client.Retrieve(context.Background(), server.URL, tt.accept)
+
+			hc.Equal(tt.accept, acceptHeader)
+			if tt.expectedError {
+				hc.Nil(reader)
+				hc.ErrorIs(err, ErrUnexpectedContent)
+				var contentTypeErr *ContentTypeError
+				hc.ErrorAs(err, &contentTypeErr)
+				hc.Equal(tt.contentType, contentTypeErr.Actual)
+			} else {
+				hc.NoError(err)
+				hc.NotNil(reader)
+			}
+		})
+	}
+}
+
+func (hc *TestHttpClientSuite) TestHttpClient_RetrieveRange() {
+	content := strings.NewReader("0123456789")
+	modified := time.Date(2024, 10, 10, 12, 0, 0, 0, time.UTC)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("ETag", `"v1"`)
+		http.ServeContent(w, r, "track.mp3", modified, content)
+	}))
+	defer server.Close()
+
+	tests := []struct {
+		name           string
+		offset         int64
+		validator      string
+		expectedOffset int64
+		expectedBody   string
+	}{
+		{"Full request", 0, "", 0, "0123456789"},
+		{"Resumed with matching validator", 4, `"v1"`, 4, "456789"},
+		{"Restarted when the validator changed", 4, `"v0"`, 0, "0123456789"},
+		{"Restarted when the range is not satisfiable", 20, `"v1"`, 0, "0123456789"},
+	}
+
+	client := NewHttpClient()
+	for _, tt := range tests {
+		hc.Run(tt.name, func() {
+			response, err := client.RetrieveRange(context.Background(), server.URL, "", tt.offset, tt.validator)
+			hc.Require().NoError(err)
+			defer response.Close()
+
+			body, err := io.ReadAll(response)
+			hc.NoError(err)
+			hc.Equal(tt.expectedOffset, response.Offset)
+			hc.Equal(tt.expectedBody, string(body))
+			hc.Equal(`"v1"`, response.Validator())
+		})
+	}
+}
+
+func (hc *TestHttpClientSuite) TestResponse_Validator() {
+	hc.Equal(`"strong"`, (&Response{ETag: `"strong"`, LastModified: "Thu, 10 Oct 2024 12:00:00 GMT"}).Validator())
+	hc.Equal("Thu, 10 Oct 2024 12:00:00 GMT", (&Response{ETag: `W/"weak"`, LastModified: "Thu, 10 Oct 2024 12:00:00 GMT"}).Validator())
+	hc.Empty((&Response{ETag: `W/"weak"`}).Validator())
+}
+
+func (hc *TestHttpClientSuite) Test_parseContentRangeStart() {
+	tests := []struct {
+		value    string
+		expected int64
+		ok       bool
+	}{
+		{"bytes 4-9/10", 4, true},
+		{"bytes 0-9/*", 0, true},
+		{"bytes */10", 0, false},
+		{"items 4-9/10", 0, false},
+		{"", 0, false},
+	}
+
+	for _, tt := range tests {
+		hc.Run(tt.value, func() {
+			start, ok := parseContentRangeStart(tt.value)
+			hc.Equal(tt.expected, start)
+			hc.Equal(tt.ok, ok)
+		})
+	}
+}
+
+func (hc *TestHttpClientSuite) TestHttpClient_Retrieve_ClosesBodyOnError() {
+	tests := []struct {
+		name         string
+		serverStatus int
+		contentType  string
+		accept       string
+		expectOpen   bool
+	}{
+		{"Success leaves body to the caller", http.StatusOK, "audio/mpeg", "audio/mpeg", true},
+		{"Status error", http.StatusNotFound, "text/html", "text/html", false},
+		{"Content type error", http.StatusOK, "text/html", "audio/mpeg", false},
+	}
+
+	for _, tt := range tests {
+		hc.Run(tt.name, func() {
+			body := &trackingBody{Reader: strings.NewReader("content")}
+			client := NewHttpClient()
+			client.client.Transport = roundTripFunc(func(r *http.Request) (*http.Response, error) {
+				return &http.Response{
+					StatusCode:    tt.serverStatus,
+					Header:        http.Header{"Content-Type": []string{tt.contentType}, "Etag": []string{`"abc"`}},
+					Body:          body,
+					ContentLength: 7,
+					Request:       r,
+				}, nil
+			})
+
+			response, err := client.Retrieve(context.Background(), "http://example.com", tt.accept)
+
+			if tt.expectOpen {
+				hc.NoError(err)
+				hc.False(body.closed)
+				hc.Equal(int64(7), response.ContentLength)
+				hc.Equal(`"abc"`, response.ETag)
+				hc.Equal(tt.contentType, response.ContentType)
+				hc.NoError(response.Close())
+			} else {
+				hc.Error(err)
+				hc.Nil(response)
+			}
+			hc.True(body.closed, "body should be closed")
+		})
+	}
+}
+
+type roundTripFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripFunc) RoundTrip(r *http.Request) (*http.Response, error) {
+	return f(r)
+}
+
+type trackingBody struct {
+	*strings.Reader
+	closed bool
+}
+
+func (t *trackingBody) Close() error {
+	t.closed = true
+	return nil
+}
@@ -0,0 +1,222 @@
+package retriever
+
+import (
+	"context"
+	"io"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// RateLimit is a token bucket: RequestsPerSecond tokens are refilled every second
+// up to Burst. A zero RequestsPerSecond disables limiting.
+type RateLimit struct {
+	RequestsPerSecond float64
+	Burst             int
+}
+
+type RateLimitConfig struct {
+	// Default applies to every host without a specific rule.
+	Default RateLimit
+	// Hosts maps a domain to its limit. A rule for "bandcamp.com" also matches
+	// artist subdomains; each host still gets its own bucket.
+	Hosts map[string]RateLimit
+	// MaxConcurrent caps the number of open connections across all hosts, 0 means unlimited.
+	MaxConcurrent int
+}
+
+func DefaultRateLimitConfig() RateLimitConfig {
+	return RateLimitConfig{
+		Default: RateLimit{RequestsPerSecond: 1, Burst: 2},
+		Hosts: map[string]RateLimit{
+			"bandcamp.com": {RequestsPerSecond: 1, Burst: 2},
+			"bcbits.com":   {RequestsPerSecond: 2, Burst: 4},
+		},
+		MaxConcurrent: 4,
+	}
+}
+
+// RateLimitedClient throttles the wrapped retriever per host and bounds the number
+// of concurrent connections. A connection slot is held until the response is closed.
+type RateLimitedClient struct {
+	retriever retriever
+	config    RateLimitConfig
+	slots     chan struct{}
+	mutex     sync.Mutex
+	buckets   map[string]*tokenBucket
+	now       func() time.Time
+	sleep     func(context.Context, time.Duration) error
+}
+
+func NewRateLimitedClient(retriever retriever, config RateLimitConfig) *RateLimitedClient {
+	client := &RateLimitedClient{
+		retriever: retriever,
+		config:    config,
+		buckets:   make(map[string]*tokenBucket),
+		now:       time.Now,
+		sleep:     sleepContext,
+	}
+	if config.MaxConcurrent > 0 {
+		client.slots = make(chan struct{}, config.MaxConcurrent)
+	}
+	return client
+}
+
+func (r *RateLimitedClient) Retrieve(ctx context.Context, resourceURL string, accept string) (*Response, error) {
+	return r.RetrieveRange(ctx, resourceURL, accept, 0, "")
+}
+
+func (r *RateLimitedClient) RetrieveRange(ctx context.Context, resourceURL string, accept string, offset int64, validator string) (*Response, error) {
+	// wait for the host before taking a slot, a throttled host does not hold the
+	// connections the others could use
+	if err := r.wait(ctx, resourceURL); err != nil {
+		return nil, err
+	}
+
+	release, err := r.acquireSlot(ctx)
+	if err != nil {
+		// the request is not made, its token goes back
+		if bucket := r.bucket(hostOf(resourceURL)); bucket != nil {
+			bucket.cancel()
+		}
+		return nil, err
+	}
+
+	response, err := r.retriever.RetrieveRange(ctx, resourceURL, accept, offset, validator)
+	if err != nil {
+		release()
+		return nil, err
+	}
+	response.ReadCloser = &releasingBody{ReadCloser: response.ReadCloser, release: release}
+	return response, nil
+}
+
+func (r *RateLimitedClient) acquireSlot(ctx context.Context) (func(), error) {
+	if r.slots == nil {
+		return func() {}, nil
+	}
+
+	select {
+	case r.slots <- struct{}{}:
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+
+	var once sync.Once
+	return func() {
+		once.Do(func() { <-r.slots })
+	}, nil
+}
+
+func (r *RateLimitedClient) wait(ctx context.Context, resourceURL string) error {
+	bucket := r.bucket(hostOf(resourceURL))
+	if bucket == nil {
+		return nil
+	}
+
+	delay := bucket.reserve(r.now())
+	if delay <= 0 {
+		return nil
+	}
+	if err := r.sleep(ctx, delay); err != nil {
+		bucket.cancel()
+		return err
+	}
+	return nil
+}
+
+func (r *RateLimitedClient) bucket(host string) *tokenBucket {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if bucket, ok := r.buckets[host]; ok {
+		return bucket
+	}
+
+	limit := r.limitFor(host)
+	var bucket *tokenBucket
+	if limit.RequestsPerSecond > 0 {
+		bucket = newTokenBucket(limit, r.now())
+	}
+	r.buckets[host] = bucket
+	return bucket
+}
+
+// limitFor returns the rule of the longest domain matching host, or the default one.
+func (r *RateLimitedClient) limitFor(host string) RateLimit {
+	limit := r.config.Default
+	matched := ""
+	for domain, hostLimit := range r.config.Hosts {
+		if (host == domain || strings.HasSuffix(host, "."+domain)) && len(domain) > len(matched) {
+			limit = hostLimit
+			matched = domain
+		}
+	}
+	return limit
+}
+
+func hostOf(resourceURL string) string {
+	parsed, err := url.Parse(resourceURL)
+	if err != nil {
+		return ""
+	}
+	return strings.ToLower(parsed.Hostname())
+}
+
+// releasingBody gives the connection slot back once the caller closes the body.
+type releasingBody struct {
+	io.ReadCloser
+	release func()
+}
+
+func (b *releasingBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.release()
+	return err
+}
+
+type tokenBucket struct {
+	mutex  sync.Mutex
+	rate   float64
+	burst  float64
+	tokens float64
+	last   time.Time
+}
+
+func newTokenBucket(limit RateLimit, now time.Time) *tokenBucket {
+	burst := float64(limit.Burst)
+	if burst < 1 {
+		burst = 1
+	}
+	return &tokenBucket{
+		rate:   limit.RequestsPerSecond,
+		burst:  burst,
+		tokens: burst,
+		last:   now,
+	}
+}
+
+// reserve takes a token and returns how long the caller has to wait before using it.
+func (b *tokenBucket) reserve(now time.Time) time.Duration {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	if elapsed := now.Sub(b.last).Seconds(); elapsed > 0 {
+		b.tokens = min(b.burst, b.tokens+elapsed*b.rate)
+		b.last = now
+	}
+
+	b.tokens--
+	if b.tokens >= 0 {
+		return 0
+	}
+	return time.Duration(-b.tokens / b.rate * float64(time.Second))
+}
+
+// cancel gives back a token reserved by a caller that stopped waiting for it.
+func (b *tokenBucket) cancel() {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	b.tokens = min(b.burst, b.tokens+1)
+}
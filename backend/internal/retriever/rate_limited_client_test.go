@@ -0,0 +1,169 @@
+package retriever
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/suite"
+)
+
+func TestRateLimitedClient(t *testing.T) {
+	suite.Run(t, new(TestRateLimitedClientSuite))
+}
+
+type TestRateLimitedClientSuite struct {
+	suite.Suite
+	server *httptest.Server
+}
+
+func (s *TestRateLimitedClientSuite) SetupTest() {
+	s.server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("content"))
+	}))
+}
+
+func (s *TestRateLimitedClientSuite) TearDownTest() {
+	s.server.Close()
+}
+
+func (s *TestRateLimitedClientSuite) TestRetrieve_ThrottlesPerHost() {
+	now := time.Date(2024, 10, 10, 12, 0, 0, 0, time.UTC)
+	var delays []time.Duration
+
+	client := NewRateLimitedClient(NewHttpClient(), RateLimitConfig{
+		Default: RateLimit{RequestsPerSecond: 2, Burst: 1},
+	})
+	client.now = func() time.Time { return now }
+	client.sleep = func(ctx context.Context, delay time.Duration) error {
+		delays = append(delays, delay)
+		return nil
+	}
+
+	for i := 0; i < 3; i++ {
+		response, err := client.Retrieve(context.Background(), s.server.URL, "")
+		s.Require().NoError(err)
+		s.NoError(response.Close())
+	}
+
+	s.Equal([]time.Duration{500 * time.Millisecond, time.Second}, delays)
+}
+
+func (s *TestRateLimitedClientSuite) TestRetrieve_ConcurrencyCap() {
+	client := NewRateLimitedClient(NewHttpClient(), RateLimitConfig{MaxConcurrent: 1})
+
+	first, err := client.Retrieve(context.Background(), s.server.URL, "")
+	s.Require().NoError(err)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	_, err = client.Retrieve(ctx, s.server.URL, "")
+	s.ErrorIs(err, context.DeadlineExceeded, "second connection should wait for the first one")
+
+	body, err := io.ReadAll(first)
+	s.NoError(err)
+	s.Equal("content", string(body))
+	s.NoError(first.Close())
+	s.NoError(first.Close(), "closing twice should not release the slot twice")
+
+	second, err := client.Retrieve(context.Background(), s.server.URL, "")
+	s.Require().NoError(err)
+	s.NoError(second.Close())
+	s.Len(client.slots, 0)
+}
+
+func (s *TestRateLimitedClientSuite) TestRetrieve_ThrottledHostHoldsNoSlot() {
+	now := time.Date(2024, 10, 10, 12, 0, 0, 0, time.UTC)
+	sleeping, wake := make(chan struct{}), make(chan struct{})
+	client := NewRateLimitedClient(NewHttpClient(), RateLimitConfig{
+		Default:       RateLimit{RequestsPerSecond: 1, Burst: 1},
+		MaxConcurrent: 1,
+	})
+	client.now = func() time.Time { return now }
+	client.sleep = func(ctx context.Context, delay time.Duration) error {
+		close(sleeping)
+		<-wake
+		return nil
+	}
+	throttledURL := strings.Replace(s.server.URL, "127.0.0.1", "localhost", 1)
+	response, err := client.Retrieve(context.Background(), throttledURL, "")
+	s.Require().NoError(err)
+	s.Require().NoError(response.Close())
+	throttled := make(chan error)
+	go func() {
+		response, err := client.Retrieve(context.Background(), throttledURL, "")
+		if err == nil {
+			err = response.Close()
+		}
+		throttled <- err
+	}()
+	<-sleeping
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	response, err = client.Retrieve(ctx, s.server.URL, "")
+
+	s.Require().NoError(err, "a request to another host should not wait for the throttled one")
+	s.NoError(response.Close())
+	close(wake)
+	s.NoError(<-throttled)
+}
+
+func (s *TestRateLimitedClientSuite) TestRetrieve_ReleasesSlotOnError() {
+	client := NewRateLimitedClient(NewHttpClient(), RateLimitConfig{MaxConcurrent: 1})
+
+	_, err := client.Retrieve(context.Background(), "://invalid-url", "")
+	s.Error(err)
+
+	s.Len(client.slots, 0)
+}
+
+func (s *TestRateLimitedClientSuite) Test_limitFor() {
+	client := NewRateLimitedClient(NewHttpClient(), RateLimitConfig{
+		Default: RateLimit{RequestsPerSecond: 10, Burst: 10},
+		Hosts: map[string]RateLimit{
+			"bandcamp.com":   {RequestsPerSecond: 1, Burst: 2},
+			"t4.bcbits.com":  {RequestsPerSecond: 3, Burst: 3},
+			"bcbits.com":     {RequestsPerSecond: 2, Burst: 4},
+			"evilbandcamp.x": {RequestsPerSecond: 5, Burst: 5},
+		},
+	})
+
+	tests := []struct {
+		host     string
+		expected RateLimit
+	}{
+		{"kinggizzard.bandcamp.com", RateLimit{RequestsPerSecond: 1, Burst: 2}},
+		{"bandcamp.com", RateLimit{RequestsPerSecond: 1, Burst: 2}},
+		{"t4.bcbits.com", RateLimit{RequestsPerSecond: 3, Burst: 3}},
+		{"f4.bcbits.com", RateLimit{RequestsPerSecond: 2, Burst: 4}},
+		{"notbandcamp.com", RateLimit{RequestsPerSecond: 10, Burst: 10}},
+	}
+
+	for _, tt := range tests {
+		s.Run(tt.host, func() {
+			s.Equal(tt.expected, client.limitFor(tt.host))
+		})
+	}
+}
+
+func (s *TestRateLimitedClientSuite) Test_tokenBucket() {
+	now := time.Date(2024, 10, 10, 12, 0, 0, 0, time.UTC)
+	bucket := newTokenBucket(RateLimit{RequestsPerSecond: 1, Burst: 2}, now)
+
+	s.Equal(time.Duration(0), bucket.reserve(now))
+	s.Equal(time.Duration(0), bucket.reserve(now))
+	s.Equal(time.Second, bucket.reserve(now))
+
+	bucket.cancel()
+	s.Equal(time.Second, bucket.reserve(now))
+
+	// refilled but never above the burst size
+	s.Equal(time.Duration(0), bucket.reserve(now.Add(time.Hour)))
+	s.Equal(time.Duration(0), bucket.reserve(now.Add(time.Hour)))
+	s.Equal(time.Second, bucket.reserve(now.Add(time.Hour)))
+}
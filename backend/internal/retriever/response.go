@@ -0,0 +1,40 @@
+package retriever
+
+import (
+	"io"
+	"net/http"
+	"strings"
+)
+
+// Response is the body of a successful retrieval together with the metadata needed
+// to validate and resume downloads. The caller owns it and must Close it.
+type Response struct {
+	io.ReadCloser
+	ContentType   string
+	ContentLength int64 // -1 when unknown
+	ETag          string
+	LastModified  string
+	// Offset is the position of the first body byte in the resource, non-zero when a
+	// range request was honoured with 206 Partial Content.
+	Offset int64
+}
+
+// Validator returns the value to send as If-Range to resume this response later:
+// its strong ETag or, failing that, its Last-Modified date. Empty means the response
+// cannot be resumed safely.
+func (r *Response) Validator() string {
+	if r.ETag != "" && !strings.HasPrefix(r.ETag, "W/") {
+		return r.ETag
+	}
+	return r.LastModified
+}
+
+func newResponse(response *http.Response) *Response {
+	return &Response{
+		ReadCloser:    response.Body,
+		ContentType:   response.Header.Get("Content-Type"),
+		ContentLength: response.ContentLength,
+		ETag:          response.Header.Get("ETag"),
+		LastModified:  response.Header.Get("Last-Modified"),
+	}
+}
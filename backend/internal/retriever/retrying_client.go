@@ -0,0 +1,143 @@
+package retriever
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log"
+	"math/rand"
+	"net"
+	"net/http"
+	"syscall"
+	"time"
+)
+
+type retriever interface {
+	Retrieve(ctx context.Context, url string, accept string) (*Response, error)
+	RetrieveRange(ctx context.Context, url string, accept string, offset int64, validator string) (*Response, error)
+}
+
+type RetryConfig struct {
+	MaxAttempts int
+	BaseDelay   time.Duration
+	MaxDelay    time.Duration
+}
+
+func DefaultRetryConfig() RetryConfig {
+	return RetryConfig{
+		MaxAttempts: 5,
+		BaseDelay:   500 * time.Millisecond,
+		MaxDelay:    30 * time.Second,
+	}
+}
+
+// RetryingClient retries transient failures (timeouts, connection resets, 429 and 5xx)
+// of the wrapped retriever with jittered exponential backoff, honouring Retry-After up to
+// MaxDelay.
+type RetryingClient struct {
+	retriever retriever
+	config    RetryConfig
+	jitter    func(time.Duration) time.Duration
+	sleep     func(context.Context, time.Duration) error
+}
+
+func NewRetryingClient(retriever retriever, config RetryConfig) *RetryingClient {
+	if config.MaxAttempts < 1 {
+		config.MaxAttempts = 1
+	}
+	return &RetryingClient{
+		retriever: retriever,
+		config:    config,
+		jitter:    equalJitter,
+		sleep:     sleepContext,
+	}
+}
+
+func (r *RetryingClient) Retrieve(ctx context.Context, url string, accept string) (*Response, error) {
+	return r.RetrieveRange(ctx, url, accept, 0, "")
+}
+
+func (r *RetryingClient) RetrieveRange(ctx context.Context, url string, accept string, offset int64, validator string) (*Response, error) {
+	var err error
+	for attempt := 1; attempt <= r.config.MaxAttempts; attempt++ {
+		var response *Response
+		response, err = r.retriever.RetrieveRange(ctx, url, accept, offset, validator)
+		if err == nil {
+			return response, nil
+		}
+
+		if !IsRetryable(err) || attempt == r.config.MaxAttempts {
+			return nil, err
+		}
+
+		delay, ok := r.backoff(attempt, err)
+		if !ok {
+			log.Printf("Not retrying %s, the server asks to wait %s, longer than %s: %v", url, delay, r.config.MaxDelay, err)
+			return nil, err
+		}
+		log.Printf("Retrying %s in %s (attempt %d/%d): %v", url, delay, attempt+1, r.config.MaxAttempts, err)
+		if sleepErr := r.sleep(ctx, delay); sleepErr != nil {
+			return nil, sleepErr
+		}
+	}
+	return nil, err
+}
+
+// backoff returns how long to wait before the next attempt, and false when the server
+// asks to wait longer than MaxDelay, retrying sooner would only be refused again.
+func (r *RetryingClient) backoff(attempt int, err error) (time.Duration, bool) {
+	var statusErr *StatusError
+	if errors.As(err, &statusErr) && statusErr.RetryAfter > 0 {
+		return statusErr.RetryAfter, statusErr.RetryAfter <= r.config.MaxDelay
+	}
+
+	delay := r.config.BaseDelay << (attempt - 1)
+	if delay <= 0 || delay > r.config.MaxDelay {
+		delay = r.config.MaxDelay
+	}
+	return r.jitter(delay), true
+}
+
+// IsRetryable reports whether err is a transient failure worth retrying.
+func IsRetryable(err error) bool {
+	if err == nil || errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return false
+	}
+
+	var statusErr *StatusError
+	if errors.As(err, &statusErr) {
+		return statusErr.StatusCode == http.StatusTooManyRequests || statusErr.StatusCode >= 500
+	}
+
+	var netErr net.Error
+	if errors.As(err, &netErr) && netErr.Timeout() {
+		return true
+	}
+
+	return errors.Is(err, syscall.ECONNRESET) ||
+		errors.Is(err, syscall.ECONNREFUSED) ||
+		errors.Is(err, syscall.EPIPE) ||
+		errors.Is(err, io.ErrUnexpectedEOF) ||
+		errors.Is(err, io.EOF)
+}
+
+// equalJitter spreads retries over [delay/2, delay] so parallel jobs do not retry in lockstep.
+func equalJitter(delay time.Duration) time.Duration {
+	half := delay / 2
+	if half <= 0 {
+		return delay
+	}
+	return half + time.Duration(rand.Int63n(int64(half)+1))
+}
+
+func sleepContext(ctx context.Context, delay time.Duration) error {
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
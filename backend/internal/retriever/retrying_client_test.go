@@ -0,0 +1,252 @@
+package retriever
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/suite"
+)
+
+func TestRetryingClient(t *testing.T) {
+	suite.Run(t, new(TestRetryingClientSuite))
+}
+
+type TestRetryingClientSuite struct {
+	suite.Suite
+	delays []time.Duration
+}
+
+func (s *TestRetryingClientSuite) SetupTest() {
+	s.delays = nil
+}
+
+func (s *TestRetryingClientSuite) newClient(config RetryConfig) *RetryingClient {
+	client := NewRetryingClient(NewHttpClient(), config)
+	client.jitter = func(delay time.Duration) time.Duration { return delay }
+	client.sleep = func(ctx context.Context, delay time.Duration) error {
+		s.delays = append(s.delays, delay)
+		return ctx.Err()
+	}
+	return client
+}
+
+func (s *TestRetryingClientSuite) TestRetrieve() {
+	tests := []struct {
+		name             string
+		statuses         []int
+		retryAfter       string
+		expectedError    bool
+		expectedStatus   int
+		expectedRequests int32
+		expectedDelays   []time.Duration
+	}{
+		{
+			name:             "Success on first attempt",
+			statuses:         []int{http.StatusOK},
+			expectedRequests: 1,
+		},
+		{
+			name:             "Retries server errors with exponential backoff",
+			statuses:         []int{http.StatusServiceUnavailable, http.StatusBadGateway, http.StatusOK},
+			expectedRequests: 3,
+			expectedDelays:   []time.Duration{100 * time.Millisecond, 200 * time.Millisecond},
+		},
+		{
+			name:             "Honours Retry-After on 429",
+			statuses:         []int{http.StatusTooManyRequests, http.StatusOK},
+			retryAfter:       "1",
+			expectedRequests: 2,
+			expectedDelays:   []time.Duration{time.Second},
+		},
+		{
+			name:             "Gives up when Retry-After exceeds the max delay",
+			statuses:         []int{http.StatusTooManyRequests, http.StatusOK},
+			retryAfter:       "7",
+			expectedError:    true,
+			expectedStatus:   http.StatusTooManyRequests,
+			expectedRequests: 1,
+		},
+		{
+			name:             "Does not retry not found",
+			statuses:         []int{http.StatusNotFound},
+			expectedError:    true,
+			expectedStatus:   http.StatusNotFound,
+			expectedRequests: 1,
+		},
+		{
+			name:             "Does not retry gone",
+			statuses:         []int{http.StatusGone},
+			expectedError:    true,
+			expectedStatus:   http.StatusGone,
+			expectedRequests: 1,
+		},
+		{
+			name:             "Gives up after max attempts",
+			statuses:         []int{http.StatusInternalServerError},
+			expectedError:    true,
+			expectedStatus:   http.StatusInternalServerError,
+			expectedRequests: 3,
+			expectedDelays:   []time.Duration{100 * time.Millisecond, 200 * time.Millisecond},
+		},
+	}
+
+	for _, tt := range tests {
+		s.Run(tt.name, func() {
+			s.delays = nil
+			var requests atomic.Int32
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				index := int(requests.Add(1)) - 1
+				if index >= len(tt.statuses) {
+					index = len(tt.statuses) - 1
+				}
+				if tt.retryAfter != "" {
+					w.Header().Set("Retry-After", tt.retryAfter)
+				}
+				w.WriteHeader(tt.statuses[index])
+				w.Write([]byte("body"))
+			}))
+			defer server.Close()
+
+			client := s.newClient(RetryConfig{MaxAttempts: 3, BaseDelay: 100 * time.Millisecond, MaxDelay: time.Second})
+
+			reader, err := client.Retrieve(context.Background(), server.URL, "")
+
+			if tt.expectedError {
+				var statusErr *StatusError
+				s.ErrorAs(err, &statusErr)
+				s.Equal(tt.expectedStatus, statusErr.StatusCode)
+			} else {
+				s.NoError(err)
+				body, err := io.ReadAll(reader)
+				s.NoError(err)
+				s.Equal("body", string(body))
+			}
+			s.Equal(tt.expectedRequests, requests.Load())
+			s.Equal(tt.expectedDelays, s.delays)
+		})
+	}
+}
+
+func (s *TestRetryingClientSuite) TestRetrieve_ContextCancelledWhileWaiting() {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	client := NewRetryingClient(NewHttpClient(), RetryConfig{MaxAttempts: 3, BaseDelay: time.Hour, MaxDelay: time.Hour})
+	client.sleep = func(ctx context.Context, delay time.Duration) error {
+		cancel()
+		return sleepContext(ctx, delay)
+	}
+
+	_, err := client.Retrieve(ctx, server.URL, "")
+
+	s.ErrorIs(err, context.Canceled)
+}
+
+func (s *TestRetryingClientSuite) Test_backoff_CappedByMaxDelay() {
+	client := s.newClient(RetryConfig{MaxAttempts: 10, BaseDelay: time.Second, MaxDelay: 5 * time.Second})
+
+	for attempt, expected := range map[int]time.Duration{1: time.Second, 3: 4 * time.Second, 4: 5 * time.Second, 60: 5 * time.Second} {
+		delay, ok := client.backoff(attempt, io.EOF)
+		s.True(ok)
+		s.Equal(expected, delay)
+	}
+
+	delay, ok := client.backoff(1, &StatusError{StatusCode: http.StatusTooManyRequests, RetryAfter: 5 * time.Second})
+	s.True(ok)
+	s.Equal(5*time.Second, delay)
+	_, ok = client.backoff(1, &StatusError{StatusCode: http.StatusTooManyRequests, RetryAfter: time.Hour})
+	s.False(ok, "a Retry-After beyond the max delay should not be retried")
+}
+
+func (s *TestRetryingClientSuite) TestIsRetryable() {
+	tests := []struct {
+		name     string
+		err      error
+		expected bool
+	}{
+		{"Nil", nil, false},
+		{"Too many requests", &StatusError{StatusCode: http.StatusTooManyRequests}, true},
+		{"Service unavailable", &StatusError{StatusCode: http.StatusServiceUnavailable}, true},
+		{"Not found", &StatusError{StatusCode: http.StatusNotFound}, false},
+		{"Gone", &StatusError{StatusCode: http.StatusGone}, false},
+		{"Connection reset", &wrappedError{syscall.ECONNRESET}, true},
+		{"Unexpected EOF", io.ErrUnexpectedEOF, true},
+		{"Timeout", &timeoutError{}, true},
+		{"Context cancelled", context.Canceled, false},
+		{"Deadline exceeded", context.DeadlineExceeded, false},
+		{"Unknown", errors.New("boom"), false},
+	}
+
+	for _, tt := range tests {
+		s.Run(tt.name, func() {
+			s.Equal(tt.expected, IsRetryable(tt.err))
+		})
+	}
+}
+
+func (s *TestRetryingClientSuite) TestIsExpired() {
+	tests := []struct {
+		name     string
+		err      error
+		expected bool
+	}{
+		{"Nil", nil, false},
+		{"Forbidden", &StatusError{StatusCode: http.StatusForbidden}, true},
+		{"Gone", fmt.Errorf("saving: %w", &StatusError{StatusCode: http.StatusGone}), true},
+		{"Not found", &StatusError{StatusCode: http.StatusNotFound}, false},
+		{"Service unavailable", &StatusError{StatusCode: http.StatusServiceUnavailable}, false},
+		{"Unexpected EOF", io.ErrUnexpectedEOF, false},
+	}
+
+	for _, tt := range tests {
+		s.Run(tt.name, func() {
+			s.Equal(tt.expected, IsExpired(tt.err))
+		})
+	}
+}
+
+func (s *TestRetryingClientSuite) Test_parseRetryAfter() {
+	now := time.Date(2024, 10, 10, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name     string
+		value    string
+		expected time.Duration
+	}{
+		{"Empty", "", 0},
+		{"Seconds", "120", 2 * time.Minute},
+		{"Negative", "-1", 0},
+		{"HTTP date", now.Add(90 * time.Second).Format(http.TimeFormat), 90 * time.Second},
+		{"Date in the past", now.Add(-time.Minute).Format(http.TimeFormat), 0},
+		{"Garbage", "soon", 0},
+	}
+
+	for _, tt := range tests {
+		s.Run(tt.name, func() {
+			s.Equal(tt.expected, parseRetryAfter(tt.value, now))
+		})
+	}
+}
+
+type wrappedError struct {
+	err error
+}
+
+func (w *wrappedError) Error() string { return "read: " + w.err.Error() }
+func (w *wrappedError) Unwrap() error { return w.err }
+
+type timeoutError struct{}
+
+func (t *timeoutError) Error() string   { return "i/o timeout" }
+func (t *timeoutError) Timeout() bool   { return true }
+func (t *timeoutError) Temporary() bool { return true }
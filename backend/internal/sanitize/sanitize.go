@@ -0,0 +1,106 @@
+package sanitize
+
+import (
+	"path"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"golang.org/x/text/cases"
+	"golang.org/x/text/unicode/norm"
+)
+
+// MaxNameBytes is the longest file or folder name accepted by ext4, exFAT and SMB shares.
+const MaxNameBytes = 255
+
+// MaxFileNameBytes is the longest name of a file, leaving room for the suffixes the
+// saver appends to it while writing, the longest being ".part.validator".
+const MaxFileNameBytes = MaxNameBytes - len(".part.validator")
+
+// maxExtensionBytes bounds what Name keeps as an extension when it has to truncate.
+const maxExtensionBytes = 8
+
+// replacer maps the characters reserved on Windows, exFAT and SMB to safe look-alikes.
+var replacer = strings.NewReplacer(
+	"/", "-",
+	"\\", "-",
+	":", " -",
+	"|", "-",
+	"\"", "'",
+	"*", "",
+	"?", "",
+	"<", "",
+	">", "",
+)
+
+var reservedNames = map[string]bool{
+	"CON": true, "PRN": true, "AUX": true, "NUL": true,
+	"COM1": true, "COM2": true, "COM3": true, "COM4": true, "COM5": true, "COM6": true, "COM7": true, "COM8": true, "COM9": true,
+	"LPT1": true, "LPT2": true, "LPT3": true, "LPT4": true, "LPT5": true, "LPT6": true, "LPT7": true, "LPT8": true, "LPT9": true,
+}
+
+// Name turns a single path segment into a name every supported filesystem accepts:
+// NFC normalized, without reserved or control characters, reserved device names,
+// leading spaces, trailing spaces and dots, and at most MaxNameBytes long. Leading dots
+// are kept, as in "...Like Clockwork".
+func Name(name string) string {
+	return clean(name, MaxNameBytes)
+}
+
+// FileName is Name for the file of a track, at most MaxFileNameBytes long.
+func FileName(name string) string {
+	return clean(name, MaxFileNameBytes)
+}
+
+func clean(name string, maxBytes int) string {
+	name = norm.NFC.String(name)
+	name = replacer.Replace(name)
+	name = strings.Map(func(r rune) rune {
+		if unicode.IsSpace(r) {
+			return ' '
+		}
+		if unicode.IsControl(r) {
+			return -1
+		}
+		return r
+	}, name)
+	name = strings.Join(strings.Fields(name), " ")
+	name = strings.TrimRight(name, ". ")
+	name = strings.TrimLeft(name, " ")
+	if name == "" || name == "." || name == ".." {
+		return "_"
+	}
+
+	stem := strings.TrimSuffix(name, path.Ext(name))
+	if reservedNames[strings.ToUpper(stem)] {
+		name = stem + "_" + path.Ext(name)
+	}
+	return truncate(name, maxBytes)
+}
+
+// truncate cuts name down to maxBytes on a rune boundary, keeping a short extension.
+func truncate(name string, maxBytes int) string {
+	if len(name) <= maxBytes {
+		return name
+	}
+
+	extension := path.Ext(name)
+	if len(extension) > maxExtensionBytes {
+		extension = ""
+	}
+	stem := strings.TrimSuffix(name, extension)
+	limit := maxBytes - len(extension)
+	for limit > 0 && !utf8.RuneStart(stem[limit]) {
+		limit--
+	}
+	return strings.TrimRight(stem[:limit], ". ") + extension
+}
+
+// Key identifies a library path regardless of Unicode normalization form and case, so
+// "Björk" written as NFC or NFD, or "The Band" and "the band", are the same entry.
+// Catalog entries and lookups must both go through Key.
+func Key(p string) string {
+	p = strings.ReplaceAll(p, "\\", "/")
+	// a Caser keeps state, so it cannot be shared between goroutines
+	return cases.Fold().String(norm.NFC.String(p))
+}
@@ -0,0 +1,73 @@
+package sanitize
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/suite"
+	"golang.org/x/text/unicode/norm"
+)
+
+func TestSanitize(t *testing.T) {
+	suite.Run(t, new(TestSanitizeSuite))
+}
+
+type TestSanitizeSuite struct {
+	suite.Suite
+}
+
+func (s *TestSanitizeSuite) TestName() {
+	tests := []struct {
+		name     string
+		input    string
+		expected string
+	}{
+		{"Unchanged", "01 - Elbow.mp3", "01 - Elbow.mp3"},
+		{"Slash", "AC/DC", "AC-DC"},
+		{"Colon", "OK Computer: OKNOTOK", "OK Computer - OKNOTOK"},
+		{"Reserved characters", `Who? "Me" <*>|\`, "Who 'Me' --"},
+		{"Control characters", "Tab\tand\x00null", "Tab andnull"},
+		{"Trailing dots and spaces", "  Vol. 2...  ", "Vol. 2"},
+		{"Reserved name", "con", "con_"},
+		{"Reserved name with extension", "NUL.mp3", "NUL_.mp3"},
+		{"Only dots", "..", "_"},
+		{"Empty", "", "_"},
+		{"NFD to NFC", norm.NFD.String("Björk"), "Björk"},
+	}
+
+	for _, tt := range tests {
+		s.Run(tt.name, func() {
+			result := Name(tt.input)
+			s.Equal(tt.expected, result)
+			s.True(norm.NFC.IsNormalString(result))
+		})
+	}
+}
+
+func (s *TestSanitizeSuite) TestName_Truncates() {
+	long := strings.Repeat("á", 200) + ".mp3"
+
+	result := Name(long)
+
+	s.LessOrEqual(len(result), MaxNameBytes)
+	s.True(strings.HasSuffix(result, ".mp3"), "extension should be kept")
+	s.True(strings.HasPrefix(result, "áá"))
+	s.NotContains(result, "�", "runes should not be split")
+}
+
+func (s *TestSanitizeSuite) TestFileName_LeavesRoomForSuffixes() {
+	long := strings.Repeat("a", 300) + ".mp3"
+
+	result := FileName(long)
+
+	s.Len(result+".part.validator", MaxNameBytes)
+	s.True(strings.HasSuffix(result, ".mp3"), "extension should be kept")
+	s.Equal(Name("Elbow.mp3"), FileName("Elbow.mp3"))
+}
+
+func (s *TestSanitizeSuite) TestKey() {
+	s.Equal(Key("The Band/Album/01 - Intro.mp3"), Key("the band/ALBUM/01 - intro.mp3"))
+	s.Equal(Key(norm.NFC.String("Björk/Debut")), Key(norm.NFD.String("Björk/Debut")))
+	s.Equal(Key("Artist/Album"), Key(`Artist\Album`))
+	s.NotEqual(Key("Artist/Album"), Key("Artist/Album 2"))
+}
@@ -1,52 +1,244 @@
 package saver
 
 import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"errors"
 	"fmt"
 	"io"
+	"log"
 	"os"
+	"path"
 	"path/filepath"
 	"strings"
+	"sync"
+	"time"
 
+	"github.com/josedelrio85/bndcmp_downloader/internal/id3"
+	"github.com/josedelrio85/bndcmp_downloader/internal/layout"
 	"github.com/josedelrio85/bndcmp_downloader/internal/model"
+	"github.com/josedelrio85/bndcmp_downloader/internal/mp3"
+	"github.com/josedelrio85/bndcmp_downloader/internal/sanitize"
 )
 
+const (
+	coverFileName = "cover.jpg"
+	// PartialExtension marks files still being written, they never count as downloaded.
+	PartialExtension = ".part"
+	// QuarantineFolder holds the downloads that are not valid MP3 streams, below the
+	// storage folder and mirroring its layout. Catalogs skip it.
+	QuarantineFolder = ".quarantine"
+	// CatalogFileName and JobsFileName are the default databases of the album catalog
+	// and the API jobs, in the storage folder.
+	CatalogFileName = ".catalog.db"
+	JobsFileName    = ".jobs.db"
+	// maxDurationDrift is how far the length of a download may be from the one Bandcamp reports.
+	maxDurationDrift = 2 * time.Second
+)
+
+var ErrInvalidAudio = errors.New("invalid MP3 stream")
+
 type LocalSaver struct {
 	storageFolder string
+	layout        *layout.Template
+	// coverMutex keeps concurrent downloads of one album from writing its cover together.
+	coverMutex sync.Mutex
 }
 
-func NewLocalSaver(folder *string) *LocalSaver {
+// NewLocalSaver stores tracks under folder following template, the default layout when nil.
+func NewLocalSaver(folder *string, template *layout.Template) *LocalSaver {
 	storageFolder := "./"
 	if folder != nil {
 		storageFolder = *folder
 	}
 	storageFolder = strings.TrimSuffix(storageFolder, "/")
-	return &LocalSaver{storageFolder: storageFolder}
+	if template == nil {
+		template = layout.MustParse(layout.Default)
+	}
+	return &LocalSaver{storageFolder: storageFolder, layout: template}
 }
 
-func (s *LocalSaver) Save(data io.Reader, track *model.Track) error {
+// Internal reports whether name is a folder or file the downloader keeps in the storage
+// folder besides the library. Other names starting with a dot are releases like any
+// other, e.g. "...Like Clockwork".
+func Internal(name string) bool {
+	switch name {
+	case QuarantineFolder, CatalogFileName, JobsFileName:
+		return true
+	default:
+		return false
+	}
+}
+
+func (s *LocalSaver) Save(ctx context.Context, data io.Reader, track *model.Track) error {
 	if track == nil {
 		return errors.New("track is nil")
 	}
 
-	directoryStructure := s.generateDirectoryStructure(track)
-	directoryStructureWithBase := filepath.Join(s.storageFolder, directoryStructure)
+	filePath := s.trackPath(track)
+	directoryStructureWithBase := filepath.Dir(filePath)
 	if err := s.checkFolder(directoryStructureWithBase); err != nil {
 		return err
 	}
 
-	trackName := fmt.Sprintf("%02d - %s.mp3", track.TrackNumber, track.Title)
-	if err := s.saveFile(directoryStructureWithBase, trackName, data); err != nil {
+	tag, err := newTag(track).Bytes()
+	if err != nil {
+		return fmt.Errorf("tagging %s: %w", filePath, err)
+	}
+
+	partPath := filePath + PartialExtension
+	done, err := startWriting(partPath)
+	if err != nil {
+		return err
+	}
+	defer done()
+	// the tag goes first and the audio is hashed and checked as it is written, in a
+	// single pass, so the final name only ever holds a complete track
+	hash := sha256.New()
+	audio := mp3.NewWriter()
+	err = writePartial(ctx, partPath, tag, data, io.MultiWriter(hash, audio))
+	stream, audioErr := audio.Finish()
+	if err != nil {
+		return err
+	}
+	if err := checkAudio(stream, audioErr, track); err != nil {
+		return s.quarantine(partPath, filePath, err)
+	}
+	if err := commit(partPath, filePath); err != nil {
 		return err
 	}
+	track.Checksum = hex.EncodeToString(hash.Sum(nil))
+	recordChecksum(directoryStructureWithBase, filepath.Base(filePath), track.Checksum)
+
+	if track.Artwork != nil && track.Album != nil {
+		if err := s.saveCover(ctx, directoryStructureWithBase, track.Artwork); err != nil {
+			return err
+		}
+	}
 	return nil
 }
 
+// trackPath returns where track is stored, reusing the existing folders and file that
+// only differ by case or Unicode normalization.
+func (s *LocalSaver) trackPath(track *model.Track) string {
+	directoryStructure := s.generateDirectoryStructure(track)
+	directoryStructureWithBase := s.storageFolder
+	if directoryStructure != "." {
+		for _, folder := range strings.Split(directoryStructure, "/") {
+			directoryStructureWithBase = filepath.Join(directoryStructureWithBase, existingName(directoryStructureWithBase, folder))
+		}
+	}
+	trackName := existingName(directoryStructureWithBase, path.Base(s.layout.Path(track)))
+	return filepath.Join(directoryStructureWithBase, trackName)
+}
+
+// checkAudio confirms the download is a whole MPEG stream as long as the track, Bandcamp
+// sometimes answers with truncated or silent responses.
+func checkAudio(stream *mp3.Stream, err error, track *model.Track) error {
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidAudio, err)
+	}
+	if track.Duration > 0 {
+		expected := time.Duration(track.Duration * float64(time.Second))
+		if (stream.Duration - expected).Abs() > maxDurationDrift {
+			return fmt.Errorf("%w: %s of audio, expected %s", ErrInvalidAudio, stream.Duration.Round(time.Millisecond), expected.Round(time.Millisecond))
+		}
+	}
+	return nil
+}
+
+// quarantine moves the invalid download at partPath aside for inspection, so it is not
+// resumed nor taken for the track at filePath.
+func (s *LocalSaver) quarantine(partPath string, filePath string, cause error) error {
+	defer removePartial(partPath)
+	rel, err := filepath.Rel(s.storageFolder, filePath)
+	if err != nil {
+		return cause
+	}
+	quarantinePath := filepath.Join(s.storageFolder, QuarantineFolder, rel)
+	if err := os.MkdirAll(filepath.Dir(quarantinePath), os.ModePerm); err != nil {
+		log.Printf("Error creating quarantine folder for %s: %v", filePath, err)
+		return cause
+	}
+	if err := os.Rename(partPath, quarantinePath); err != nil {
+		log.Printf("Error quarantining %s: %v", filePath, err)
+		return cause
+	}
+	log.Printf("Quarantined %s as %s: %v", filePath, quarantinePath, cause)
+	return fmt.Errorf("%w, quarantined as %s", cause, quarantinePath)
+}
+
+func newTag(track *model.Track) *id3.Tag {
+	tag := &id3.Tag{
+		Title:       track.Title,
+		Artist:      track.Artist,
+		AlbumArtist: track.AlbumArtist,
+		TrackNumber: track.TrackNumber,
+		TrackTotal:  track.TrackTotal,
+		Year:        track.ReleaseYear,
+		ISRC:        track.ISRC,
+		URL:         track.URL,
+		Picture:     track.Artwork,
+	}
+	if track.Album != nil {
+		tag.Album = *track.Album
+	}
+	return tag
+}
+
 func (s *LocalSaver) generateDirectoryStructure(track *model.Track) string {
-	if track.Album == nil {
-		return strings.Join([]string{track.Artist}, "/")
+	return s.layout.Dir(track)
+}
+
+// saveCover writes the album artwork next to its tracks, once per album folder.
+func (s *LocalSaver) saveCover(ctx context.Context, base string, artwork []byte) error {
+	s.coverMutex.Lock()
+	defer s.coverMutex.Unlock()
+	if _, err := os.Stat(filepath.Join(base, coverFileName)); err == nil {
+		return nil
+	}
+	if err := s.saveFile(ctx, base, coverFileName, bytes.NewReader(artwork)); err != nil {
+		if errors.Is(err, ErrAlreadySaving) {
+			// another saver of the same folder is writing it
+			return nil
+		}
+		return err
+	}
+	checksum := sha256.Sum256(artwork)
+	recordChecksum(base, coverFileName, hex.EncodeToString(checksum[:]))
+	return nil
+}
+
+// recordChecksum adds a saved file to the manifest of its folder. The file itself is
+// complete, so failing to record it does not fail the download.
+func recordChecksum(dir string, name string, checksum string) {
+	if err := UpdateManifest(dir, name, checksum); err != nil {
+		log.Printf("Error recording the checksum of %s in %s: %v", name, dir, err)
+	}
+}
+
+// existingName returns the entry of dir that only differs from name by Unicode normalization
+// or case, so "the band" reuses "The Band" instead of creating a duplicate folder that
+// case-insensitive filesystems cannot hold next to it.
+func existingName(dir string, name string) string {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return name
+	}
+
+	key := sanitize.Key(name)
+	match := name
+	for _, entry := range entries {
+		if entry.Name() == name {
+			return name
+		}
+		if match == name && sanitize.Key(entry.Name()) == key {
+			match = entry.Name()
+		}
 	}
-	return strings.Join([]string{track.Artist, *track.Album}, "/")
+	return match
 }
 
 func (s *LocalSaver) checkFolder(base string) error {
@@ -59,17 +251,30 @@ func (s *LocalSaver) checkFolder(base string) error {
 	return nil
 }
 
-func (s *LocalSaver) saveFile(base string, filename string, data io.Reader) error {
+func (s *LocalSaver) saveFile(ctx context.Context, base string, filename string, data io.Reader) error {
 	filePath := filepath.Join(base, filename)
-	newFile, err := os.Create(filePath)
+	partPath := filePath + PartialExtension
+	done, err := startWriting(partPath)
 	if err != nil {
 		return err
 	}
-	defer newFile.Close()
-
-	_, err = io.Copy(newFile, data)
-	if err != nil {
+	defer done()
+	if err := writePartial(ctx, partPath, nil, data, io.Discard); err != nil {
 		return err
 	}
-	return nil
+	return commit(partPath, filePath)
+}
+
+// contextReader stops reading as soon as the context is done, so a cancelled
+// download is not streamed to disk until the remote server gives up.
+type contextReader struct {
+	ctx    context.Context
+	reader io.Reader
+}
+
+func (c *contextReader) Read(p []byte) (int, error) {
+	if err := c.ctx.Err(); err != nil {
+		return 0, err
+	}
+	return c.reader.Read(p)
 }
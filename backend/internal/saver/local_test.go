@@ -1,14 +1,25 @@
 package saver
 
 import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"fmt"
+	"io"
 	"os"
 	"path/filepath"
 	"strings"
 	"testing"
+	"time"
 
+	"github.com/josedelrio85/bndcmp_downloader/internal/id3"
+	"github.com/josedelrio85/bndcmp_downloader/internal/layout"
 	"github.com/josedelrio85/bndcmp_downloader/internal/model"
+	"github.com/josedelrio85/bndcmp_downloader/internal/mp3/mp3test"
+	"github.com/josedelrio85/bndcmp_downloader/internal/retriever"
+	"github.com/josedelrio85/bndcmp_downloader/internal/sanitize"
 	"github.com/stretchr/testify/suite"
+	"golang.org/x/text/unicode/norm"
 )
 
 func TestLocalSaver(t *testing.T) {
@@ -26,7 +37,7 @@ func (s *TestLocalSaverSuite) SetupTest() {
 	s.tempDir, err = os.MkdirTemp("", "localsaver_test")
 	s.Require().NoError(err)
 
-	s.saver = NewLocalSaver(&s.tempDir)
+	s.saver = NewLocalSaver(&s.tempDir, nil)
 }
 
 func (s *TestLocalSaverSuite) TearDownTest() {
@@ -39,6 +50,9 @@ func (s *TestLocalSaverSuite) TearDownTest() {
 	}
 }
 
+// audio is a valid MP3 stream of three frames.
+var audio = string(mp3test.Frames(3))
+
 func toPointer(s string) *string {
 	return &s
 }
@@ -152,7 +166,7 @@ func (s *TestLocalSaverSuite) Test_saveFile() {
 
 	for _, tt := range testCases {
 		fmt.Println(tt.Description)
-		err := s.saver.saveFile(s.tempDir, tt.Filename, strings.NewReader("test"))
+		err := s.saver.saveFile(context.Background(), s.tempDir, tt.Filename, strings.NewReader("test"))
 		if tt.ExpectedResult {
 			s.NoError(err)
 		} else {
@@ -175,7 +189,7 @@ func (s *TestLocalSaverSuite) TestSave() {
 				TrackNumber: 1,
 				Artist:      "Test Artist",
 			},
-			data:        "Test audio data",
+			data:        string(mp3test.Frames(3)),
 			expectedErr: false,
 		},
 		{
@@ -186,7 +200,7 @@ func (s *TestLocalSaverSuite) TestSave() {
 				Artist:      "Test Artist",
 				Album:       toPointer("Test Album"),
 			},
-			data:        "Test audio data with album",
+			data:        string(mp3test.Frames(4)),
 			expectedErr: false,
 		},
 		{
@@ -200,7 +214,7 @@ func (s *TestLocalSaverSuite) TestSave() {
 	for _, tt := range tests {
 		s.Run(tt.name, func() {
 			reader := strings.NewReader(tt.data)
-			err := s.saver.Save(reader, tt.track)
+			err := s.saver.Save(context.Background(), reader, tt.track)
 
 			if tt.expectedErr {
 				s.Error(err)
@@ -219,11 +233,518 @@ func (s *TestLocalSaverSuite) TestSave() {
 					s.NoError(err, "File should exist")
 					s.False(fileInfo.IsDir(), "File path should not be a directory")
 
+					tag, err := newTag(tt.track).Bytes()
+					s.NoError(err)
+
 					content, err := os.ReadFile(filePath)
 					s.NoError(err, "Should be able to read the file")
-					s.Equal(tt.data, string(content), "File content should match the input data")
+					s.Equal(string(tag)+tt.data, string(content), "File content should be the tag followed by the input data")
 				}
 			}
 		})
 	}
 }
+
+func (s *TestLocalSaverSuite) TestSave_Artwork() {
+	track := &model.Track{
+		Title:       "Elbow",
+		TrackNumber: 1,
+		Artist:      "Test Artist",
+		Album:       toPointer("Test Album"),
+		Artwork:     []byte("album artwork"),
+	}
+
+	err := s.saver.Save(context.Background(), strings.NewReader(audio), track)
+	s.Require().NoError(err)
+
+	coverPath := filepath.Join(s.tempDir, "Test Artist", "Test Album", "cover.jpg")
+	cover, err := os.ReadFile(coverPath)
+	s.NoError(err)
+	s.Equal("album artwork", string(cover))
+
+	tag, err := newTag(track).Bytes()
+	s.NoError(err)
+	s.Contains(string(tag), "APIC")
+	content, err := os.ReadFile(filepath.Join(s.tempDir, "Test Artist", "Test Album", "01 - Elbow.mp3"))
+	s.NoError(err)
+	s.Equal(string(tag)+audio, string(content))
+
+	// the cover is only written for the first track of the album
+	s.Require().NoError(os.WriteFile(coverPath, []byte("existing cover"), 0644))
+	track.Artwork = []byte("other artwork")
+	err = s.saver.Save(context.Background(), strings.NewReader(audio), track)
+	s.NoError(err)
+	cover, err = os.ReadFile(coverPath)
+	s.NoError(err)
+	s.Equal("existing cover", string(cover))
+}
+
+func (s *TestLocalSaverSuite) TestSave_ArtworkWithoutAlbum() {
+	track := &model.Track{
+		Title:       "Single",
+		TrackNumber: 1,
+		Artist:      "Test Artist",
+		Artwork:     []byte("single artwork"),
+	}
+
+	err := s.saver.Save(context.Background(), strings.NewReader(audio), track)
+
+	s.NoError(err)
+	_, err = os.Stat(filepath.Join(s.tempDir, "Test Artist", "cover.jpg"))
+	s.True(os.IsNotExist(err), "standalone tracks should not write an artist cover")
+}
+
+func (s *TestLocalSaverSuite) TestSave_Layout() {
+	s.saver = NewLocalSaver(&s.tempDir, layout.MustParse(layout.Presets["navidrome"]))
+	track := &model.Track{
+		Title:       "Elbow",
+		TrackNumber: 1,
+		Artist:      "Test Artist",
+		Album:       toPointer("Test Album"),
+		ReleaseYear: 2012,
+	}
+
+	err := s.saver.Save(context.Background(), strings.NewReader(audio), track)
+
+	s.NoError(err)
+	_, err = os.Stat(filepath.Join(s.tempDir, "Test Artist", "2012 - Test Album", "01 - Elbow.mp3"))
+	s.NoError(err, "track should follow the configured layout")
+}
+
+func (s *TestLocalSaverSuite) TestSave_LongTitle() {
+	track := &model.Track{Title: strings.Repeat("Elbow ", 60), TrackNumber: 1, Artist: "Test Artist"}
+	response := &retriever.Response{
+		ReadCloser:    io.NopCloser(strings.NewReader(audio)),
+		ContentLength: int64(len(audio)),
+		ETag:          `"v1"`,
+	}
+
+	err := s.saver.Save(context.Background(), response, track)
+
+	s.NoError(err, "the partial file and its validator should fit in the name limit")
+	tracks, err := filepath.Glob(filepath.Join(s.tempDir, "Test Artist", "*.mp3"))
+	s.Require().NoError(err)
+	s.Require().Len(tracks, 1)
+	s.LessOrEqual(len(filepath.Base(tracks[0])+PartialExtension+validatorExtension), sanitize.MaxNameBytes)
+}
+
+func (s *TestLocalSaverSuite) TestSave_ReusesExistingFolders() {
+	existing := filepath.Join(s.tempDir, "The Band", norm.NFD.String("Café Tacvba"))
+	s.Require().NoError(os.MkdirAll(existing, 0755))
+	track := &model.Track{
+		Title:       "Intro?",
+		TrackNumber: 1,
+		Artist:      "the band",
+		Album:       toPointer(norm.NFC.String("Café Tacvba")),
+	}
+
+	err := s.saver.Save(context.Background(), strings.NewReader(audio), track)
+
+	s.NoError(err)
+	_, err = os.Stat(filepath.Join(existing, "01 - Intro.mp3"))
+	s.NoError(err, "track should be saved in the existing folders")
+	entries, err := os.ReadDir(s.tempDir)
+	s.NoError(err)
+	s.Len(entries, 1, "no duplicate artist folder should be created")
+}
+
+func (s *TestLocalSaverSuite) Test_saveFile_ContextCancelled() {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	err := s.saver.saveFile(ctx, s.tempDir, "cancelled.mp3", strings.NewReader("test"))
+
+	s.ErrorIs(err, context.Canceled)
+	_, err = os.Stat(filepath.Join(s.tempDir, "cancelled.mp3"))
+	s.True(os.IsNotExist(err), "Partial file should be removed")
+}
+
+func (s *TestLocalSaverSuite) Test_saveFile_ReaderError() {
+	data := io.MultiReader(strings.NewReader("partial"), &errorReader{err: io.ErrUnexpectedEOF})
+
+	err := s.saver.saveFile(context.Background(), s.tempDir, "truncated.mp3", data)
+
+	s.ErrorIs(err, io.ErrUnexpectedEOF)
+	_, err = os.Stat(filepath.Join(s.tempDir, "truncated.mp3"))
+	s.True(os.IsNotExist(err), "Partial file should be removed")
+	_, err = os.Stat(filepath.Join(s.tempDir, "truncated.mp3"+PartialExtension))
+	s.True(os.IsNotExist(err), "Partial file should be removed")
+}
+
+func (s *TestLocalSaverSuite) Test_saveFile_ContentLengthMismatch() {
+	response := &retriever.Response{ReadCloser: io.NopCloser(strings.NewReader("short")), ContentLength: 10}
+
+	err := s.saver.saveFile(context.Background(), s.tempDir, "short.mp3", response)
+
+	s.ErrorIs(err, ErrIncompleteDownload)
+	entries, err := os.ReadDir(s.tempDir)
+	s.NoError(err)
+	s.Empty(entries, "neither the track nor its partial file should be left")
+}
+
+func (s *TestLocalSaverSuite) Test_saveFile_ContentLength() {
+	response := &retriever.Response{ReadCloser: io.NopCloser(strings.NewReader("complete")), ContentLength: 8}
+
+	err := s.saver.saveFile(context.Background(), s.tempDir, "complete.mp3", response)
+
+	s.NoError(err)
+	content, err := os.ReadFile(filepath.Join(s.tempDir, "complete.mp3"))
+	s.NoError(err)
+	s.Equal("complete", string(content))
+	_, err = os.Stat(filepath.Join(s.tempDir, "complete.mp3"+PartialExtension))
+	s.True(os.IsNotExist(err), "partial file should be renamed")
+}
+
+func (s *TestLocalSaverSuite) TestSave_KeepsExistingTrackOnError() {
+	track := &model.Track{Title: "Elbow", TrackNumber: 1, Artist: "Test Artist"}
+	s.Require().NoError(s.saver.Save(context.Background(), strings.NewReader(audio), track))
+	trackPath := filepath.Join(s.tempDir, "Test Artist", "01 - Elbow.mp3")
+	before, err := os.ReadFile(trackPath)
+	s.Require().NoError(err)
+
+	data := io.MultiReader(strings.NewReader("second"), &errorReader{err: io.ErrUnexpectedEOF})
+	err = s.saver.Save(context.Background(), data, track)
+
+	s.ErrorIs(err, io.ErrUnexpectedEOF)
+	after, err := os.ReadFile(trackPath)
+	s.NoError(err)
+	s.Equal(before, after, "a failed download should not touch the complete track")
+}
+
+func (s *TestLocalSaverSuite) TestSave_QuarantinesInvalidAudio() {
+	tests := []struct {
+		name     string
+		data     string
+		duration float64
+	}{
+		{name: "Error page", data: "<html><body>403 Forbidden</body></html>"},
+		{name: "Empty", data: ""},
+		{name: "Cut short", data: audio[:len(audio)-100]},
+		{name: "Shorter than the track", data: audio, duration: 170.41},
+	}
+
+	for _, tt := range tests {
+		s.Run(tt.name, func() {
+			track := &model.Track{Title: "Elbow", TrackNumber: 1, Artist: "Test Artist", Album: toPointer("Test Album"), Duration: tt.duration}
+
+			err := s.saver.Save(context.Background(), strings.NewReader(tt.data), track)
+
+			s.ErrorIs(err, ErrInvalidAudio)
+			quarantined, err := os.ReadFile(filepath.Join(s.tempDir, QuarantineFolder, "Test Artist", "Test Album", "01 - Elbow.mp3"))
+			s.NoError(err)
+			tag, err := newTag(track).Bytes()
+			s.NoError(err)
+			s.Equal(string(tag)+tt.data, string(quarantined), "the download is kept for inspection")
+			entries, err := os.ReadDir(filepath.Join(s.tempDir, "Test Artist", "Test Album"))
+			s.NoError(err)
+			s.Empty(entries, "no track, partial file nor manifest should be left")
+			offset, _ := s.saver.Partial(track)
+			s.Zero(offset)
+			s.Empty(track.Checksum)
+		})
+	}
+}
+
+func (s *TestLocalSaverSuite) TestSave_MatchingDuration() {
+	track := &model.Track{Title: "Elbow", TrackNumber: 1, Artist: "Test Artist", Duration: 1.5}
+
+	err := s.saver.Save(context.Background(), strings.NewReader(string(mp3test.Frames(100))), track)
+
+	s.NoError(err, "2.6s of audio is within the allowed drift")
+	_, err = os.Stat(filepath.Join(s.tempDir, QuarantineFolder))
+	s.True(os.IsNotExist(err))
+}
+
+func (s *TestLocalSaverSuite) TestCleanPartials() {
+	albumDir := filepath.Join(s.tempDir, "Artist", "Album")
+	s.Require().NoError(os.MkdirAll(albumDir, 0755))
+	s.Require().NoError(os.WriteFile(filepath.Join(albumDir, "01 - Done.mp3"), []byte("done"), 0644))
+	s.Require().NoError(os.WriteFile(filepath.Join(albumDir, "02 - Crashed.mp3"+PartialExtension), []byte("cra"), 0644))
+	s.Require().NoError(os.WriteFile(filepath.Join(albumDir, "03 - Resumable.mp3"+PartialExtension), []byte("res"), 0644))
+	s.Require().NoError(os.WriteFile(filepath.Join(albumDir, "03 - Resumable.mp3"+PartialExtension+validatorExtension), []byte(`"v1"`), 0644))
+	s.Require().NoError(os.WriteFile(filepath.Join(albumDir, "04 - Orphan.mp3"+PartialExtension+validatorExtension), []byte(`"v1"`), 0644))
+	// left by a tag rewrite interrupted by a crash
+	s.Require().NoError(os.WriteFile(filepath.Join(albumDir, ".tagging-123"+PartialExtension), []byte("tagged"), 0644))
+
+	err := s.saver.CleanPartials()
+
+	s.NoError(err)
+	entries, err := os.ReadDir(albumDir)
+	s.NoError(err)
+	var names []string
+	for _, entry := range entries {
+		names = append(names, entry.Name())
+	}
+	s.Equal([]string{
+		"01 - Done.mp3",
+		"03 - Resumable.mp3" + PartialExtension,
+		"03 - Resumable.mp3" + PartialExtension + validatorExtension,
+	}, names)
+}
+
+func (s *TestLocalSaverSuite) TestCleanPartials_WhileSaving() {
+	track := &model.Track{Title: "Elbow", TrackNumber: 1, Artist: "Test Artist"}
+	partPath := filepath.Join(s.tempDir, "Test Artist", "01 - Elbow.mp3"+PartialExtension)
+	reader, writer := io.Pipe()
+	saved := make(chan error)
+	go func() {
+		saved <- s.saver.Save(context.Background(), reader, track)
+	}()
+	_, err := writer.Write([]byte(audio[:100]))
+	s.Require().NoError(err)
+	s.Require().Eventually(func() bool {
+		_, err := os.Stat(partPath)
+		return err == nil
+	}, time.Second, time.Millisecond)
+
+	err = s.saver.CleanPartials()
+
+	s.NoError(err)
+	s.FileExists(partPath, "the download in progress should keep writing it")
+	_, err = writer.Write([]byte(audio[100:]))
+	s.Require().NoError(err)
+	writer.Close()
+	s.NoError(<-saved)
+}
+
+func (s *TestLocalSaverSuite) TestSave_SameTrackTwice() {
+	track := &model.Track{Title: "Elbow", TrackNumber: 1, Artist: "Test Artist"}
+	partPath := filepath.Join(s.tempDir, "Test Artist", "01 - Elbow.mp3"+PartialExtension)
+	reader, writer := io.Pipe()
+	saved := make(chan error)
+	go func() {
+		saved <- s.saver.Save(context.Background(), reader, track)
+	}()
+	_, err := writer.Write([]byte(audio[:100]))
+	s.Require().NoError(err)
+	s.Require().Eventually(func() bool {
+		_, err := os.Stat(partPath)
+		return err == nil
+	}, time.Second, time.Millisecond)
+
+	err = NewLocalSaver(&s.tempDir, nil).Save(context.Background(), strings.NewReader(audio), &model.Track{Title: "Elbow", TrackNumber: 1, Artist: "Test Artist"})
+
+	s.ErrorIs(err, ErrAlreadySaving)
+	s.saver.Discard(track)
+	s.FileExists(partPath, "the partial file of the first download should be left to it")
+	_, err = writer.Write([]byte(audio[100:]))
+	s.Require().NoError(err)
+	writer.Close()
+	s.NoError(<-saved)
+	content, err := os.ReadFile(filepath.Join(s.tempDir, "Test Artist", "01 - Elbow.mp3"))
+	s.NoError(err)
+	tag, err := newTag(track).Bytes()
+	s.NoError(err)
+	s.Equal(string(tag)+audio, string(content))
+}
+
+func (s *TestLocalSaverSuite) TestRemoveOrphaned_Writing() {
+	partPath := filepath.Join(s.tempDir, "01 - Downloading.mp3"+PartialExtension)
+	s.Require().NoError(os.WriteFile(partPath, []byte("dow"), 0644))
+	done, err := startWriting(partPath)
+	s.Require().NoError(err)
+
+	s.False(Orphaned(partPath), "a download without validator is still writing it")
+	removed, err := RemoveOrphaned(partPath)
+	s.NoError(err)
+	s.False(removed)
+	s.FileExists(partPath)
+
+	done()
+
+	s.True(Orphaned(partPath))
+	removed, err = RemoveOrphaned(partPath)
+	s.NoError(err)
+	s.True(removed)
+	s.NoFileExists(partPath)
+}
+
+func (s *TestLocalSaverSuite) TestSave_Resume() {
+	track := &model.Track{Title: "Elbow", TrackNumber: 1, Artist: "Test Artist"}
+	first := &retriever.Response{
+		ReadCloser:    io.NopCloser(io.MultiReader(strings.NewReader(audio[:4]), &errorReader{err: io.ErrUnexpectedEOF})),
+		ContentLength: int64(len(audio)),
+		ETag:          `"v1"`,
+	}
+
+	err := s.saver.Save(context.Background(), first, track)
+
+	s.ErrorIs(err, io.ErrUnexpectedEOF)
+	offset, validator := s.saver.Partial(track)
+	s.Equal(int64(4), offset)
+	s.Equal(`"v1"`, validator)
+
+	second := &retriever.Response{
+		ReadCloser:    io.NopCloser(strings.NewReader(audio[4:])),
+		ContentLength: int64(len(audio) - 4),
+		ETag:          `"v1"`,
+		Offset:        4,
+	}
+	err = s.saver.Save(context.Background(), second, track)
+
+	s.NoError(err)
+	tag, err := newTag(track).Bytes()
+	s.NoError(err)
+	content, err := os.ReadFile(filepath.Join(s.tempDir, "Test Artist", "01 - Elbow.mp3"))
+	s.NoError(err)
+	s.Equal(string(tag)+audio, string(content))
+	offset, validator = s.saver.Partial(track)
+	s.Zero(offset)
+	s.Empty(validator)
+	entries, err := os.ReadDir(filepath.Join(s.tempDir, "Test Artist"))
+	s.NoError(err)
+	s.Len(entries, 2, "partial file and validator should be gone, leaving the track and its manifest")
+}
+
+func (s *TestLocalSaverSuite) TestSave_ResumeTaggedDownload() {
+	track := &model.Track{Title: "Elbow", TrackNumber: 1, Artist: "Test Artist"}
+	downloadTag, err := (&id3.Tag{Title: "Bandcamp"}).Bytes()
+	s.Require().NoError(err)
+	download := string(downloadTag) + audio
+	first := &retriever.Response{
+		ReadCloser:    io.NopCloser(io.MultiReader(strings.NewReader(download[:len(downloadTag)+4]), &errorReader{err: io.ErrUnexpectedEOF})),
+		ContentLength: int64(len(download)),
+		ETag:          `"v1"`,
+	}
+
+	s.Require().ErrorIs(s.saver.Save(context.Background(), first, track), io.ErrUnexpectedEOF)
+	offset, validator := s.saver.Partial(track)
+	s.Equal(int64(len(downloadTag)+4), offset, "the tag of the download counts even though it is not kept")
+	s.Equal(`"v1"`, validator)
+
+	second := &retriever.Response{
+		ReadCloser:    io.NopCloser(strings.NewReader(download[offset:])),
+		ContentLength: int64(len(download)) - offset,
+		ETag:          `"v1"`,
+		Offset:        offset,
+	}
+	err = s.saver.Save(context.Background(), second, track)
+
+	s.NoError(err)
+	tag, err := newTag(track).Bytes()
+	s.NoError(err)
+	filePath := filepath.Join(s.tempDir, "Test Artist", "01 - Elbow.mp3")
+	content, err := os.ReadFile(filePath)
+	s.NoError(err)
+	s.Equal(string(tag)+audio, string(content), "the tag of the download is replaced")
+	s.Equal(fileChecksum(s.T(), filePath), track.Checksum)
+}
+
+func (s *TestLocalSaverSuite) TestDiscard() {
+	track := &model.Track{Title: "Elbow", TrackNumber: 1, Artist: "Test Artist"}
+	response := &retriever.Response{
+		ReadCloser:    io.NopCloser(io.MultiReader(strings.NewReader(audio[:4]), &errorReader{err: io.ErrUnexpectedEOF})),
+		ContentLength: int64(len(audio)),
+		ETag:          `"v1"`,
+	}
+	s.Require().ErrorIs(s.saver.Save(context.Background(), response, track), io.ErrUnexpectedEOF)
+
+	s.saver.Discard(track)
+
+	offset, _ := s.saver.Partial(track)
+	s.Zero(offset)
+	entries, err := os.ReadDir(filepath.Join(s.tempDir, "Test Artist"))
+	s.NoError(err)
+	s.Empty(entries, "partial file and validator should be removed")
+}
+
+func (s *TestLocalSaverSuite) TestSave_ResumeMismatch() {
+	track := &model.Track{Title: "Elbow", TrackNumber: 1, Artist: "Test Artist"}
+	partPath := filepath.Join(s.tempDir, "Test Artist", "01 - Elbow.mp3"+PartialExtension)
+	s.Require().NoError(os.MkdirAll(filepath.Dir(partPath), 0755))
+	s.Require().NoError(os.WriteFile(partPath, []byte("01"), 0644))
+	s.Require().NoError(os.WriteFile(partPath+validatorExtension, []byte(`"v1"`), 0644))
+
+	response := &retriever.Response{ReadCloser: io.NopCloser(strings.NewReader("456789")), ContentLength: 6, Offset: 4}
+	err := s.saver.Save(context.Background(), response, track)
+
+	s.ErrorIs(err, ErrResumeMismatch)
+	offset, _ := s.saver.Partial(track)
+	s.Zero(offset, "a partial file that cannot be resumed should be discarded")
+}
+
+func (s *TestLocalSaverSuite) TestSave_NotResumableWithoutValidator() {
+	track := &model.Track{Title: "Elbow", TrackNumber: 1, Artist: "Test Artist"}
+	response := &retriever.Response{
+		ReadCloser:    io.NopCloser(io.MultiReader(strings.NewReader("0123"), &errorReader{err: io.ErrUnexpectedEOF})),
+		ContentLength: 10,
+	}
+
+	err := s.saver.Save(context.Background(), response, track)
+
+	s.ErrorIs(err, io.ErrUnexpectedEOF)
+	entries, err := os.ReadDir(filepath.Join(s.tempDir, "Test Artist"))
+	s.NoError(err)
+	s.Empty(entries)
+}
+
+type errorReader struct {
+	err error
+}
+
+func (e *errorReader) Read(p []byte) (int, error) {
+	return 0, e.err
+}
+
+func (s *TestLocalSaverSuite) TestSave_Manifest() {
+	albumDir := filepath.Join(s.tempDir, "Test Artist", "Test Album")
+	first := &model.Track{Title: "Elbow", TrackNumber: 1, Artist: "Test Artist", Album: toPointer("Test Album"), Artwork: []byte("album artwork")}
+	second := &model.Track{Title: "Muckraker", TrackNumber: 2, Artist: "Test Artist", Album: toPointer("Test Album")}
+
+	s.Require().NoError(s.saver.Save(context.Background(), strings.NewReader(audio), second))
+	s.Require().NoError(s.saver.Save(context.Background(), strings.NewReader(audio), first))
+
+	s.Equal(fileChecksum(s.T(), filepath.Join(albumDir, "01 - Elbow.mp3")), first.Checksum, "the checksum is of the tagged file")
+	manifest, err := os.ReadFile(filepath.Join(albumDir, ManifestFileName))
+	s.NoError(err)
+	s.Equal(first.Checksum+"  01 - Elbow.mp3\n"+
+		second.Checksum+"  02 - Muckraker.mp3\n"+
+		fileChecksum(s.T(), filepath.Join(albumDir, "cover.jpg"))+"  cover.jpg\n", string(manifest))
+
+	// a new download of the track replaces its checksum
+	s.Require().NoError(s.saver.Save(context.Background(), strings.NewReader(string(mp3test.Frames(4))), second))
+	sums, err := ReadManifest(albumDir)
+	s.NoError(err)
+	s.Len(sums, 3)
+	s.Equal(fileChecksum(s.T(), filepath.Join(albumDir, "02 - Muckraker.mp3")), sums["02 - Muckraker.mp3"])
+}
+
+func (s *TestLocalSaverSuite) TestUpdateManifest() {
+	s.Require().NoError(UpdateManifest(s.tempDir, "b.mp3", "bbbb"))
+	s.Require().NoError(UpdateManifest(s.tempDir, "a track.mp3", "aaaa"))
+
+	sums, err := ReadManifest(s.tempDir)
+	s.NoError(err)
+	s.Equal(map[string]string{"a track.mp3": "aaaa", "b.mp3": "bbbb"}, sums)
+
+	s.Require().NoError(UpdateManifest(s.tempDir, "b.mp3", ""))
+	sums, err = ReadManifest(s.tempDir)
+	s.NoError(err)
+	s.Equal(map[string]string{"a track.mp3": "aaaa"}, sums)
+
+	s.Require().NoError(UpdateManifest(s.tempDir, "a track.mp3", ""))
+	_, err = os.Stat(filepath.Join(s.tempDir, ManifestFileName))
+	s.True(os.IsNotExist(err), "an empty manifest should be removed")
+}
+
+func (s *TestLocalSaverSuite) TestReadManifest() {
+	s.Require().NoError(os.WriteFile(filepath.Join(s.tempDir, ManifestFileName), []byte("aaaa  01 - Elbow.mp3\nbbbb *cover.jpg\ninvalid\n"), 0644))
+
+	sums, err := ReadManifest(s.tempDir)
+
+	s.NoError(err)
+	s.Equal(map[string]string{"01 - Elbow.mp3": "aaaa", "cover.jpg": "bbbb"}, sums)
+
+	sums, err = ReadManifest(filepath.Join(s.tempDir, "missing"))
+	s.NoError(err)
+	s.Empty(sums)
+}
+
+func fileChecksum(t *testing.T, filePath string) string {
+	content, err := os.ReadFile(filePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	checksum := sha256.Sum256(content)
+	return hex.EncodeToString(checksum[:])
+}
@@ -0,0 +1,88 @@
+package saver
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+)
+
+// ManifestFileName is the manifest of every album folder, listing the SHA-256 of the
+// files saved in it in the format of sha256sum, so `sha256sum -c SHA256SUMS` checks a copy.
+const ManifestFileName = "SHA256SUMS"
+
+// manifestMutex serializes the updates of the manifests, shared by the tracks of an album
+// saved concurrently and the verifier.
+var manifestMutex sync.Mutex
+
+// ReadManifest returns the checksums listed in the manifest of dir by file name, none
+// when dir has no manifest.
+func ReadManifest(dir string) (map[string]string, error) {
+	sums := make(map[string]string)
+	file, err := os.Open(filepath.Join(dir, ManifestFileName))
+	if os.IsNotExist(err) {
+		return sums, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		// "<checksum>  <name>", or "<checksum> *<name>" for binary mode
+		checksum, name, found := strings.Cut(scanner.Text(), " ")
+		if !found || len(name) < 2 {
+			continue
+		}
+		sums[name[1:]] = checksum
+	}
+	return sums, scanner.Err()
+}
+
+// UpdateManifest sets the checksum of the file name in the manifest of dir, or removes
+// it when checksum is empty. A manifest left empty is removed.
+func UpdateManifest(dir string, name string, checksum string) error {
+	manifestMutex.Lock()
+	defer manifestMutex.Unlock()
+
+	sums, err := ReadManifest(dir)
+	if err != nil {
+		return err
+	}
+	if checksum == "" {
+		delete(sums, name)
+	} else {
+		sums[name] = checksum
+	}
+
+	manifestPath := filepath.Join(dir, ManifestFileName)
+	if len(sums) == 0 {
+		if err := os.Remove(manifestPath); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		return nil
+	}
+
+	names := make([]string, 0, len(sums))
+	for name := range sums {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	var content bytes.Buffer
+	for _, name := range names {
+		fmt.Fprintf(&content, "%s  %s\n", sums[name], name)
+	}
+
+	partPath := manifestPath + PartialExtension
+	if err := writePartial(context.Background(), partPath, nil, &content, io.Discard); err != nil {
+		return err
+	}
+	return commit(partPath, manifestPath)
+}
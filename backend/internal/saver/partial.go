@@ -0,0 +1,311 @@
+package saver
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/josedelrio85/bndcmp_downloader/internal/id3"
+	"github.com/josedelrio85/bndcmp_downloader/internal/model"
+	"github.com/josedelrio85/bndcmp_downloader/internal/retriever"
+)
+
+// validatorExtension is appended to a partial file name to store the If-Range
+// validator of the response it was started from.
+const validatorExtension = ".validator"
+
+var (
+	ErrIncompleteDownload = errors.New("incomplete download")
+	ErrResumeMismatch     = errors.New("resumed download does not match the partial file")
+	ErrAlreadySaving      = errors.New("another download is saving the same file")
+)
+
+// writing holds the partial files being written. Those are not orphaned even when they
+// cannot be resumed, shared by the savers and the verifier like the manifests.
+var (
+	writingMutex sync.Mutex
+	writing      = make(map[string]bool)
+)
+
+// startWriting marks partPath as being written until the returned func is called. Only
+// one download writes a partial file at a time.
+func startWriting(partPath string) (func(), error) {
+	writingMutex.Lock()
+	defer writingMutex.Unlock()
+	if writing[partPath] {
+		return nil, fmt.Errorf("%w: %s", ErrAlreadySaving, partPath)
+	}
+	writing[partPath] = true
+	return func() {
+		writingMutex.Lock()
+		defer writingMutex.Unlock()
+		delete(writing, partPath)
+	}, nil
+}
+
+// IsPartial reports whether name is an unfinished download or its resume metadata.
+func IsPartial(name string) bool {
+	return strings.HasSuffix(name, PartialExtension) || strings.HasSuffix(name, PartialExtension+validatorExtension)
+}
+
+// Partial returns how many bytes of track are already on disk from an interrupted
+// download, and the validator to resume it with. A zero offset means start over.
+func (s *LocalSaver) Partial(track *model.Track) (int64, string) {
+	if track == nil {
+		return 0, ""
+	}
+	offset, validator, err := partialOffset(s.trackPath(track) + PartialExtension)
+	if err != nil || offset <= 0 {
+		return 0, ""
+	}
+	return offset, validator
+}
+
+// partialOffset returns how many bytes of its download the partial track at partPath
+// holds, and the validator to resume it with. The file starts with the tag written in
+// place of the one of the download, the validator file records the size of the latter.
+// Partial files without a tag are started over.
+func partialOffset(partPath string) (int64, string, error) {
+	content, err := os.ReadFile(partPath + validatorExtension)
+	if err != nil {
+		return 0, "", err
+	}
+	validator, skippedValue, _ := strings.Cut(string(content), "\n")
+	var skipped int64
+	if skippedValue != "" {
+		if skipped, err = strconv.ParseInt(skippedValue, 10, 64); err != nil {
+			return 0, "", err
+		}
+	}
+
+	partFile, err := os.Open(partPath)
+	if err != nil {
+		return 0, "", err
+	}
+	defer partFile.Close()
+	info, err := partFile.Stat()
+	if err != nil {
+		return 0, "", err
+	}
+	tagSize, err := id3.TagSize(partFile)
+	if err != nil || tagSize == 0 {
+		return 0, "", err
+	}
+	return info.Size() - tagSize + skipped, validator, nil
+}
+
+// Discard removes the partial file of track, when its download is stopped for good.
+// The one another download is writing is kept.
+func (s *LocalSaver) Discard(track *model.Track) {
+	if track == nil {
+		return
+	}
+	partPath := s.trackPath(track) + PartialExtension
+	writingMutex.Lock()
+	defer writingMutex.Unlock()
+	if !writing[partPath] {
+		removePartial(partPath)
+	}
+}
+
+// writePartial streams data into partPath, copying every byte of the file to w, and
+// flushes it to disk. A track starts with its tag, written in place of the one data
+// starts with. When data is a retriever response, the byte count is checked against
+// its Content-Length and a 206 response is appended to the partial file it resumes,
+// after copying what the file already holds to w. On failure the partial file is kept
+// only if it can be resumed.
+func writePartial(ctx context.Context, partPath string, tag []byte, data io.Reader, w io.Writer) error {
+	reader := bufio.NewReader(&contextReader{ctx: ctx, reader: data})
+	var skipped int64
+	if tag != nil && !resumes(data) {
+		var err error
+		if skipped, err = skipTag(reader); err != nil {
+			return err
+		}
+	}
+
+	partFile, err := openPartial(partPath, tag, skipped, data, w)
+	if err != nil {
+		return err
+	}
+
+	written, err := io.Copy(io.MultiWriter(partFile, w), reader)
+	if err == nil {
+		err = checkLength(data, skipped+written)
+	}
+	if err == nil {
+		err = partFile.Sync()
+	}
+	if closeErr := partFile.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		if !resumable(partPath) {
+			removePartial(partPath)
+		}
+		return err
+	}
+	return nil
+}
+
+// skipTag discards the ID3v2 tag reader starts with, returning its size.
+func skipTag(reader *bufio.Reader) (int64, error) {
+	// a read error is returned again by the copy, after what was read is saved
+	header, _ := reader.Peek(10)
+	size, err := id3.TagSize(bytes.NewReader(header))
+	if err != nil {
+		return 0, err
+	}
+	skipped, err := reader.Discard(int(size))
+	if errors.Is(err, io.EOF) {
+		err = fmt.Errorf("%w: ID3 tag has %d of %d bytes", ErrIncompleteDownload, skipped, size)
+	}
+	return int64(skipped), err
+}
+
+func resumes(data io.Reader) bool {
+	response, ok := data.(*retriever.Response)
+	return ok && response.Offset > 0
+}
+
+// openPartial appends to partPath when data resumes it, otherwise starts it over with
+// tag and records the validator needed to resume it later, along with the size of the
+// tag skipped from data.
+func openPartial(partPath string, tag []byte, skipped int64, data io.Reader, w io.Writer) (*os.File, error) {
+	response, _ := data.(*retriever.Response)
+	if resumes(data) {
+		offset, _, err := partialOffset(partPath)
+		if err != nil || offset != response.Offset {
+			removePartial(partPath)
+			return nil, fmt.Errorf("%w: expected %s to end at byte %d", ErrResumeMismatch, partPath, response.Offset)
+		}
+		partFile, err := os.OpenFile(partPath, os.O_RDWR|os.O_APPEND, 0)
+		if err != nil {
+			return nil, err
+		}
+		if _, err := io.Copy(w, partFile); err != nil {
+			partFile.Close()
+			return nil, err
+		}
+		return partFile, nil
+	}
+
+	os.Remove(partPath + validatorExtension)
+	partFile, err := os.Create(partPath)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := io.MultiWriter(partFile, w).Write(tag); err != nil {
+		partFile.Close()
+		removePartial(partPath)
+		return nil, err
+	}
+	if response != nil && response.Validator() != "" {
+		validator := response.Validator()
+		if skipped > 0 {
+			validator += "\n" + strconv.FormatInt(skipped, 10)
+		}
+		if err := os.WriteFile(partPath+validatorExtension, []byte(validator), 0644); err != nil {
+			partFile.Close()
+			removePartial(partPath)
+			return nil, err
+		}
+	}
+	return partFile, nil
+}
+
+func checkLength(data io.Reader, written int64) error {
+	response, ok := data.(*retriever.Response)
+	if !ok || response.ContentLength < 0 || response.ContentLength == written {
+		return nil
+	}
+	return fmt.Errorf("%w: wrote %d of %d bytes", ErrIncompleteDownload, written, response.ContentLength)
+}
+
+// Orphaned reports whether the partial file or validator at filePath was left behind
+// by a download that cannot be resumed, and no download is writing it.
+func Orphaned(filePath string) bool {
+	writingMutex.Lock()
+	defer writingMutex.Unlock()
+	return orphaned(filePath)
+}
+
+// RemoveOrphaned removes the partial file or validator at filePath if it is orphaned.
+// No download can start writing it in between.
+func RemoveOrphaned(filePath string) (bool, error) {
+	writingMutex.Lock()
+	defer writingMutex.Unlock()
+	if !orphaned(filePath) {
+		return false, nil
+	}
+	if err := os.Remove(filePath); err != nil && !os.IsNotExist(err) {
+		return false, err
+	}
+	return true, nil
+}
+
+func orphaned(filePath string) bool {
+	if !IsPartial(filePath) {
+		return false
+	}
+	partPath := strings.TrimSuffix(filePath, validatorExtension)
+	return !writing[partPath] && !resumable(partPath)
+}
+
+func resumable(partPath string) bool {
+	if _, err := os.Stat(partPath + validatorExtension); err != nil {
+		return false
+	}
+	info, err := os.Stat(partPath)
+	return err == nil && info.Size() > 0
+}
+
+func removePartial(partPath string) {
+	os.Remove(partPath)
+	os.Remove(partPath + validatorExtension)
+}
+
+// commit moves a complete file into place. The rename is atomic within a folder,
+// syncing the folder makes it survive a crash.
+func commit(partPath string, filePath string) error {
+	if err := os.Rename(partPath, filePath); err != nil {
+		removePartial(partPath)
+		return err
+	}
+	os.Remove(partPath + validatorExtension)
+	if dir, err := os.Open(filepath.Dir(filePath)); err == nil {
+		dir.Sync()
+		dir.Close()
+	}
+	return nil
+}
+
+// CleanPartials removes what crashed downloads left behind and cannot be resumed:
+// partial files without a validator and validators without a partial file. It may run
+// while tracks are saved, the partial files being written are kept.
+func (s *LocalSaver) CleanPartials() error {
+	return filepath.WalkDir(s.storageFolder, func(filePath string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if entry.IsDir() || !IsPartial(entry.Name()) {
+			return nil
+		}
+
+		removed, err := RemoveOrphaned(filePath)
+		if removed {
+			log.Printf("Removed partial download %s", filePath)
+		}
+		return err
+	})
+}
@@ -1,6 +1,8 @@
 package scrapper
 
 import (
+	"context"
+	"encoding/json"
 	io "io"
 	"log"
 	"net/url"
@@ -8,34 +10,61 @@ import (
 	"strings"
 
 	"github.com/josedelrio85/bndcmp_downloader/internal/album_catalog"
+	"github.com/josedelrio85/bndcmp_downloader/internal/bandcamp"
 	"github.com/josedelrio85/bndcmp_downloader/internal/model"
+	"github.com/josedelrio85/bndcmp_downloader/internal/retriever"
 	html "golang.org/x/net/html"
 )
 
 type AlbumScrapper struct {
-	TrackList     []string
-	httpClient    Retriever
-	parseClient   Parser
-	saveClient    Saver
-	executeClient func(Retriever, Parser, Saver, album_catalog.AlbumCatalog) Executer
-	albumCatalog  album_catalog.AlbumCatalog
+	TrackList      []string
+	Tracks         []*model.Track
+	httpClient     Retriever
+	parseClient    Parser
+	saveClient     Saver
+	executeClient  func(Retriever, Parser, Saver, album_catalog.AlbumCatalog) Executer
+	downloadClient func(Retriever, Parser, Saver, album_catalog.AlbumCatalog) Downloader
+	albumCatalog   album_catalog.AlbumCatalog
+	options        Options
+	observer       Observer
 }
 
 func NewAlbumScrapper(httpClient Retriever, parseClient Parser, saveClient Saver, albumCatalog album_catalog.AlbumCatalog) *AlbumScrapper {
-	return &AlbumScrapper{
-		TrackList:   []string{},
-		httpClient:  httpClient,
-		parseClient: parseClient,
-		saveClient:  saveClient,
-		executeClient: func(httpClient Retriever, parseClient Parser, saveClient Saver, albumCatalog album_catalog.AlbumCatalog) Executer {
-			return NewTrackScrapper(httpClient, parseClient, saveClient, albumCatalog)
-		},
+	albumScrapper := &AlbumScrapper{
+		TrackList:    []string{},
+		httpClient:   httpClient,
+		parseClient:  parseClient,
+		saveClient:   saveClient,
 		albumCatalog: albumCatalog,
+		options:      DefaultOptions(),
+		observer:     nopObserver{},
 	}
+	albumScrapper.executeClient = func(httpClient Retriever, parseClient Parser, saveClient Saver, albumCatalog album_catalog.AlbumCatalog) Executer {
+		return albumScrapper.newTrackScrapper(httpClient, parseClient, saveClient, albumCatalog)
+	}
+	albumScrapper.downloadClient = func(httpClient Retriever, parseClient Parser, saveClient Saver, albumCatalog album_catalog.AlbumCatalog) Downloader {
+		return albumScrapper.newTrackScrapper(httpClient, parseClient, saveClient, albumCatalog)
+	}
+	return albumScrapper
 }
 
-func (a *AlbumScrapper) Retrieve(url string) (io.Reader, error) {
-	return a.httpClient.Retrieve(url)
+func (a *AlbumScrapper) SetOptions(options Options) {
+	a.options = options
+}
+
+func (a *AlbumScrapper) SetObserver(observer Observer) {
+	a.observer = observer
+}
+
+func (a *AlbumScrapper) newTrackScrapper(httpClient Retriever, parseClient Parser, saveClient Saver, albumCatalog album_catalog.AlbumCatalog) *TrackScrapper {
+	trackScrapper := NewTrackScrapper(httpClient, parseClient, saveClient, albumCatalog)
+	trackScrapper.SetOptions(a.options)
+	trackScrapper.SetObserver(a.observer)
+	return trackScrapper
+}
+
+func (a *AlbumScrapper) Retrieve(ctx context.Context, url string, accept string) (*retriever.Response, error) {
+	return a.httpClient.Retrieve(ctx, url, accept)
 }
 
 func (a *AlbumScrapper) Parse(data io.Reader) (*html.Node, error) {
@@ -43,6 +72,9 @@ func (a *AlbumScrapper) Parse(data io.Reader) (*html.Node, error) {
 }
 
 func (a *AlbumScrapper) Find(node *html.Node) error {
+	if err := a.findTrAlbum(node); err != nil {
+		return err
+	}
 	if err := a.find(node); err != nil {
 		return err
 	}
@@ -50,6 +82,31 @@ func (a *AlbumScrapper) Find(node *html.Node) error {
 	return nil
 }
 
+// findTrAlbum reads the album's data-tralbum, which already lists every track with its stream URL.
+// An unreadable payload is not fatal, the track pages are used instead.
+func (a *AlbumScrapper) findTrAlbum(node *html.Node) error {
+	if node.Type == html.ElementNode && node.Data == "script" {
+		for _, attr := range node.Attr {
+			if attr.Key == "data-tralbum" {
+				var albumInfo bandcamp.TrAlbum
+				if err := json.Unmarshal([]byte(attr.Val), &albumInfo); err != nil {
+					log.Printf("Error unmarshalling album data-tralbum, falling back to track pages: %v", err)
+					return nil
+				}
+				a.Tracks = albumInfo.ToTracks()
+				return nil
+			}
+		}
+	}
+
+	for c := node.FirstChild; c != nil; c = c.NextSibling {
+		if err := a.findTrAlbum(c); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 func (a *AlbumScrapper) find(node *html.Node) error {
 	if node.Type == html.ElementNode && node.Data == "a" {
 		for _, attr := range node.Attr {
@@ -82,19 +139,65 @@ func (a *AlbumScrapper) processTrackList() []string {
 	return result
 }
 
-func (a *AlbumScrapper) Save(data io.Reader, track *model.Track) error {
+func (a *AlbumScrapper) Save(ctx context.Context, data io.Reader, track *model.Track) error {
 	return nil
 }
 
-func (a *AlbumScrapper) Execute(albumURL *url.URL) error {
+// Execute downloads every track of the album. A track failing is reported and the
+// others are still downloaded, only a stopped context ends the album early.
+func (a *AlbumScrapper) Execute(ctx context.Context, albumURL *url.URL) error {
 	log.Println("Scrapping album at:", albumURL.String())
-	reader, err := a.Retrieve(albumURL.String())
+	a.TrackList = []string{}
+	a.Tracks = nil
+	if err := a.readPage(ctx, albumURL); err != nil {
+		if ctx.Err() == nil {
+			a.observer.AlbumFailed(albumURL.String(), err)
+		}
+		return err
+	}
+
+	if progress, ok := a.observer.(Progress); ok {
+		progress.AlbumStarted(albumURL.String())
+	}
+
+	if len(a.Tracks) > 0 {
+		return a.executeTracks(ctx)
+	}
+
+	baseURL := url.URL{
+		Scheme: albumURL.Scheme,
+		Host:   albumURL.Host,
+	}
+	log.Printf("%d tracks to download \n", len(a.TrackList))
+	var failures []error
+	for _, track := range a.TrackList {
+		if err := ctx.Err(); err != nil {
+			log.Println("Album scrapper cancelled:", err)
+			return err
+		}
+		trackURL := baseURL.ResolveReference(&url.URL{Path: track})
+		log.Println("Retrieving track:", trackURL.String())
+		trackScrapper := a.executeClient(a.httpClient, a.parseClient, a.saveClient, a.albumCatalog)
+		if err := trackScrapper.Execute(ctx, trackURL); err != nil {
+			if ctx.Err() != nil {
+				return err
+			}
+			log.Println("Error executing track scrapper:", err)
+			failures = append(failures, err)
+		}
+	}
+	return failed(failures, len(a.TrackList), "tracks")
+}
+
+func (a *AlbumScrapper) readPage(ctx context.Context, albumURL *url.URL) error {
+	reader, err := a.Retrieve(ctx, albumURL.String(), htmlContentType)
 	if err != nil {
 		log.Println("Error retrieving album:", err)
 		return err
 	}
 
 	node, err := a.Parse(reader)
+	reader.Close()
 	if err != nil {
 		log.Println("Error parsing album HTML:", err)
 		return err
@@ -105,20 +208,59 @@ func (a *AlbumScrapper) Execute(albumURL *url.URL) error {
 		log.Println("Error finding tracks in album HTML:", err)
 		return err
 	}
+	return nil
+}
 
-	baseURL := url.URL{
-		Scheme: albumURL.Scheme,
-		Host:   albumURL.Host,
+// executeTracks downloads the tracks found in the album's data-tralbum, only visiting
+// the track page of those without a stream URL.
+func (a *AlbumScrapper) executeTracks(ctx context.Context) error {
+	log.Printf("%d tracks to download \n", len(a.Tracks))
+	// every track of the album shares the cover, download it once
+	artwork := fetchArtwork(ctx, a.httpClient, a.Tracks[0].ArtID, a.options.ArtworkSize)
+	var failures []error
+	for _, track := range a.Tracks {
+		track.Artwork, track.ArtworkFetched = artwork, true
+		if err := ctx.Err(); err != nil {
+			log.Println("Album scrapper cancelled:", err)
+			return err
+		}
+
+		if err := a.executeTrack(ctx, track); err != nil {
+			if ctx.Err() != nil {
+				return err
+			}
+			failures = append(failures, err)
+		}
 	}
-	log.Printf("%d tracks to download \n", len(a.TrackList))
-	for _, track := range a.TrackList {
-		trackURL := baseURL.ResolveReference(&url.URL{Path: track})
-		log.Println("Retrieving track:", trackURL.String())
-		trackScrapper := a.executeClient(a.httpClient, a.parseClient, a.saveClient, a.albumCatalog)
-		if err := trackScrapper.Execute(trackURL); err != nil {
-			log.Println("Error executing track scrapper:", err)
+	return failed(failures, len(a.Tracks), "tracks")
+}
+
+// executeTrack downloads a track of the data-tralbum, its failures are reported.
+func (a *AlbumScrapper) executeTrack(ctx context.Context, track *model.Track) error {
+	if track.DownloadURL != "" {
+		log.Println("Downloading track:", track.Title)
+		if err := a.downloadClient(a.httpClient, a.parseClient, a.saveClient, a.albumCatalog).Download(ctx, track); err != nil {
+			log.Println("Error downloading track:", err)
 			return err
 		}
+		return nil
+	}
+
+	if track.URL == "" {
+		log.Println("Skipping track without stream or page URL:", track.Title)
+		a.observer.TrackSkipped(track)
+		return nil
+	}
+	trackURL, err := url.Parse(track.URL)
+	if err != nil {
+		log.Println("Error parsing track URL:", err)
+		a.observer.TrackFailed(track, err)
+		return err
+	}
+	log.Println("Retrieving track:", trackURL.String())
+	if err := a.executeClient(a.httpClient, a.parseClient, a.saveClient, a.albumCatalog).Execute(ctx, trackURL); err != nil {
+		log.Println("Error executing track scrapper:", err)
+		return err
 	}
 	return nil
 }
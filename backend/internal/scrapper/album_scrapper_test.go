@@ -2,6 +2,7 @@ package scrapper
 
 import (
 	"bytes"
+	"context"
 	_ "embed"
 	"errors"
 	"net/url"
@@ -9,6 +10,7 @@ import (
 
 	gomock "github.com/golang/mock/gomock"
 	"github.com/josedelrio85/bndcmp_downloader/internal/album_catalog"
+	"github.com/josedelrio85/bndcmp_downloader/internal/layout"
 	"github.com/josedelrio85/bndcmp_downloader/internal/model"
 	"github.com/stretchr/testify/suite"
 	html "golang.org/x/net/html"
@@ -17,6 +19,9 @@ import (
 //go:embed resources/valid_album_example.html
 var validAlbumExample string
 
+//go:embed resources/valid_album_tralbum_example.html
+var validAlbumTrAlbumExample string
+
 func TestAlbumScrapper(t *testing.T) {
 	suite.Run(t, new(TestalbumScrapperSuite))
 }
@@ -27,13 +32,25 @@ type mockTrackScrapper struct {
 	URL          string
 }
 
-func (m *mockTrackScrapper) Execute(url *url.URL) error {
+func (m *mockTrackScrapper) Execute(ctx context.Context, url *url.URL) error {
 	m.ExecuteCalls++
+	m.URL = url.String()
 	return m.ExecuteFunc()
 }
 
+type mockTrackDownloader struct {
+	DownloadFunc func(track *model.Track) error
+	Tracks       []*model.Track
+}
+
+func (m *mockTrackDownloader) Download(ctx context.Context, track *model.Track) error {
+	m.Tracks = append(m.Tracks, track)
+	return m.DownloadFunc(track)
+}
+
 type TestalbumScrapperSuite struct {
 	suite.Suite
+	ctx             context.Context
 	controller      *gomock.Controller
 	mockHttpClient  *MockRetriever
 	mockParseClient *MockParser
@@ -44,6 +61,7 @@ type TestalbumScrapperSuite struct {
 }
 
 func (s *TestalbumScrapperSuite) SetupTest() {
+	s.ctx = context.Background()
 	s.controller = gomock.NewController(s.T())
 	s.mockHttpClient = NewMockRetriever(s.controller)
 	s.mockParseClient = NewMockParser(s.controller)
@@ -63,9 +81,9 @@ func (s *TestalbumScrapperSuite) TearDownTest() {
 
 func (s *TestalbumScrapperSuite) TestRetrieve_Success() {
 	mockResponse := []byte("mock response data")
-	s.mockHttpClient.EXPECT().Retrieve(s.albumURL.String()).Return(bytes.NewReader(mockResponse), nil)
+	s.mockHttpClient.EXPECT().Retrieve(s.ctx, s.albumURL.String(), htmlContentType).Return(newMockResponse(mockResponse), nil)
 
-	reader, err := s.albumScrapper.Retrieve(s.albumURL.String())
+	reader, err := s.albumScrapper.Retrieve(s.ctx, s.albumURL.String(), htmlContentType)
 
 	s.NoError(err)
 	s.NotNil(reader)
@@ -73,9 +91,9 @@ func (s *TestalbumScrapperSuite) TestRetrieve_Success() {
 
 func (s *TestalbumScrapperSuite) TestRetrieve_Error() {
 	expectedError := errors.New("failed to retrieve track")
-	s.mockHttpClient.EXPECT().Retrieve(s.albumURL.String()).Return(nil, expectedError)
+	s.mockHttpClient.EXPECT().Retrieve(s.ctx, s.albumURL.String(), htmlContentType).Return(nil, expectedError)
 
-	reader, err := s.albumScrapper.Retrieve(s.albumURL.String())
+	reader, err := s.albumScrapper.Retrieve(s.ctx, s.albumURL.String(), htmlContentType)
 
 	s.Error(err)
 	s.Equal(expectedError, err)
@@ -241,7 +259,7 @@ func (s *TestalbumScrapperSuite) TestFind_Error() {
 func (s *TestalbumScrapperSuite) TestSave() {
 	mockReader := bytes.NewReader([]byte("mock response data"))
 
-	err := s.albumScrapper.Save(mockReader, &model.Track{})
+	err := s.albumScrapper.Save(s.ctx, mockReader, &model.Track{})
 	s.NoError(err)
 }
 
@@ -255,52 +273,58 @@ func (s *TestalbumScrapperSuite) TestExecute_Success() {
 		return mockExecuteClient
 	}
 
-	mockReader := bytes.NewReader([]byte(validAlbumExample))
-	s.mockHttpClient.EXPECT().Retrieve(s.albumURL.String()).Return(mockReader, nil)
+	mockReader := newMockResponse([]byte(validAlbumExample))
+	s.mockHttpClient.EXPECT().Retrieve(s.ctx, s.albumURL.String(), htmlContentType).Return(mockReader, nil)
 
 	mockNode, _ := html.Parse(bytes.NewReader([]byte(validAlbumExample)))
 	s.mockParseClient.EXPECT().Parse(mockReader).Return(mockNode, nil)
 
-	err := s.albumScrapper.Execute(s.albumURL)
+	err := s.albumScrapper.Execute(s.ctx, s.albumURL)
 
 	s.NoError(err)
 	s.Equal(len(s.albumScrapper.TrackList), mockExecuteClient.ExecuteCalls)
+	s.True(isClosed(mockReader), "page body should be closed")
 }
 
 func (s *TestalbumScrapperSuite) TestExecute_RetrieveError() {
+	observer := &recordingObserver{}
+	s.albumScrapper.SetObserver(observer)
 	mockError := errors.New("retrieve error")
-	s.mockHttpClient.EXPECT().Retrieve(s.albumURL.String()).Return(nil, mockError)
+	s.mockHttpClient.EXPECT().Retrieve(s.ctx, s.albumURL.String(), htmlContentType).Return(nil, mockError)
 
-	err := s.albumScrapper.Execute(s.albumURL)
+	err := s.albumScrapper.Execute(s.ctx, s.albumURL)
 
 	s.Error(err)
 	s.Equal(mockError, err)
+	s.Equal([]string{s.albumURL.String()}, observer.failedAlbums)
 }
 
 func (s *TestalbumScrapperSuite) TestExecute_ParseError() {
-	mockReader := bytes.NewReader([]byte(validExample))
-	s.mockHttpClient.EXPECT().Retrieve(s.albumURL.String()).Return(mockReader, nil)
+	mockReader := newMockResponse([]byte(validExample))
+	s.mockHttpClient.EXPECT().Retrieve(s.ctx, s.albumURL.String(), htmlContentType).Return(mockReader, nil)
 
 	mockError := errors.New("parse error")
 	s.mockParseClient.EXPECT().Parse(mockReader).Return(nil, mockError)
 
-	err := s.albumScrapper.Execute(s.albumURL)
+	err := s.albumScrapper.Execute(s.ctx, s.albumURL)
 
 	s.Error(err)
 	s.Equal(mockError, err)
+	s.True(isClosed(mockReader), "page body should be closed")
 }
 
 func (s *TestalbumScrapperSuite) TestExecute_FindError() {
-	mockReader := bytes.NewReader([]byte(invalidExample))
-	s.mockHttpClient.EXPECT().Retrieve(s.albumURL.String()).Return(mockReader, nil)
+	mockReader := newMockResponse([]byte(invalidExample))
+	s.mockHttpClient.EXPECT().Retrieve(s.ctx, s.albumURL.String(), htmlContentType).Return(mockReader, nil)
 
 	mockNode, _ := html.Parse(mockReader)
 	s.mockParseClient.EXPECT().Parse(mockReader).Return(mockNode, nil)
 
-	err := s.albumScrapper.Execute(s.albumURL)
+	err := s.albumScrapper.Execute(s.ctx, s.albumURL)
 
 	s.NoError(err)
 	s.Equal(0, len(s.albumScrapper.TrackList))
+	s.True(isClosed(mockReader), "page body should be closed")
 }
 
 func (s *TestalbumScrapperSuite) TestExecute_SaveError() {
@@ -315,14 +339,206 @@ func (s *TestalbumScrapperSuite) TestExecute_SaveError() {
 		return mockExecuteClient
 	}
 
-	mockReader := bytes.NewReader([]byte(validAlbumExample))
-	s.mockHttpClient.EXPECT().Retrieve(s.albumURL.String()).Return(mockReader, nil)
+	mockReader := newMockResponse([]byte(validAlbumExample))
+	s.mockHttpClient.EXPECT().Retrieve(s.ctx, s.albumURL.String(), htmlContentType).Return(mockReader, nil)
 
 	mockNode, _ := html.Parse(mockReader)
 	s.mockParseClient.EXPECT().Parse(mockReader).Return(mockNode, nil)
 
-	err := s.albumScrapper.Execute(s.albumURL)
+	err := s.albumScrapper.Execute(s.ctx, s.albumURL)
 
-	s.Error(err)
-	s.Equal(mockedError, err)
+	s.ErrorIs(err, mockedError)
+	s.Greater(len(s.albumScrapper.TrackList), 1)
+	s.Equal(len(s.albumScrapper.TrackList), mockExecuteClient.ExecuteCalls, "a failed track should not stop the album")
+	s.True(isClosed(mockReader), "page body should be closed")
+}
+
+func (s *TestalbumScrapperSuite) TestExecute_ContextCancelled() {
+	ctx, cancel := context.WithCancel(s.ctx)
+	defer cancel()
+
+	mockExecuteClient := &mockTrackScrapper{
+		ExecuteFunc: func() error {
+			cancel()
+			return nil
+		},
+	}
+	s.albumScrapper.executeClient = func(httpClient Retriever, parseClient Parser, saveClient Saver, albumCatalog album_catalog.AlbumCatalog) Executer {
+		return mockExecuteClient
+	}
+
+	mockReader := newMockResponse([]byte(validAlbumExample))
+	s.mockHttpClient.EXPECT().Retrieve(ctx, s.albumURL.String(), htmlContentType).Return(mockReader, nil)
+
+	mockNode, _ := html.Parse(bytes.NewReader([]byte(validAlbumExample)))
+	s.mockParseClient.EXPECT().Parse(mockReader).Return(mockNode, nil)
+
+	err := s.albumScrapper.Execute(ctx, s.albumURL)
+
+	s.ErrorIs(err, context.Canceled)
+	s.Equal(1, mockExecuteClient.ExecuteCalls)
+	s.True(isClosed(mockReader), "page body should be closed")
+}
+
+func (s *TestalbumScrapperSuite) TestFind_TrAlbum() {
+	nodes, err := html.Parse(bytes.NewReader([]byte(validAlbumTrAlbumExample)))
+	s.NoError(err)
+
+	err = s.albumScrapper.Find(nodes)
+
+	s.NoError(err)
+	s.Require().Len(s.albumScrapper.Tracks, 3)
+	s.Equal(&model.Track{
+		Title:       "Elbow",
+		TrackNumber: 1,
+		Artist:      "King Gizzard & The Lizard Wizard",
+		Album:       toPointer("12 Bar Bruise"),
+		AlbumArtist: "King Gizzard & The Lizard Wizard",
+		TrackTotal:  3,
+		ReleaseYear: 2012,
+		ArtID:       1846339374,
+		TrackID:     3749823254,
+		AlbumID:     2765388374,
+		Duration:    159.88,
+		URL:         "https://kinggizzard.bandcamp.com/track/elbow",
+		DownloadURL: "https://t4.bcbits.com/stream/b77ce644d30f5a71778080be8c194c19/mp3-128/3749823254?p=0&ts=1728551843&t=dd8cc7cd9d747ac5be9c0a202fea450a5aa08944&token=1728551843_656b69850113f6ea23cd1e4321e6d148a256413b",
+	}, s.albumScrapper.Tracks[0])
+	s.Equal(int64(2), s.albumScrapper.Tracks[1].TrackNumber)
+	s.Equal("Nein", s.albumScrapper.Tracks[2].Title)
+	s.Empty(s.albumScrapper.Tracks[2].DownloadURL)
+	s.Equal([]string{"/track/elbow", "/track/muckraker", "/track/nein"}, s.albumScrapper.TrackList)
+}
+
+func (s *TestalbumScrapperSuite) TestExecute_TrAlbum() {
+	mockDownloadClient := &mockTrackDownloader{
+		DownloadFunc: func(track *model.Track) error {
+			return nil
+		},
+	}
+	s.albumScrapper.downloadClient = func(httpClient Retriever, parseClient Parser, saveClient Saver, albumCatalog album_catalog.AlbumCatalog) Downloader {
+		return mockDownloadClient
+	}
+	mockExecuteClient := &mockTrackScrapper{
+		ExecuteFunc: func() error {
+			return nil
+		},
+	}
+	s.albumScrapper.executeClient = func(httpClient Retriever, parseClient Parser, saveClient Saver, albumCatalog album_catalog.AlbumCatalog) Executer {
+		return mockExecuteClient
+	}
+
+	mockReader := newMockResponse([]byte(validAlbumTrAlbumExample))
+	s.mockHttpClient.EXPECT().Retrieve(s.ctx, s.albumURL.String(), htmlContentType).Return(mockReader, nil).Times(1)
+
+	mockNode, _ := html.Parse(bytes.NewReader([]byte(validAlbumTrAlbumExample)))
+	s.mockParseClient.EXPECT().Parse(mockReader).Return(mockNode, nil)
+
+	mockArtworkReader := newMockResponse([]byte("mock artwork"))
+	s.mockHttpClient.EXPECT().Retrieve(s.ctx, "https://f4.bcbits.com/img/a1846339374_10.jpg", jpegContentType).Return(mockArtworkReader, nil).Times(1)
+	observer := &progressObserver{}
+	s.albumScrapper.SetObserver(observer)
+
+	err := s.albumScrapper.Execute(s.ctx, s.albumURL)
+
+	s.NoError(err)
+	s.Equal([]string{s.albumURL.String()}, observer.startedAlbums)
+	for _, track := range s.albumScrapper.Tracks {
+		s.Equal([]byte("mock artwork"), track.Artwork, "every track should share the album artwork")
+		s.True(track.ArtworkFetched)
+	}
+	s.True(isClosed(mockArtworkReader), "artwork body should be closed")
+	s.Require().Len(mockDownloadClient.Tracks, 2)
+	s.Equal("Elbow", mockDownloadClient.Tracks[0].Title)
+	s.Equal("Muckraker", mockDownloadClient.Tracks[1].Title)
+	s.Equal(1, mockExecuteClient.ExecuteCalls, "only the track without stream URL goes through its page")
+	s.Equal("https://kinggizzard.bandcamp.com/track/nein", mockExecuteClient.URL)
+	s.True(isClosed(mockReader), "page body should be closed")
+}
+
+func (s *TestalbumScrapperSuite) TestExecute_TrAlbumArtworkError() {
+	mockDownloadClient := &mockTrackDownloader{
+		DownloadFunc: func(track *model.Track) error {
+			return nil
+		},
+	}
+	s.albumScrapper.downloadClient = func(httpClient Retriever, parseClient Parser, saveClient Saver, albumCatalog album_catalog.AlbumCatalog) Downloader {
+		return mockDownloadClient
+	}
+	s.albumScrapper.executeClient = func(httpClient Retriever, parseClient Parser, saveClient Saver, albumCatalog album_catalog.AlbumCatalog) Executer {
+		return &mockTrackScrapper{ExecuteFunc: func() error { return nil }}
+	}
+
+	mockReader := newMockResponse([]byte(validAlbumTrAlbumExample))
+	s.mockHttpClient.EXPECT().Retrieve(s.ctx, s.albumURL.String(), htmlContentType).Return(mockReader, nil)
+	mockNode, _ := html.Parse(bytes.NewReader([]byte(validAlbumTrAlbumExample)))
+	s.mockParseClient.EXPECT().Parse(mockReader).Return(mockNode, nil)
+	s.mockHttpClient.EXPECT().Retrieve(s.ctx, "https://f4.bcbits.com/img/a1846339374_10.jpg", jpegContentType).Return(nil, errors.New("artwork error")).Times(1)
+
+	err := s.albumScrapper.Execute(s.ctx, s.albumURL)
+
+	s.NoError(err, "artwork is optional")
+	s.Require().Len(mockDownloadClient.Tracks, 2)
+	for _, track := range mockDownloadClient.Tracks {
+		s.Nil(track.Artwork)
+		s.True(track.ArtworkFetched, "the tracks should not fetch the cover the album could not")
+	}
+}
+
+func (s *TestalbumScrapperSuite) TestExecute_TrAlbumDownloadError() {
+	mockedError := errors.New("download error")
+	mockDownloadClient := &mockTrackDownloader{
+		DownloadFunc: func(track *model.Track) error {
+			return mockedError
+		},
+	}
+	s.albumScrapper.downloadClient = func(httpClient Retriever, parseClient Parser, saveClient Saver, albumCatalog album_catalog.AlbumCatalog) Downloader {
+		return mockDownloadClient
+	}
+	mockExecuteClient := &mockTrackScrapper{
+		ExecuteFunc: func() error {
+			return nil
+		},
+	}
+	s.albumScrapper.executeClient = func(httpClient Retriever, parseClient Parser, saveClient Saver, albumCatalog album_catalog.AlbumCatalog) Executer {
+		return mockExecuteClient
+	}
+
+	mockReader := newMockResponse([]byte(validAlbumTrAlbumExample))
+	s.mockHttpClient.EXPECT().Retrieve(s.ctx, s.albumURL.String(), htmlContentType).Return(mockReader, nil)
+
+	mockNode, _ := html.Parse(bytes.NewReader([]byte(validAlbumTrAlbumExample)))
+	s.mockParseClient.EXPECT().Parse(mockReader).Return(mockNode, nil)
+
+	s.mockHttpClient.EXPECT().Retrieve(s.ctx, "https://f4.bcbits.com/img/a1846339374_10.jpg", jpegContentType).Return(newMockResponse([]byte("mock artwork")), nil)
+
+	err := s.albumScrapper.Execute(s.ctx, s.albumURL)
+
+	s.ErrorIs(err, mockedError)
+	streamed := 0
+	for _, track := range s.albumScrapper.Tracks {
+		if track.DownloadURL != "" {
+			streamed++
+		}
+	}
+	s.Greater(streamed, 1)
+	s.Len(mockDownloadClient.Tracks, streamed, "a failed track should not stop the album")
+	s.Equal(1, mockExecuteClient.ExecuteCalls, "the track without stream URL is still visited")
+}
+
+func (s *TestalbumScrapperSuite) Test_newTrackScrapper_Options() {
+	options := Options{ArtworkSize: 2, Layout: layout.MustParse(layout.Presets["plex"])}
+	s.albumScrapper.SetOptions(options)
+
+	trackScrapper := s.albumScrapper.newTrackScrapper(s.mockHttpClient, s.mockParseClient, s.mockSaveClient, s.albumCatalog)
+
+	s.Equal(options, trackScrapper.options)
+}
+
+func (s *TestalbumScrapperSuite) Test_newTrackScrapper_Observer() {
+	observer := &recordingObserver{}
+	s.albumScrapper.SetObserver(observer)
+
+	trackScrapper := s.albumScrapper.newTrackScrapper(s.mockHttpClient, s.mockParseClient, s.mockSaveClient, s.albumCatalog)
+
+	s.Same(observer, trackScrapper.observer)
 }
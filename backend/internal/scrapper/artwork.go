@@ -0,0 +1,34 @@
+package scrapper
+
+import (
+	"context"
+	"io"
+	"log"
+
+	"github.com/josedelrio85/bndcmp_downloader/internal/bandcamp"
+)
+
+const maxArtworkBytes = 20 << 20
+
+// fetchArtwork downloads the cover identified by artID. Artwork is optional, so a
+// failure is logged and the tracks are saved without it.
+func fetchArtwork(ctx context.Context, httpClient Retriever, artID int64, size int) []byte {
+	if artID == 0 {
+		return nil
+	}
+
+	artworkURL := bandcamp.ArtworkURL(artID, size)
+	reader, err := httpClient.Retrieve(ctx, artworkURL, jpegContentType)
+	if err != nil {
+		log.Printf("Error retrieving artwork from URL %s: %v", artworkURL, err)
+		return nil
+	}
+	defer reader.Close()
+
+	artwork, err := io.ReadAll(io.LimitReader(reader, maxArtworkBytes))
+	if err != nil {
+		log.Printf("Error reading artwork from URL %s: %v", artworkURL, err)
+		return nil
+	}
+	return artwork
+}
@@ -1,6 +1,7 @@
 package scrapper
 
 import (
+	"context"
 	"encoding/json"
 	io "io"
 	"log"
@@ -11,6 +12,7 @@ import (
 	"github.com/josedelrio85/bndcmp_downloader/internal/album_catalog"
 	"github.com/josedelrio85/bndcmp_downloader/internal/bandcamp"
 	model "github.com/josedelrio85/bndcmp_downloader/internal/model"
+	"github.com/josedelrio85/bndcmp_downloader/internal/retriever"
 	html "golang.org/x/net/html"
 )
 
@@ -21,23 +23,39 @@ type DiscographyScrapper struct {
 	saveClient    Saver
 	executeClient func(Retriever, Parser, Saver, album_catalog.AlbumCatalog) Executer
 	albumCatalog  album_catalog.AlbumCatalog
+	options       Options
+	observer      Observer
 }
 
 func NewDiscographyScrapper(httpClient Retriever, parseClient Parser, saveClient Saver, albumCatalog album_catalog.AlbumCatalog) *DiscographyScrapper {
-	return &DiscographyScrapper{
-		AlbumList:   []string{},
-		httpClient:  httpClient,
-		parseClient: parseClient,
-		saveClient:  saveClient,
-		executeClient: func(httpClient Retriever, parseClient Parser, saveClient Saver, albumCatalog album_catalog.AlbumCatalog) Executer {
-			return NewAlbumScrapper(httpClient, parseClient, saveClient, albumCatalog)
-		},
+	discographyScrapper := &DiscographyScrapper{
+		AlbumList:    []string{},
+		httpClient:   httpClient,
+		parseClient:  parseClient,
+		saveClient:   saveClient,
 		albumCatalog: albumCatalog,
+		options:      DefaultOptions(),
+		observer:     nopObserver{},
 	}
+	discographyScrapper.executeClient = func(httpClient Retriever, parseClient Parser, saveClient Saver, albumCatalog album_catalog.AlbumCatalog) Executer {
+		albumScrapper := NewAlbumScrapper(httpClient, parseClient, saveClient, albumCatalog)
+		albumScrapper.SetOptions(discographyScrapper.options)
+		albumScrapper.SetObserver(discographyScrapper.observer)
+		return albumScrapper
+	}
+	return discographyScrapper
+}
+
+func (a *DiscographyScrapper) SetOptions(options Options) {
+	a.options = options
 }
 
-func (a *DiscographyScrapper) Retrieve(url string) (io.Reader, error) {
-	return a.httpClient.Retrieve(url)
+func (a *DiscographyScrapper) SetObserver(observer Observer) {
+	a.observer = observer
+}
+
+func (a *DiscographyScrapper) Retrieve(ctx context.Context, url string, accept string) (*retriever.Response, error) {
+	return a.httpClient.Retrieve(ctx, url, accept)
 }
 
 func (a *DiscographyScrapper) Parse(data io.Reader) (*html.Node, error) {
@@ -118,22 +136,23 @@ func (a *DiscographyScrapper) processAlbumList() []string {
 	return result
 }
 
-func (a *DiscographyScrapper) Save(data io.Reader, track *model.Track) error {
+func (a *DiscographyScrapper) Save(ctx context.Context, data io.Reader, track *model.Track) error {
 	return nil
 }
 
-func (a *DiscographyScrapper) Execute(discographyURL *url.URL) error {
+func (a *DiscographyScrapper) Execute(ctx context.Context, discographyURL *url.URL) error {
 	log.Printf("Starting discography scrapper for URL: %s", discographyURL.String())
 	if len(a.AlbumList) > 0 {
 		a.AlbumList = []string{}
 	}
-	reader, err := a.Retrieve(discographyURL.String())
+	reader, err := a.Retrieve(ctx, discographyURL.String(), htmlContentType)
 	if err != nil {
 		log.Printf("Error retrieving discography page: %v", err)
 		return err
 	}
 
 	node, err := a.Parse(reader)
+	reader.Close()
 	if err != nil {
 		log.Printf("Error parsing discography HTML: %v", err)
 		return err
@@ -150,14 +169,29 @@ func (a *DiscographyScrapper) Execute(discographyURL *url.URL) error {
 		Host:   discographyURL.Host,
 	}
 	log.Printf("%d albums to download \n", len(a.AlbumList))
+	var failures []error
 	for _, album := range a.AlbumList {
+		if err := ctx.Err(); err != nil {
+			log.Printf("Discography scrapper cancelled: %v", err)
+			return err
+		}
 		albumURL := baseURL.ResolveReference(&url.URL{Path: album})
+		if checkpoint, ok := a.observer.(Checkpoint); ok && checkpoint.Completed(albumURL.String()) {
+			log.Printf("Album %s already completed, skipping", albumURL.String())
+			continue
+		}
 		log.Printf("Retrieving album: %s", albumURL.String())
 		albumScrapper := a.executeClient(a.httpClient, a.parseClient, a.saveClient, a.albumCatalog)
-		if err := albumScrapper.Execute(albumURL); err != nil {
+		if err := albumScrapper.Execute(ctx, albumURL); err != nil {
+			if ctx.Err() != nil {
+				return err
+			}
+			// the album is not completed, a resumed download retries its failed tracks
 			log.Printf("Error executing album scrapper for %s: %v", albumURL.String(), err)
-			return err
+			failures = append(failures, err)
+			continue
 		}
+		a.observer.AlbumCompleted(albumURL.String())
 	}
-	return nil
+	return failed(failures, len(a.AlbumList), "albums")
 }
@@ -2,14 +2,17 @@ package scrapper
 
 import (
 	"bytes"
+	"context"
 	_ "embed"
 	"errors"
+	"io"
 	"net/url"
 	"testing"
 
 	gomock "github.com/golang/mock/gomock"
 	"github.com/josedelrio85/bndcmp_downloader/internal/album_catalog"
 	model "github.com/josedelrio85/bndcmp_downloader/internal/model"
+	"github.com/josedelrio85/bndcmp_downloader/internal/retriever"
 	"github.com/stretchr/testify/suite"
 	html "golang.org/x/net/html"
 )
@@ -27,13 +30,14 @@ type mockAlbumScrapper struct {
 	URL          *url.URL
 }
 
-func (m *mockAlbumScrapper) Execute(url *url.URL) error {
+func (m *mockAlbumScrapper) Execute(ctx context.Context, url *url.URL) error {
 	m.ExecuteCalls++
 	return m.ExecuteFunc()
 }
 
 type TestDiscographyScrapperSuite struct {
 	suite.Suite
+	ctx                 context.Context
 	controller          *gomock.Controller
 	mockHttpClient      *MockRetriever
 	mockParseClient     *MockParser
@@ -44,6 +48,7 @@ type TestDiscographyScrapperSuite struct {
 }
 
 func (s *TestDiscographyScrapperSuite) SetupTest() {
+	s.ctx = context.Background()
 	s.controller = gomock.NewController(s.T())
 	s.mockHttpClient = NewMockRetriever(s.controller)
 	s.mockParseClient = NewMockParser(s.controller)
@@ -63,9 +68,9 @@ func (s *TestDiscographyScrapperSuite) TearDownTest() {
 
 func (s *TestDiscographyScrapperSuite) TestRetrieve_Success() {
 	mockResponse := []byte("mock response data")
-	s.mockHttpClient.EXPECT().Retrieve(s.discographyURL.String()).Return(bytes.NewReader(mockResponse), nil)
+	s.mockHttpClient.EXPECT().Retrieve(s.ctx, s.discographyURL.String(), htmlContentType).Return(newMockResponse(mockResponse), nil)
 
-	reader, err := s.DiscographyScrapper.Retrieve(s.discographyURL.String())
+	reader, err := s.DiscographyScrapper.Retrieve(s.ctx, s.discographyURL.String(), htmlContentType)
 
 	s.NoError(err)
 	s.NotNil(reader)
@@ -73,9 +78,9 @@ func (s *TestDiscographyScrapperSuite) TestRetrieve_Success() {
 
 func (s *TestDiscographyScrapperSuite) TestRetrieve_Error() {
 	expectedError := errors.New("failed to retrieve track")
-	s.mockHttpClient.EXPECT().Retrieve(s.discographyURL.String()).Return(nil, expectedError)
+	s.mockHttpClient.EXPECT().Retrieve(s.ctx, s.discographyURL.String(), htmlContentType).Return(nil, expectedError)
 
-	reader, err := s.DiscographyScrapper.Retrieve(s.discographyURL.String())
+	reader, err := s.DiscographyScrapper.Retrieve(s.ctx, s.discographyURL.String(), htmlContentType)
 
 	s.Error(err)
 	s.Equal(expectedError, err)
@@ -278,7 +283,7 @@ func (s *TestDiscographyScrapperSuite) TestFind_Error() {
 func (s *TestDiscographyScrapperSuite) TestSave() {
 	mockReader := bytes.NewReader([]byte("mock response data"))
 
-	err := s.DiscographyScrapper.Save(mockReader, &model.Track{})
+	err := s.DiscographyScrapper.Save(s.ctx, mockReader, &model.Track{})
 	s.NoError(err)
 }
 
@@ -292,52 +297,86 @@ func (s *TestDiscographyScrapperSuite) TestExecute_Success() {
 		return mockExecuteClient
 	}
 
-	mockReader := bytes.NewReader([]byte(validDiscographyExample))
-	s.mockHttpClient.EXPECT().Retrieve(s.discographyURL.String()).Return(mockReader, nil)
+	mockReader := newMockResponse([]byte(validDiscographyExample))
+	s.mockHttpClient.EXPECT().Retrieve(s.ctx, s.discographyURL.String(), htmlContentType).Return(mockReader, nil)
 
 	mockNode, _ := html.Parse(bytes.NewReader([]byte(validDiscographyExample)))
 	s.mockParseClient.EXPECT().Parse(mockReader).Return(mockNode, nil)
 
-	err := s.DiscographyScrapper.Execute(s.discographyURL)
+	err := s.DiscographyScrapper.Execute(s.ctx, s.discographyURL)
 
 	s.NoError(err)
 	s.Equal(len(s.DiscographyScrapper.AlbumList), mockExecuteClient.ExecuteCalls)
+	s.True(isClosed(mockReader), "page body should be closed")
+}
+
+func (s *TestDiscographyScrapperSuite) TestExecute_Checkpoint() {
+	mockExecuteClient := &mockAlbumScrapper{
+		ExecuteFunc: func() error {
+			return nil
+		},
+	}
+	s.DiscographyScrapper.executeClient = func(httpClient Retriever, parseClient Parser, saveClient Saver, albumCatalog album_catalog.AlbumCatalog) Executer {
+		return mockExecuteClient
+	}
+	s.mockHttpClient.EXPECT().Retrieve(s.ctx, s.discographyURL.String(), htmlContentType).DoAndReturn(func(ctx context.Context, url string, accept string) (*retriever.Response, error) {
+		return newMockResponse([]byte(validDiscographyExample)), nil
+	}).Times(2)
+	s.mockParseClient.EXPECT().Parse(gomock.Any()).DoAndReturn(func(data io.Reader) (*html.Node, error) {
+		return html.Parse(bytes.NewReader([]byte(validDiscographyExample)))
+	}).Times(2)
+
+	observer := &recordingObserver{}
+	s.DiscographyScrapper.SetObserver(observer)
+	s.Require().NoError(s.DiscographyScrapper.Execute(s.ctx, s.discographyURL))
+	s.Require().Greater(len(observer.albums), 2)
+	s.Equal(len(s.DiscographyScrapper.AlbumList), len(observer.albums), "every album should be reported once completed")
+
+	resumed := &checkpointObserver{completed: map[string]bool{observer.albums[0]: true, observer.albums[1]: true}}
+	s.DiscographyScrapper.SetObserver(resumed)
+	mockExecuteClient.ExecuteCalls = 0
+	s.Require().NoError(s.DiscographyScrapper.Execute(s.ctx, s.discographyURL))
+
+	s.Equal(len(observer.albums)-2, mockExecuteClient.ExecuteCalls, "completed albums should be skipped")
+	s.Equal(observer.albums[2:], resumed.albums)
 }
 
 func (s *TestDiscographyScrapperSuite) TestExecute_RetrieveError() {
 	mockError := errors.New("retrieve error")
-	s.mockHttpClient.EXPECT().Retrieve(s.discographyURL.String()).Return(nil, mockError)
+	s.mockHttpClient.EXPECT().Retrieve(s.ctx, s.discographyURL.String(), htmlContentType).Return(nil, mockError)
 
-	err := s.DiscographyScrapper.Execute(s.discographyURL)
+	err := s.DiscographyScrapper.Execute(s.ctx, s.discographyURL)
 
 	s.Error(err)
 	s.Equal(mockError, err)
 }
 
 func (s *TestDiscographyScrapperSuite) TestExecute_ParseError() {
-	mockReader := bytes.NewReader([]byte(validExample))
-	s.mockHttpClient.EXPECT().Retrieve(s.discographyURL.String()).Return(mockReader, nil)
+	mockReader := newMockResponse([]byte(validExample))
+	s.mockHttpClient.EXPECT().Retrieve(s.ctx, s.discographyURL.String(), htmlContentType).Return(mockReader, nil)
 
 	mockError := errors.New("parse error")
 	s.mockParseClient.EXPECT().Parse(mockReader).Return(nil, mockError)
 
-	err := s.DiscographyScrapper.Execute(s.discographyURL)
+	err := s.DiscographyScrapper.Execute(s.ctx, s.discographyURL)
 
 	s.Error(err)
 	s.Equal(mockError, err)
+	s.True(isClosed(mockReader), "page body should be closed")
 }
 
 func (s *TestDiscographyScrapperSuite) TestExecute_FindError() {
-	mockReader := bytes.NewReader([]byte(invalidExample))
-	s.mockHttpClient.EXPECT().Retrieve(s.discographyURL.String()).Return(mockReader, nil)
+	mockReader := newMockResponse([]byte(invalidExample))
+	s.mockHttpClient.EXPECT().Retrieve(s.ctx, s.discographyURL.String(), htmlContentType).Return(mockReader, nil)
 
 	mockNode, _ := html.Parse(mockReader)
 	s.mockParseClient.EXPECT().Parse(mockReader).Return(mockNode, nil)
 
-	err := s.DiscographyScrapper.Execute(s.discographyURL)
+	err := s.DiscographyScrapper.Execute(s.ctx, s.discographyURL)
 
 	s.NoError(err)
 	s.Equal(0, len(s.DiscographyScrapper.AlbumList))
+	s.True(isClosed(mockReader), "page body should be closed")
 }
 
 func (s *TestDiscographyScrapperSuite) TestExecute_SaveError() {
@@ -352,14 +391,47 @@ func (s *TestDiscographyScrapperSuite) TestExecute_SaveError() {
 		return mockExecuteClient
 	}
 
-	mockReader := bytes.NewReader([]byte(validDiscographyExample))
-	s.mockHttpClient.EXPECT().Retrieve(s.discographyURL.String()).Return(mockReader, nil)
+	mockReader := newMockResponse([]byte(validDiscographyExample))
+	s.mockHttpClient.EXPECT().Retrieve(s.ctx, s.discographyURL.String(), htmlContentType).Return(mockReader, nil)
 
 	mockNode, _ := html.Parse(mockReader)
 	s.mockParseClient.EXPECT().Parse(mockReader).Return(mockNode, nil)
 
-	err := s.DiscographyScrapper.Execute(s.discographyURL)
+	observer := &recordingObserver{}
+	s.DiscographyScrapper.SetObserver(observer)
 
-	s.Error(err)
-	s.Equal(mockedError, err)
+	err := s.DiscographyScrapper.Execute(s.ctx, s.discographyURL)
+
+	s.ErrorIs(err, mockedError)
+	s.Greater(len(s.DiscographyScrapper.AlbumList), 1)
+	s.Equal(len(s.DiscographyScrapper.AlbumList), mockExecuteClient.ExecuteCalls, "a failed album should not stop the discography")
+	s.Empty(observer.albums, "failed albums should not be completed")
+	s.True(isClosed(mockReader), "page body should be closed")
+}
+
+func (s *TestDiscographyScrapperSuite) TestExecute_ContextCancelled() {
+	ctx, cancel := context.WithCancel(s.ctx)
+	defer cancel()
+
+	mockExecuteClient := &mockAlbumScrapper{
+		ExecuteFunc: func() error {
+			cancel()
+			return nil
+		},
+	}
+	s.DiscographyScrapper.executeClient = func(httpClient Retriever, parseClient Parser, saveClient Saver, albumCatalog album_catalog.AlbumCatalog) Executer {
+		return mockExecuteClient
+	}
+
+	mockReader := newMockResponse([]byte(validDiscographyExample))
+	s.mockHttpClient.EXPECT().Retrieve(ctx, s.discographyURL.String(), htmlContentType).Return(mockReader, nil)
+
+	mockNode, _ := html.Parse(bytes.NewReader([]byte(validDiscographyExample)))
+	s.mockParseClient.EXPECT().Parse(mockReader).Return(mockNode, nil)
+
+	err := s.DiscographyScrapper.Execute(ctx, s.discographyURL)
+
+	s.ErrorIs(err, context.Canceled)
+	s.Equal(1, mockExecuteClient.ExecuteCalls)
+	s.True(isClosed(mockReader), "page body should be closed")
 }
@@ -0,0 +1,85 @@
+package scrapper
+
+import (
+	"errors"
+	"net/url"
+	"strings"
+
+	"github.com/josedelrio85/bndcmp_downloader/internal/album_catalog"
+	"github.com/josedelrio85/bndcmp_downloader/internal/model"
+)
+
+var ErrUnsupportedURL = errors.New("not a Bandcamp discography, album or track URL")
+
+// Factory creates the scrapper of a Bandcamp URL. Scrappers keep the state of their last
+// run, every download running at the same time needs its own.
+type Factory struct {
+	httpClient   Retriever
+	parseClient  Parser
+	saveClient   Saver
+	albumCatalog album_catalog.AlbumCatalog
+	options      Options
+}
+
+func NewFactory(httpClient Retriever, parseClient Parser, saveClient Saver, albumCatalog album_catalog.AlbumCatalog) *Factory {
+	return &Factory{
+		httpClient:   httpClient,
+		parseClient:  parseClient,
+		saveClient:   saveClient,
+		albumCatalog: albumCatalog,
+		options:      DefaultOptions(),
+	}
+}
+
+func (f *Factory) SetOptions(options Options) {
+	f.options = options
+}
+
+// New returns a scrapper for resourceURL reporting its tracks to observer, if any.
+func (f *Factory) New(resourceURL *url.URL, observer Observer) (Executer, error) {
+	if observer == nil {
+		observer = nopObserver{}
+	}
+	switch TypeOf(resourceURL) {
+	case Discography:
+		discographyScrapper := NewDiscographyScrapper(f.httpClient, f.parseClient, f.saveClient, f.albumCatalog)
+		discographyScrapper.SetOptions(f.options)
+		discographyScrapper.SetObserver(observer)
+		return discographyScrapper, nil
+	case Album:
+		albumScrapper := NewAlbumScrapper(f.httpClient, f.parseClient, f.saveClient, f.albumCatalog)
+		albumScrapper.SetOptions(f.options)
+		albumScrapper.SetObserver(observer)
+		return albumScrapper, nil
+	case Track:
+		trackScrapper := NewTrackScrapper(f.httpClient, f.parseClient, f.saveClient, f.albumCatalog)
+		trackScrapper.SetOptions(f.options)
+		trackScrapper.SetObserver(observer)
+		return trackScrapper, nil
+	default:
+		return nil, ErrUnsupportedURL
+	}
+}
+
+// Discard removes what the saver kept of track, for downloads stopped outside a scrapper,
+// e.g. a paused job cancelled.
+func (f *Factory) Discard(track *model.Track) {
+	if discarder, ok := f.saveClient.(Discarder); ok {
+		discarder.Discard(track)
+	}
+}
+
+// TypeOf tells the scrapper of a URL by its path: /music, /album/{album} or /track/{track}.
+func TypeOf(resourceURL *url.URL) ScrapType {
+	pathParts := strings.Split(strings.Trim(resourceURL.Path, "/"), "/")
+	switch {
+	case len(pathParts) == 1 && pathParts[0] == "music":
+		return Discography
+	case len(pathParts) == 2 && pathParts[0] == "album" && pathParts[1] != "":
+		return Album
+	case len(pathParts) == 2 && pathParts[0] == "track" && pathParts[1] != "":
+		return Track
+	default:
+		return Undefined
+	}
+}
@@ -0,0 +1,114 @@
+package scrapper
+
+import (
+	"net/url"
+	"testing"
+
+	"github.com/golang/mock/gomock"
+	"github.com/josedelrio85/bndcmp_downloader/internal/layout"
+	"github.com/josedelrio85/bndcmp_downloader/internal/model"
+	"github.com/stretchr/testify/suite"
+)
+
+type FactoryTestSuite struct {
+	suite.Suite
+	factory *Factory
+}
+
+func TestFactorySuite(t *testing.T) {
+	suite.Run(t, new(FactoryTestSuite))
+}
+
+func (s *FactoryTestSuite) SetupTest() {
+	s.factory = NewFactory(nil, nil, nil, nil)
+}
+
+func (s *FactoryTestSuite) TestNew() {
+	options := Options{ArtworkSize: 2, Layout: layout.MustParse(layout.Presets["plex"])}
+	s.factory.SetOptions(options)
+	observer := &recordingObserver{}
+
+	discography, err := s.factory.New(mustParseURL("https://kinggizzard.bandcamp.com/music"), observer)
+	s.Require().NoError(err)
+	s.Require().IsType(&DiscographyScrapper{}, discography)
+	s.Equal(options, discography.(*DiscographyScrapper).options)
+	s.Same(observer, discography.(*DiscographyScrapper).observer)
+
+	album, err := s.factory.New(mustParseURL("https://kinggizzard.bandcamp.com/album/12-bar-bruise"), observer)
+	s.Require().NoError(err)
+	s.Require().IsType(&AlbumScrapper{}, album)
+	s.Equal(options, album.(*AlbumScrapper).options)
+	s.Same(observer, album.(*AlbumScrapper).observer)
+
+	track, err := s.factory.New(mustParseURL("https://kinggizzard.bandcamp.com/track/elbow"), observer)
+	s.Require().NoError(err)
+	s.Require().IsType(&TrackScrapper{}, track)
+	s.Equal(options, track.(*TrackScrapper).options)
+	s.Same(observer, track.(*TrackScrapper).observer)
+
+	other, err := s.factory.New(mustParseURL("https://kinggizzard.bandcamp.com/merch"), observer)
+	s.ErrorIs(err, ErrUnsupportedURL)
+	s.Nil(other)
+}
+
+func (s *FactoryTestSuite) TestNew_NewScrapperEveryTime() {
+	albumURL := mustParseURL("https://kinggizzard.bandcamp.com/album/12-bar-bruise")
+
+	first, err := s.factory.New(albumURL, nopObserver{})
+	s.Require().NoError(err)
+	second, err := s.factory.New(albumURL, nopObserver{})
+	s.Require().NoError(err)
+
+	s.NotSame(first, second)
+}
+
+func (s *FactoryTestSuite) TestNew_WithoutObserver() {
+	track, err := s.factory.New(mustParseURL("https://kinggizzard.bandcamp.com/track/elbow"), nil)
+
+	s.Require().NoError(err)
+	s.Equal(nopObserver{}, track.(*TrackScrapper).observer)
+}
+
+func (s *FactoryTestSuite) TestTypeOf() {
+	tests := []struct {
+		url      string
+		expected ScrapType
+	}{
+		{url: "https://kinggizzard.bandcamp.com/music", expected: Discography},
+		{url: "https://kinggizzard.bandcamp.com/music/", expected: Discography},
+		{url: "https://kinggizzard.bandcamp.com/album/12-bar-bruise", expected: Album},
+		{url: "https://kinggizzard.bandcamp.com/track/elbow", expected: Track},
+		{url: "https://kinggizzard.bandcamp.com/album/", expected: Undefined},
+		{url: "https://kinggizzard.bandcamp.com/track/elbow/extra", expected: Undefined},
+		{url: "https://kinggizzard.bandcamp.com/", expected: Undefined},
+	}
+
+	for _, tt := range tests {
+		s.Run(tt.url, func() {
+			s.Equal(tt.expected, TypeOf(mustParseURL(tt.url)))
+		})
+	}
+}
+
+func mustParseURL(rawURL string) *url.URL {
+	parsed, err := url.Parse(rawURL)
+	if err != nil {
+		panic(err)
+	}
+	return parsed
+}
+
+func (s *FactoryTestSuite) TestDiscard() {
+	ctrl := gomock.NewController(s.T())
+	track := &model.Track{Title: "Elbow"}
+	discarder := NewMockDiscarder(ctrl)
+	discarder.EXPECT().Discard(track)
+	s.factory = NewFactory(nil, nil, struct {
+		Saver
+		Discarder
+	}{NewMockSaver(ctrl), discarder}, nil)
+
+	s.factory.Discard(track)
+
+	NewFactory(nil, nil, NewMockSaver(ctrl), nil).Discard(track)
+}
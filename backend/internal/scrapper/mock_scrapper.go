@@ -5,12 +5,14 @@
 package scrapper
 
 import (
+	context "context"
 	io "io"
 	url "net/url"
 	reflect "reflect"
 
 	gomock "github.com/golang/mock/gomock"
 	model "github.com/josedelrio85/bndcmp_downloader/internal/model"
+	retriever "github.com/josedelrio85/bndcmp_downloader/internal/retriever"
 	html "golang.org/x/net/html"
 )
 
@@ -38,17 +40,17 @@ func (m *MockScrapper) EXPECT() *MockScrapperMockRecorder {
 }
 
 // Execute mocks base method.
-func (m *MockScrapper) Execute(resourceURL *url.URL) error {
+func (m *MockScrapper) Execute(ctx context.Context, resourceURL *url.URL) error {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "Execute", resourceURL)
+	ret := m.ctrl.Call(m, "Execute", ctx, resourceURL)
 	ret0, _ := ret[0].(error)
 	return ret0
 }
 
 // Execute indicates an expected call of Execute.
-func (mr *MockScrapperMockRecorder) Execute(resourceURL interface{}) *gomock.Call {
+func (mr *MockScrapperMockRecorder) Execute(ctx, resourceURL interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Execute", reflect.TypeOf((*MockScrapper)(nil).Execute), resourceURL)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Execute", reflect.TypeOf((*MockScrapper)(nil).Execute), ctx, resourceURL)
 }
 
 // Find mocks base method.
@@ -81,32 +83,32 @@ func (mr *MockScrapperMockRecorder) Parse(data interface{}) *gomock.Call {
 }
 
 // Retrieve mocks base method.
-func (m *MockScrapper) Retrieve(url string) (io.Reader, error) {
+func (m *MockScrapper) Retrieve(ctx context.Context, url, accept string) (*retriever.Response, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "Retrieve", url)
-	ret0, _ := ret[0].(io.Reader)
+	ret := m.ctrl.Call(m, "Retrieve", ctx, url, accept)
+	ret0, _ := ret[0].(*retriever.Response)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
 // Retrieve indicates an expected call of Retrieve.
-func (mr *MockScrapperMockRecorder) Retrieve(url interface{}) *gomock.Call {
+func (mr *MockScrapperMockRecorder) Retrieve(ctx, url, accept interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Retrieve", reflect.TypeOf((*MockScrapper)(nil).Retrieve), url)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Retrieve", reflect.TypeOf((*MockScrapper)(nil).Retrieve), ctx, url, accept)
 }
 
 // Save mocks base method.
-func (m *MockScrapper) Save(data io.Reader, track *model.Track) error {
+func (m *MockScrapper) Save(ctx context.Context, data io.Reader, track *model.Track) error {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "Save", data, track)
+	ret := m.ctrl.Call(m, "Save", ctx, data, track)
 	ret0, _ := ret[0].(error)
 	return ret0
 }
 
 // Save indicates an expected call of Save.
-func (mr *MockScrapperMockRecorder) Save(data, track interface{}) *gomock.Call {
+func (mr *MockScrapperMockRecorder) Save(ctx, data, track interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Save", reflect.TypeOf((*MockScrapper)(nil).Save), data, track)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Save", reflect.TypeOf((*MockScrapper)(nil).Save), ctx, data, track)
 }
 
 // MockRetriever is a mock of Retriever interface.
@@ -133,18 +135,18 @@ func (m *MockRetriever) EXPECT() *MockRetrieverMockRecorder {
 }
 
 // Retrieve mocks base method.
-func (m *MockRetriever) Retrieve(url string) (io.Reader, error) {
+func (m *MockRetriever) Retrieve(ctx context.Context, url, accept string) (*retriever.Response, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "Retrieve", url)
-	ret0, _ := ret[0].(io.Reader)
+	ret := m.ctrl.Call(m, "Retrieve", ctx, url, accept)
+	ret0, _ := ret[0].(*retriever.Response)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
 // Retrieve indicates an expected call of Retrieve.
-func (mr *MockRetrieverMockRecorder) Retrieve(url interface{}) *gomock.Call {
+func (mr *MockRetrieverMockRecorder) Retrieve(ctx, url, accept interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Retrieve", reflect.TypeOf((*MockRetriever)(nil).Retrieve), url)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Retrieve", reflect.TypeOf((*MockRetriever)(nil).Retrieve), ctx, url, accept)
 }
 
 // MockParser is a mock of Parser interface.
@@ -222,6 +224,44 @@ func (mr *MockFinderMockRecorder) Find(node interface{}) *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Find", reflect.TypeOf((*MockFinder)(nil).Find), node)
 }
 
+// MockRangeRetriever is a mock of RangeRetriever interface.
+type MockRangeRetriever struct {
+	ctrl     *gomock.Controller
+	recorder *MockRangeRetrieverMockRecorder
+}
+
+// MockRangeRetrieverMockRecorder is the mock recorder for MockRangeRetriever.
+type MockRangeRetrieverMockRecorder struct {
+	mock *MockRangeRetriever
+}
+
+// NewMockRangeRetriever creates a new mock instance.
+func NewMockRangeRetriever(ctrl *gomock.Controller) *MockRangeRetriever {
+	mock := &MockRangeRetriever{ctrl: ctrl}
+	mock.recorder = &MockRangeRetrieverMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockRangeRetriever) EXPECT() *MockRangeRetrieverMockRecorder {
+	return m.recorder
+}
+
+// RetrieveRange mocks base method.
+func (m *MockRangeRetriever) RetrieveRange(ctx context.Context, url, accept string, offset int64, validator string) (*retriever.Response, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RetrieveRange", ctx, url, accept, offset, validator)
+	ret0, _ := ret[0].(*retriever.Response)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// RetrieveRange indicates an expected call of RetrieveRange.
+func (mr *MockRangeRetrieverMockRecorder) RetrieveRange(ctx, url, accept, offset, validator interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RetrieveRange", reflect.TypeOf((*MockRangeRetriever)(nil).RetrieveRange), ctx, url, accept, offset, validator)
+}
+
 // MockSaver is a mock of Saver interface.
 type MockSaver struct {
 	ctrl     *gomock.Controller
@@ -246,17 +286,90 @@ func (m *MockSaver) EXPECT() *MockSaverMockRecorder {
 }
 
 // Save mocks base method.
-func (m *MockSaver) Save(data io.Reader, track *model.Track) error {
+func (m *MockSaver) Save(ctx context.Context, data io.Reader, track *model.Track) error {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "Save", data, track)
+	ret := m.ctrl.Call(m, "Save", ctx, data, track)
 	ret0, _ := ret[0].(error)
 	return ret0
 }
 
 // Save indicates an expected call of Save.
-func (mr *MockSaverMockRecorder) Save(data, track interface{}) *gomock.Call {
+func (mr *MockSaverMockRecorder) Save(ctx, data, track interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Save", reflect.TypeOf((*MockSaver)(nil).Save), ctx, data, track)
+}
+
+// MockResumer is a mock of Resumer interface.
+type MockResumer struct {
+	ctrl     *gomock.Controller
+	recorder *MockResumerMockRecorder
+}
+
+// MockResumerMockRecorder is the mock recorder for MockResumer.
+type MockResumerMockRecorder struct {
+	mock *MockResumer
+}
+
+// NewMockResumer creates a new mock instance.
+func NewMockResumer(ctrl *gomock.Controller) *MockResumer {
+	mock := &MockResumer{ctrl: ctrl}
+	mock.recorder = &MockResumerMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockResumer) EXPECT() *MockResumerMockRecorder {
+	return m.recorder
+}
+
+// Partial mocks base method.
+func (m *MockResumer) Partial(track *model.Track) (int64, string) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Partial", track)
+	ret0, _ := ret[0].(int64)
+	ret1, _ := ret[1].(string)
+	return ret0, ret1
+}
+
+// Partial indicates an expected call of Partial.
+func (mr *MockResumerMockRecorder) Partial(track interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Save", reflect.TypeOf((*MockSaver)(nil).Save), data, track)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Partial", reflect.TypeOf((*MockResumer)(nil).Partial), track)
+}
+
+// MockDiscarder is a mock of Discarder interface.
+type MockDiscarder struct {
+	ctrl     *gomock.Controller
+	recorder *MockDiscarderMockRecorder
+}
+
+// MockDiscarderMockRecorder is the mock recorder for MockDiscarder.
+type MockDiscarderMockRecorder struct {
+	mock *MockDiscarder
+}
+
+// NewMockDiscarder creates a new mock instance.
+func NewMockDiscarder(ctrl *gomock.Controller) *MockDiscarder {
+	mock := &MockDiscarder{ctrl: ctrl}
+	mock.recorder = &MockDiscarderMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockDiscarder) EXPECT() *MockDiscarderMockRecorder {
+	return m.recorder
+}
+
+// Discard mocks base method.
+func (m *MockDiscarder) Discard(track *model.Track) {
+	m.ctrl.T.Helper()
+	m.ctrl.Call(m, "Discard", track)
+}
+
+// Discard indicates an expected call of Discard.
+func (mr *MockDiscarderMockRecorder) Discard(track interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Discard", reflect.TypeOf((*MockDiscarder)(nil).Discard), track)
 }
 
 // MockExecuter is a mock of Executer interface.
@@ -283,15 +396,52 @@ func (m *MockExecuter) EXPECT() *MockExecuterMockRecorder {
 }
 
 // Execute mocks base method.
-func (m *MockExecuter) Execute(resourceURL *url.URL) error {
+func (m *MockExecuter) Execute(ctx context.Context, resourceURL *url.URL) error {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "Execute", resourceURL)
+	ret := m.ctrl.Call(m, "Execute", ctx, resourceURL)
 	ret0, _ := ret[0].(error)
 	return ret0
 }
 
 // Execute indicates an expected call of Execute.
-func (mr *MockExecuterMockRecorder) Execute(resourceURL interface{}) *gomock.Call {
+func (mr *MockExecuterMockRecorder) Execute(ctx, resourceURL interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Execute", reflect.TypeOf((*MockExecuter)(nil).Execute), ctx, resourceURL)
+}
+
+// MockDownloader is a mock of Downloader interface.
+type MockDownloader struct {
+	ctrl     *gomock.Controller
+	recorder *MockDownloaderMockRecorder
+}
+
+// MockDownloaderMockRecorder is the mock recorder for MockDownloader.
+type MockDownloaderMockRecorder struct {
+	mock *MockDownloader
+}
+
+// NewMockDownloader creates a new mock instance.
+func NewMockDownloader(ctrl *gomock.Controller) *MockDownloader {
+	mock := &MockDownloader{ctrl: ctrl}
+	mock.recorder = &MockDownloaderMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockDownloader) EXPECT() *MockDownloaderMockRecorder {
+	return m.recorder
+}
+
+// Download mocks base method.
+func (m *MockDownloader) Download(ctx context.Context, track *model.Track) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Download", ctx, track)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Download indicates an expected call of Download.
+func (mr *MockDownloaderMockRecorder) Download(ctx, track interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Execute", reflect.TypeOf((*MockExecuter)(nil).Execute), resourceURL)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Download", reflect.TypeOf((*MockDownloader)(nil).Download), ctx, track)
 }
@@ -0,0 +1,66 @@
+package scrapper
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/josedelrio85/bndcmp_downloader/internal/model"
+)
+
+// ErrCancelled and ErrPaused are the causes of the context of a download stopped on
+// purpose, e.g. from the API, as opposed to interrupted. The track being saved is not
+// reported as failed. The partial file of a cancelled one is removed, a paused one is
+// resumed from it.
+var (
+	ErrCancelled = errors.New("download cancelled")
+	ErrPaused    = errors.New("download paused")
+)
+
+// Observer is told what happens to every track a scrapper goes through, e.g. to report
+// the progress of a download running in the background.
+type Observer interface {
+	TrackDownloaded(track *model.Track)
+	// TrackSkipped is told about the tracks already in the library and the ones Bandcamp
+	// does not stream.
+	TrackSkipped(track *model.Track)
+	// TrackFailed is also told about the track pages that cannot be read, with only the
+	// URL of the track known.
+	TrackFailed(track *model.Track, err error)
+	// AlbumFailed is told about the album pages that cannot be read.
+	AlbumFailed(albumURL string, err error)
+	// AlbumCompleted is told about the albums of a discography once all their tracks are.
+	AlbumCompleted(albumURL string)
+}
+
+// Checkpoint is implemented by the observers of downloads resumed after a restart, the
+// discography scrapper skips the albums they already completed.
+type Checkpoint interface {
+	Completed(albumURL string) bool
+}
+
+// Progress is implemented by the observers following a download live, it is told what
+// the scrapper is working on.
+type Progress interface {
+	AlbumStarted(albumURL string)
+	TrackStarted(track *model.Track)
+	// TrackProgress is told how many bytes of the track are written out of total, which
+	// is -1 when the server does not tell the size of the file.
+	TrackProgress(track *model.Track, written int64, total int64)
+}
+
+// failed combines the failures of the total tracks or albums of a download, already
+// reported to the observer one by one. It is nil when none failed.
+func failed(failures []error, total int, what string) error {
+	if len(failures) == 0 {
+		return nil
+	}
+	return fmt.Errorf("%d of %d %s failed: %w", len(failures), total, what, errors.Join(failures...))
+}
+
+type nopObserver struct{}
+
+func (nopObserver) TrackDownloaded(track *model.Track)        {}
+func (nopObserver) TrackSkipped(track *model.Track)           {}
+func (nopObserver) TrackFailed(track *model.Track, err error) {}
+func (nopObserver) AlbumFailed(albumURL string, err error)    {}
+func (nopObserver) AlbumCompleted(albumURL string)            {}
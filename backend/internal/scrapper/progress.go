@@ -0,0 +1,48 @@
+package scrapper
+
+import (
+	"io"
+
+	"github.com/josedelrio85/bndcmp_downloader/internal/model"
+	"github.com/josedelrio85/bndcmp_downloader/internal/retriever"
+)
+
+// progressInterval is how many bytes of a track are read between two progress reports.
+const progressInterval = 256 * 1024
+
+// progressReader reports to progress the bytes read from the body of an MP3 response.
+type progressReader struct {
+	io.ReadCloser
+	progress Progress
+	track    *model.Track
+	written  int64
+	total    int64
+	reported int64
+}
+
+// reportProgress makes the body of response report its progress, counting the bytes
+// of a resumed download already saved.
+func reportProgress(response *retriever.Response, progress Progress, track *model.Track) {
+	total := int64(-1)
+	if response.ContentLength >= 0 {
+		total = response.Offset + response.ContentLength
+	}
+	response.ReadCloser = &progressReader{
+		ReadCloser: response.ReadCloser,
+		progress:   progress,
+		track:      track,
+		written:    response.Offset,
+		total:      total,
+		reported:   response.Offset,
+	}
+}
+
+func (p *progressReader) Read(data []byte) (int, error) {
+	n, err := p.ReadCloser.Read(data)
+	p.written += int64(n)
+	if p.written-p.reported >= progressInterval || (err == io.EOF && p.written > p.reported) {
+		p.reported = p.written
+		p.progress.TrackProgress(p.track, p.written, p.total)
+	}
+	return n, err
+}
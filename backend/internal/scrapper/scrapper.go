@@ -1,13 +1,38 @@
 package scrapper
 
 import (
+	"context"
 	"io"
 	"net/url"
 
+	"github.com/josedelrio85/bndcmp_downloader/internal/bandcamp"
+	"github.com/josedelrio85/bndcmp_downloader/internal/layout"
 	"github.com/josedelrio85/bndcmp_downloader/internal/model"
+	"github.com/josedelrio85/bndcmp_downloader/internal/retriever"
 	"golang.org/x/net/html"
 )
 
+const (
+	htmlContentType = "text/html"
+	mp3ContentType  = "audio/mpeg"
+	jpegContentType = "image/jpeg"
+)
+
+// Options are the download settings shared by a scrapper and the ones it creates.
+type Options struct {
+	// ArtworkSize is the Bandcamp image format of the cover art.
+	ArtworkSize int
+	// Layout must be the one used by the saver, the catalog is looked up with it.
+	Layout *layout.Template
+}
+
+func DefaultOptions() Options {
+	return Options{
+		ArtworkSize: bandcamp.DefaultArtworkSize,
+		Layout:      layout.MustParse(layout.Default),
+	}
+}
+
 //go:generate mockgen -source=$GOFILE -package=$GOPACKAGE -destination=mock_$GOFILE
 type Scrapper interface {
 	Retriever
@@ -18,7 +43,7 @@ type Scrapper interface {
 }
 
 type Retriever interface {
-	Retrieve(url string) (io.Reader, error)
+	Retrieve(ctx context.Context, url string, accept string) (*retriever.Response, error)
 }
 
 type Parser interface {
@@ -29,10 +54,30 @@ type Finder interface {
 	Find(node *html.Node) error
 }
 
+// RangeRetriever is implemented by retrievers able to resume a download from a byte offset.
+type RangeRetriever interface {
+	RetrieveRange(ctx context.Context, url string, accept string, offset int64, validator string) (*retriever.Response, error)
+}
+
 type Saver interface {
-	Save(data io.Reader, track *model.Track) error
+	Save(ctx context.Context, data io.Reader, track *model.Track) error
+}
+
+// Resumer is implemented by savers that keep interrupted downloads to resume them.
+type Resumer interface {
+	Partial(track *model.Track) (offset int64, validator string)
+}
+
+// Discarder is implemented by savers that keep interrupted downloads, Discard removes
+// what they kept of track.
+type Discarder interface {
+	Discard(track *model.Track)
 }
 
 type Executer interface {
-	Execute(resourceURL *url.URL) error
+	Execute(ctx context.Context, resourceURL *url.URL) error
+}
+
+type Downloader interface {
+	Download(ctx context.Context, track *model.Track) error
 }
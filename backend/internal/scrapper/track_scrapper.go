@@ -1,25 +1,33 @@
 package scrapper
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"log"
 	"net/url"
-	"strings"
 
 	"github.com/josedelrio85/bndcmp_downloader/internal/album_catalog"
 	"github.com/josedelrio85/bndcmp_downloader/internal/bandcamp"
 	"github.com/josedelrio85/bndcmp_downloader/internal/model"
+	"github.com/josedelrio85/bndcmp_downloader/internal/retriever"
+	"github.com/josedelrio85/bndcmp_downloader/internal/sanitize"
 	"golang.org/x/net/html"
 )
 
+// maxResumeAttempts bounds how many times a dropped MP3 transfer is resumed in a row.
+const maxResumeAttempts = 3
+
 type TrackScrapper struct {
 	Track        *model.Track
 	httpClient   Retriever
 	parseClient  Parser
 	saveClient   Saver
 	albumCatalog album_catalog.AlbumCatalog
+	options      Options
+	observer     Observer
 }
 
 func NewTrackScrapper(httpClient Retriever, parseClient Parser, saveClient Saver, albumCatalog album_catalog.AlbumCatalog) *TrackScrapper {
@@ -29,11 +37,21 @@ func NewTrackScrapper(httpClient Retriever, parseClient Parser, saveClient Saver
 		parseClient:  parseClient,
 		saveClient:   saveClient,
 		albumCatalog: albumCatalog,
+		options:      DefaultOptions(),
+		observer:     nopObserver{},
 	}
 }
 
-func (t *TrackScrapper) Retrieve(url string) (io.Reader, error) {
-	return t.httpClient.Retrieve(url)
+func (t *TrackScrapper) SetOptions(options Options) {
+	t.options = options
+}
+
+func (t *TrackScrapper) SetObserver(observer Observer) {
+	t.observer = observer
+}
+
+func (t *TrackScrapper) Retrieve(ctx context.Context, url string, accept string) (*retriever.Response, error) {
+	return t.httpClient.Retrieve(ctx, url, accept)
 }
 
 func (t *TrackScrapper) Parse(data io.Reader) (*html.Node, error) {
@@ -41,40 +59,85 @@ func (t *TrackScrapper) Parse(data io.Reader) (*html.Node, error) {
 }
 
 func (t *TrackScrapper) Find(node *html.Node) error {
+	albumInfo, err := findTrAlbum(node)
+	if err != nil {
+		return err
+	}
+	if albumInfo != nil {
+		t.Track = albumInfo.ToTrack()
+	}
+	return nil
+}
+
+// findTrAlbum returns the data-tralbum of the page, or nil when there is none.
+func findTrAlbum(node *html.Node) (*bandcamp.TrAlbum, error) {
 	if node.Type == html.ElementNode && node.Data == "script" {
 		for _, z := range node.Attr {
 			if z.Key == "data-tralbum" {
 				var albumInfo bandcamp.TrAlbum
 				if err := json.Unmarshal([]byte(z.Val), &albumInfo); err != nil {
-					return err
+					return nil, err
 				}
-				t.Track = albumInfo.ToTrack()
-				return nil
+				albumInfo.AlbumTitle = findAlbumTitle(node)
+				return &albumInfo, nil
 			}
 		}
 	}
 
 	for c := node.FirstChild; c != nil; c = c.NextSibling {
-		if err := t.Find(c); err != nil {
-			return err
+		albumInfo, err := findTrAlbum(c)
+		if err != nil || albumInfo != nil {
+			return albumInfo, err
 		}
 	}
-	return nil
+	return nil, nil
 }
 
-func (t *TrackScrapper) Save(data io.Reader, track *model.Track) error {
-	return t.saveClient.Save(data, track)
+// findAlbumTitle reads the title of the release a track belongs to from the data-embed
+// published next to data-tralbum. Standalone tracks have none.
+func findAlbumTitle(node *html.Node) string {
+	for _, attr := range node.Attr {
+		if attr.Key == "data-embed" {
+			var embed bandcamp.Embed
+			if err := json.Unmarshal([]byte(attr.Val), &embed); err != nil {
+				log.Printf("Error unmarshalling data-embed: %v", err)
+				return ""
+			}
+			return embed.AlbumTitle
+		}
+	}
+	return ""
 }
 
-func (t *TrackScrapper) Execute(trackURL *url.URL) error {
+func (t *TrackScrapper) Save(ctx context.Context, data io.Reader, track *model.Track) error {
+	return t.saveClient.Save(ctx, data, track)
+}
+
+func (t *TrackScrapper) Execute(ctx context.Context, trackURL *url.URL) error {
 	log.Printf("Starting track scrapper for URL: %s", trackURL.String())
-	reader, err := t.Retrieve(trackURL.String())
+	if err := t.readPage(ctx, trackURL); err != nil {
+		if ctx.Err() == nil {
+			t.observer.TrackFailed(&model.Track{URL: trackURL.String()}, err)
+		}
+		return err
+	}
+
+	if t.Track != nil {
+		return t.download(ctx)
+	}
+
+	return nil
+}
+
+func (t *TrackScrapper) readPage(ctx context.Context, trackURL *url.URL) error {
+	reader, err := t.Retrieve(ctx, trackURL.String(), htmlContentType)
 	if err != nil {
 		log.Printf("Error retrieving URL %s: %v", trackURL.String(), err)
 		return err
 	}
 
 	node, err := t.Parse(reader)
+	reader.Close()
 	if err != nil {
 		log.Printf("Error parsing HTML content: %v", err)
 		return err
@@ -85,55 +148,177 @@ func (t *TrackScrapper) Execute(trackURL *url.URL) error {
 		log.Printf("Error finding track information in HTML: %v", err)
 		return err
 	}
+	return nil
+}
 
-	if t.Track != nil {
-		if t.isDownloaded() {
-			return nil
+// Download saves a track whose metadata is already known, e.g. from an album page,
+// without retrieving its track page.
+func (t *TrackScrapper) Download(ctx context.Context, track *model.Track) error {
+	if track == nil {
+		return nil
+	}
+	t.Track = track
+	return t.download(ctx)
+}
+
+func (t *TrackScrapper) download(ctx context.Context) error {
+	if t.isDownloaded() || t.Track.DownloadURL == "" {
+		t.observer.TrackSkipped(t.Track)
+		return nil
+	}
+
+	log.Printf("Processing download for track: %s", t.Track.Title)
+	if progress, ok := t.observer.(Progress); ok {
+		progress.TrackStarted(t.Track)
+	}
+	if t.Track.Artwork == nil && !t.Track.ArtworkFetched {
+		t.Track.Artwork = fetchArtwork(ctx, t.httpClient, t.Track.ArtID, t.options.ArtworkSize)
+		t.Track.ArtworkFetched = true
+	}
+
+	if err := t.saveWithRetries(ctx); err != nil {
+		switch cause := context.Cause(ctx); {
+		case errors.Is(cause, ErrCancelled):
+			t.discard()
+			return err
+		case errors.Is(cause, ErrPaused):
+			log.Printf("Download of track %s paused", t.Track.Title)
+			return err
 		}
-		if t.Track.DownloadURL != "" {
-			log.Printf("Processing download for track: %s", t.Track.Title)
-			mp3_reader, err := t.Retrieve(t.Track.DownloadURL)
-			if err != nil {
-				log.Printf("Error retrieving MP3 from URL %s: %v", t.Track.DownloadURL, err)
-				return err
-			}
+		t.observer.TrackFailed(t.Track, err)
+		return err
+	}
+	t.updateDownloadedTracks()
+	t.observer.TrackDownloaded(t.Track)
+	return nil
+}
 
-			if err := t.Save(mp3_reader, t.Track); err != nil {
-				log.Printf("Error saving track %s: %v", t.Track.Title, err)
+// saveWithRetries saves the track, refreshing its stream URL once it expires and
+// resuming the transfers that drop.
+func (t *TrackScrapper) saveWithRetries(ctx context.Context) error {
+	_, canResume := t.saveClient.(Resumer)
+	attempt, refreshed := 1, false
+	for {
+		err := t.saveMP3(ctx)
+		if err == nil {
+			break
+		}
+		switch {
+		case retriever.IsExpired(err) && !refreshed:
+			// the page may have been parsed long ago, its signed stream URL only lasts so long
+			refreshed = true
+			if refreshErr := t.refreshDownloadURL(ctx); refreshErr != nil {
+				log.Printf("Error refreshing stream URL of track %s: %v", t.Track.Title, refreshErr)
 				return err
 			}
+			log.Printf("Stream URL of track %s expired, retrying with a fresh one", t.Track.Title)
+		case canResume && attempt < maxResumeAttempts && retriever.IsRetryable(err):
+			attempt++
+			log.Printf("Download of track %s interrupted, resuming (attempt %d/%d): %v", t.Track.Title, attempt, maxResumeAttempts, err)
+		default:
+			return err
+		}
+	}
+	return nil
+}
 
-			t.updateDownloadedTracks()
+func (t *TrackScrapper) saveMP3(ctx context.Context) error {
+	mp3_reader, err := t.retrieveMP3(ctx)
+	if err != nil {
+		log.Printf("Error retrieving MP3 from URL %s: %v", t.Track.DownloadURL, err)
+		return err
+	}
+	defer mp3_reader.Close()
+	if progress, ok := t.observer.(Progress); ok {
+		reportProgress(mp3_reader, progress, t.Track)
+	}
+
+	if err := t.Save(ctx, mp3_reader, t.Track); err != nil {
+		log.Printf("Error saving track %s: %v", t.Track.Title, err)
+		return err
+	}
+	return nil
+}
+
+// retrieveMP3 resumes a previously interrupted download with a range request when both
+// the saver kept its partial file and the retriever supports ranges. If the file changed
+// on the server since, the If-Range validator makes it answer with the whole file.
+func (t *TrackScrapper) retrieveMP3(ctx context.Context) (*retriever.Response, error) {
+	resumer, canResume := t.saveClient.(Resumer)
+	rangeRetriever, canRange := t.httpClient.(RangeRetriever)
+	if canResume && canRange {
+		if offset, validator := resumer.Partial(t.Track); offset > 0 {
+			log.Printf("Resuming download of track %s from byte %d", t.Track.Title, offset)
+			return rangeRetriever.RetrieveRange(ctx, t.Track.DownloadURL, mp3ContentType, offset, validator)
 		}
 	}
+	return t.Retrieve(ctx, t.Track.DownloadURL, mp3ContentType)
+}
 
+// refreshDownloadURL retrieves the track page again to get a newly signed stream URL.
+func (t *TrackScrapper) refreshDownloadURL(ctx context.Context) error {
+	if t.Track.URL == "" {
+		return errors.New("track has no page URL")
+	}
+
+	reader, err := t.Retrieve(ctx, t.Track.URL, htmlContentType)
+	if err != nil {
+		return err
+	}
+	node, err := t.Parse(reader)
+	reader.Close()
+	if err != nil {
+		return err
+	}
+
+	albumInfo, err := findTrAlbum(node)
+	if err != nil {
+		return err
+	}
+	if albumInfo == nil {
+		return fmt.Errorf("no track information found in %s", t.Track.URL)
+	}
+	downloadURL := albumInfo.ToTrack().DownloadURL
+	if downloadURL == "" {
+		return fmt.Errorf("track %s is no longer streamable", t.Track.URL)
+	}
+	t.Track.DownloadURL = downloadURL
 	return nil
 }
 
 func (t *TrackScrapper) isDownloaded() bool {
-	mapDir := t.albumCatalog.GetMapDir()
-	if mapDir != nil {
-		filePath := t.generateFilePath()
-		log.Printf("Checking if track %s is downloaded", filePath)
-		if _, ok := (*mapDir)[filePath]; ok {
-			log.Printf("Track %s already downloaded", filePath)
+	if t.Track.TrackID != 0 {
+		if savedPath, ok := t.albumCatalog.Lookup(t.Track.TrackID); ok {
+			if filePath := t.generateFilePath(); sanitize.Key(savedPath) != sanitize.Key(filePath) {
+				log.Printf("Track %d already downloaded as %s, it would be saved as %s now: renamed on Bandcamp or the library layout changed", t.Track.TrackID, savedPath, filePath)
+			} else {
+				log.Printf("Track %s already downloaded", savedPath)
+			}
 			return true
 		}
 	}
+
+	filePath := t.generateFilePath()
+	log.Printf("Checking if track %s is downloaded", filePath)
+	if t.albumCatalog.Contains(filePath) {
+		log.Printf("Track %s already downloaded", filePath)
+		return true
+	}
 	return false
 }
 
 func (t *TrackScrapper) generateFilePath() string {
-	trackName := fmt.Sprintf("%02d - %s.mp3", t.Track.TrackNumber, t.Track.Title)
-	if t.Track.Album == nil {
-		return strings.Join([]string{t.Track.Artist, trackName}, "/")
+	return t.options.Layout.Path(t.Track)
+}
+
+// discard removes the partial file of a cancelled download, it is not resumed.
+func (t *TrackScrapper) discard() {
+	if discarder, ok := t.saveClient.(Discarder); ok {
+		log.Printf("Download of track %s cancelled, removing its partial file", t.Track.Title)
+		discarder.Discard(t.Track)
 	}
-	return strings.Join([]string{t.Track.Artist, *t.Track.Album, trackName}, "/")
 }
 
 func (t *TrackScrapper) updateDownloadedTracks() {
-	if t.albumCatalog.GetMapDir() != nil {
-		filePath := t.generateFilePath()
-		t.albumCatalog.Update(filePath)
-	}
+	t.albumCatalog.Update(t.generateFilePath(), t.Track)
 }
@@ -2,16 +2,34 @@ package scrapper
 
 import (
 	"bytes"
+	"context"
 	_ "embed"
 	"encoding/json"
 	"errors"
+	"fmt"
+	stdhtml "html"
+	"io"
+	"net/http"
+	"net/http/httptest"
 	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
+	"time"
 
 	gomock "github.com/golang/mock/gomock"
 	"github.com/josedelrio85/bndcmp_downloader/internal/album_catalog"
 	"github.com/josedelrio85/bndcmp_downloader/internal/bandcamp"
+	"github.com/josedelrio85/bndcmp_downloader/internal/layout"
 	"github.com/josedelrio85/bndcmp_downloader/internal/model"
+	"github.com/josedelrio85/bndcmp_downloader/internal/mp3/mp3test"
+	"github.com/josedelrio85/bndcmp_downloader/internal/parser"
+	"github.com/josedelrio85/bndcmp_downloader/internal/retriever"
+	"github.com/josedelrio85/bndcmp_downloader/internal/saver"
 	"github.com/stretchr/testify/suite"
 	html "golang.org/x/net/html"
 )
@@ -31,6 +49,7 @@ func TestTrackScrapper(t *testing.T) {
 
 type TestTrackScrapperSuite struct {
 	suite.Suite
+	ctx             context.Context
 	controller      *gomock.Controller
 	mockHttpClient  *MockRetriever
 	mockParseClient *MockParser
@@ -41,6 +60,7 @@ type TestTrackScrapperSuite struct {
 }
 
 func (s *TestTrackScrapperSuite) SetupTest() {
+	s.ctx = context.Background()
 	s.controller = gomock.NewController(s.T())
 	s.mockHttpClient = NewMockRetriever(s.controller)
 	s.mockParseClient = NewMockParser(s.controller)
@@ -58,9 +78,9 @@ func (s *TestTrackScrapperSuite) TearDownTest() {
 
 func (s *TestTrackScrapperSuite) TestRetrieve_Success() {
 	mockResponse := []byte("mock response data")
-	s.mockHttpClient.EXPECT().Retrieve(s.trackURL.String()).Return(bytes.NewReader(mockResponse), nil)
+	s.mockHttpClient.EXPECT().Retrieve(s.ctx, s.trackURL.String(), htmlContentType).Return(newMockResponse(mockResponse), nil)
 
-	reader, err := s.trackScrapper.Retrieve(s.trackURL.String())
+	reader, err := s.trackScrapper.Retrieve(s.ctx, s.trackURL.String(), htmlContentType)
 
 	s.NoError(err)
 	s.NotNil(reader)
@@ -68,9 +88,9 @@ func (s *TestTrackScrapperSuite) TestRetrieve_Success() {
 
 func (s *TestTrackScrapperSuite) TestRetrieve_Error() {
 	expectedError := errors.New("failed to retrieve track")
-	s.mockHttpClient.EXPECT().Retrieve(s.trackURL.String()).Return(nil, expectedError)
+	s.mockHttpClient.EXPECT().Retrieve(s.ctx, s.trackURL.String(), htmlContentType).Return(nil, expectedError)
 
-	reader, err := s.trackScrapper.Retrieve(s.trackURL.String())
+	reader, err := s.trackScrapper.Retrieve(s.ctx, s.trackURL.String(), htmlContentType)
 
 	s.Error(err)
 	s.Equal(expectedError, err)
@@ -133,9 +153,9 @@ func (s *TestTrackScrapperSuite) TestSave_Success() {
 	mockReader := bytes.NewReader([]byte("mock response data"))
 	track := &model.Track{}
 
-	s.mockSaveClient.EXPECT().Save(mockReader, track).Return(nil)
+	s.mockSaveClient.EXPECT().Save(s.ctx, mockReader, track).Return(nil)
 
-	err := s.trackScrapper.Save(mockReader, track)
+	err := s.trackScrapper.Save(s.ctx, mockReader, track)
 	s.NoError(err)
 }
 
@@ -144,9 +164,9 @@ func (s *TestTrackScrapperSuite) TestSave_Error() {
 	track := &model.Track{}
 
 	mockedError := errors.New("failed to save file")
-	s.mockSaveClient.EXPECT().Save(mockReader, track).Return(mockedError)
+	s.mockSaveClient.EXPECT().Save(s.ctx, mockReader, track).Return(mockedError)
 
-	err := s.trackScrapper.Save(mockReader, track)
+	err := s.trackScrapper.Save(s.ctx, mockReader, track)
 	s.Error(err)
 	s.Equal(mockedError, err)
 }
@@ -159,63 +179,90 @@ func (s *TestTrackScrapperSuite) TestExecute_Success() {
 	}
 	downloadURL := trAlbum.Trackinfo[0].File.Mp3128
 
-	mockReader := bytes.NewReader([]byte(validExample))
-	s.mockHttpClient.EXPECT().Retrieve(s.trackURL.String()).Return(mockReader, nil)
+	mockReader := newMockResponse([]byte(validExample))
+	s.mockHttpClient.EXPECT().Retrieve(s.ctx, s.trackURL.String(), htmlContentType).Return(mockReader, nil)
 
 	mockNode, _ := html.Parse(bytes.NewReader([]byte(validExample)))
 	s.mockParseClient.EXPECT().Parse(mockReader).Return(mockNode, nil)
 
-	expectedMapDir := make(map[string]bool)
-	s.albumCatalog.EXPECT().GetMapDir().Return(&expectedMapDir).Times(2)
+	s.albumCatalog.EXPECT().Lookup(trAlbum.ID).Return("", false)
+	s.albumCatalog.EXPECT().Contains(gomock.Any()).Return(false)
 
-	mockMP3Reader := bytes.NewReader([]byte("mock mp3 data"))
-	s.mockHttpClient.EXPECT().Retrieve(downloadURL).Return(mockMP3Reader, nil)
+	mockArtworkReader := newMockResponse([]byte("mock artwork"))
+	s.mockHttpClient.EXPECT().Retrieve(s.ctx, "https://f4.bcbits.com/img/a3529909906_10.jpg", jpegContentType).Return(mockArtworkReader, nil)
+
+	mockMP3Reader := newMockResponse([]byte("mock mp3 data"))
+	s.mockHttpClient.EXPECT().Retrieve(s.ctx, downloadURL, mp3ContentType).Return(mockMP3Reader, nil)
 	s.trackScrapper.Track = trAlbum.ToTrack()
-	s.mockSaveClient.EXPECT().Save(mockMP3Reader, s.trackScrapper.Track).Return(nil)
-	s.albumCatalog.EXPECT().Update(s.trackScrapper.generateFilePath()).Return()
+	s.trackScrapper.Track.Artwork = []byte("mock artwork")
+	s.trackScrapper.Track.ArtworkFetched = true
+	s.mockSaveClient.EXPECT().Save(s.ctx, mockMP3Reader, s.trackScrapper.Track).Return(nil)
+	s.albumCatalog.EXPECT().Update(s.trackScrapper.generateFilePath(), s.trackScrapper.Track).Return()
 
-	err = s.trackScrapper.Execute(s.trackURL)
+	err = s.trackScrapper.Execute(s.ctx, s.trackURL)
 
 	s.NoError(err)
 	s.Equal("Elbow", s.trackScrapper.Track.Title)
 	s.Equal("https://kinggizzard.bandcamp.com/track/elbow", s.trackScrapper.Track.URL)
 	s.Equal("https://t4.bcbits.com/stream/b77ce644d30f5a71778080be8c194c19/mp3-128/3749823254?p=0&ts=1728551843&t=dd8cc7cd9d747ac5be9c0a202fea450a5aa08944&token=1728551843_656b69850113f6ea23cd1e4321e6d148a256413b", s.trackScrapper.Track.DownloadURL)
+	s.Equal([]byte("mock artwork"), s.trackScrapper.Track.Artwork)
+	s.True(isClosed(mockReader), "page body should be closed")
+	s.True(isClosed(mockArtworkReader), "artwork body should be closed")
+	s.True(isClosed(mockMP3Reader), "MP3 body should be closed")
 }
 
 func (s *TestTrackScrapperSuite) TestExecute_RetrieveError() {
+	observer := &recordingObserver{}
+	s.trackScrapper.SetObserver(observer)
 	mockError := errors.New("retrieve error")
-	s.mockHttpClient.EXPECT().Retrieve(s.trackURL.String()).Return(nil, mockError)
+	s.mockHttpClient.EXPECT().Retrieve(s.ctx, s.trackURL.String(), htmlContentType).Return(nil, mockError)
 
-	err := s.trackScrapper.Execute(s.trackURL)
+	err := s.trackScrapper.Execute(s.ctx, s.trackURL)
 
 	s.Error(err)
 	s.Equal(mockError, err)
+	s.Len(observer.failed, 1, "the unreadable page should be reported")
+}
+
+func (s *TestTrackScrapperSuite) TestExecute_RetrieveCancelled() {
+	observer := &recordingObserver{}
+	s.trackScrapper.SetObserver(observer)
+	ctx, cancel := context.WithCancel(s.ctx)
+	cancel()
+	s.mockHttpClient.EXPECT().Retrieve(ctx, s.trackURL.String(), htmlContentType).Return(nil, context.Canceled)
+
+	err := s.trackScrapper.Execute(ctx, s.trackURL)
+
+	s.ErrorIs(err, context.Canceled)
+	s.Empty(observer.failed, "a stopped download is not a failure")
 }
 
 func (s *TestTrackScrapperSuite) TestExecute_ParseError() {
-	mockReader := bytes.NewReader([]byte(validExample))
-	s.mockHttpClient.EXPECT().Retrieve(s.trackURL.String()).Return(mockReader, nil)
+	mockReader := newMockResponse([]byte(validExample))
+	s.mockHttpClient.EXPECT().Retrieve(s.ctx, s.trackURL.String(), htmlContentType).Return(mockReader, nil)
 
 	mockError := errors.New("parse error")
 	s.mockParseClient.EXPECT().Parse(mockReader).Return(nil, mockError)
 
-	err := s.trackScrapper.Execute(s.trackURL)
+	err := s.trackScrapper.Execute(s.ctx, s.trackURL)
 
 	s.Error(err)
 	s.Equal(mockError, err)
+	s.True(isClosed(mockReader), "page body should be closed")
 }
 
 func (s *TestTrackScrapperSuite) TestExecute_FindError() {
-	mockReader := bytes.NewReader([]byte(invalidExample))
-	s.mockHttpClient.EXPECT().Retrieve(s.trackURL.String()).Return(mockReader, nil)
+	mockReader := newMockResponse([]byte(invalidExample))
+	s.mockHttpClient.EXPECT().Retrieve(s.ctx, s.trackURL.String(), htmlContentType).Return(mockReader, nil)
 
 	mockNode, _ := html.Parse(mockReader)
 	s.mockParseClient.EXPECT().Parse(mockReader).Return(mockNode, nil)
 
-	err := s.trackScrapper.Execute(s.trackURL)
+	err := s.trackScrapper.Execute(s.ctx, s.trackURL)
 
 	s.Error(err)
 	s.Contains(err.Error(), "invalid character")
+	s.True(isClosed(mockReader), "page body should be closed")
 }
 
 func (s *TestTrackScrapperSuite) TestExecute_SaveError() {
@@ -226,26 +273,32 @@ func (s *TestTrackScrapperSuite) TestExecute_SaveError() {
 	}
 	downloadURL := trAlbum.Trackinfo[0].File.Mp3128
 	s.trackScrapper.Track = trAlbum.ToTrack()
+	s.trackScrapper.Track.ArtworkFetched = true
 
-	mockReader := bytes.NewReader([]byte(validExample))
-	s.mockHttpClient.EXPECT().Retrieve(s.trackURL.String()).Return(mockReader, nil)
+	mockReader := newMockResponse([]byte(validExample))
+	s.mockHttpClient.EXPECT().Retrieve(s.ctx, s.trackURL.String(), htmlContentType).Return(mockReader, nil)
 
 	mockNode, _ := html.Parse(mockReader)
 	s.mockParseClient.EXPECT().Parse(mockReader).Return(mockNode, nil)
 
-	expectedMapDir := make(map[string]bool)
-	s.albumCatalog.EXPECT().GetMapDir().Return(&expectedMapDir)
+	s.albumCatalog.EXPECT().Lookup(trAlbum.ID).Return("", false)
+	s.albumCatalog.EXPECT().Contains(gomock.Any()).Return(false)
+
+	// artwork is optional, a failure does not stop the download
+	s.mockHttpClient.EXPECT().Retrieve(s.ctx, "https://f4.bcbits.com/img/a3529909906_10.jpg", jpegContentType).Return(nil, errors.New("artwork error"))
 
-	mockMP3Reader := bytes.NewReader([]byte("mock mp3 data"))
-	s.mockHttpClient.EXPECT().Retrieve(downloadURL).Return(mockMP3Reader, nil)
+	mockMP3Reader := newMockResponse([]byte("mock mp3 data"))
+	s.mockHttpClient.EXPECT().Retrieve(s.ctx, downloadURL, mp3ContentType).Return(mockMP3Reader, nil)
 
 	mockError := errors.New("save error")
-	s.mockSaveClient.EXPECT().Save(mockMP3Reader, s.trackScrapper.Track).Return(mockError)
+	s.mockSaveClient.EXPECT().Save(s.ctx, mockMP3Reader, s.trackScrapper.Track).Return(mockError)
 
-	err = s.trackScrapper.Execute(s.trackURL)
+	err = s.trackScrapper.Execute(s.ctx, s.trackURL)
 
 	s.Error(err)
 	s.Equal(mockError, err)
+	s.True(isClosed(mockReader), "page body should be closed")
+	s.True(isClosed(mockMP3Reader), "MP3 body should be closed")
 }
 
 func (s *TestTrackScrapperSuite) TestFind_NoDataTralbum() {
@@ -278,15 +331,90 @@ func (s *TestTrackScrapperSuite) TestFind_InvalidJSON() {
 	s.Contains(err.Error(), "invalid character")
 }
 
+func (s *TestTrackScrapperSuite) TestFind_AlbumTitleFromEmbed() {
+	mockNode := &html.Node{
+		Type: html.ElementNode,
+		Data: "script",
+		Attr: []html.Attribute{
+			{
+				Key: "data-tralbum",
+				Val: `{"current":{"title":"Airbag","track_number":1},"artist":"Radiohead","item_type":"track","album_url":"/album/ok-computer"}`,
+			},
+			{
+				Key: "data-embed",
+				Val: `{"artist":"Radiohead","album_title":"OK Computer"}`,
+			},
+		},
+	}
+
+	err := s.trackScrapper.Find(mockNode)
+
+	s.NoError(err)
+	s.Equal(toPointer("OK Computer"), s.trackScrapper.Track.Album)
+}
+
+func (s *TestTrackScrapperSuite) TestFind_StandaloneTrack() {
+	mockNode := &html.Node{
+		Type: html.ElementNode,
+		Data: "script",
+		Attr: []html.Attribute{
+			{
+				Key: "data-tralbum",
+				Val: `{"current":{"title":"Single"},"artist":"Radiohead","item_type":"track"}`,
+			},
+			{
+				Key: "data-embed",
+				Val: `{"artist":"Radiohead"}`,
+			},
+		},
+	}
+
+	err := s.trackScrapper.Find(mockNode)
+
+	s.NoError(err)
+	s.Nil(s.trackScrapper.Track.Album)
+}
+
 func (s *TestTrackScrapperSuite) TestIsDownloaded_True() {
-	filePath := "Artist/Album/01 - Track.mp3"
-	expectedMapDir := map[string]bool{filePath: true}
-	s.albumCatalog.EXPECT().GetMapDir().Return(&expectedMapDir)
+	s.albumCatalog.EXPECT().Contains("Artist/Album/01 - Track.mp3").Return(true)
+	s.trackScrapper.Track = &model.Track{
+		Artist:      "Artist",
+		Album:       toPointer("Album"),
+		Title:       "Track",
+		TrackNumber: 1,
+	}
+
+	result := s.trackScrapper.isDownloaded()
+
+	s.True(result)
+}
+
+func (s *TestTrackScrapperSuite) TestIsDownloaded_TrackID() {
+	// renamed on Bandcamp since it was saved
+	s.albumCatalog.EXPECT().Lookup(int64(3749823254)).Return("Artist/Album/01 - Old Title.mp3", true)
+	s.trackScrapper.Track = &model.Track{
+		Artist:      "Artist",
+		Album:       toPointer("Album"),
+		Title:       "New Title",
+		TrackNumber: 1,
+		TrackID:     3749823254,
+	}
+
+	result := s.trackScrapper.isDownloaded()
+
+	s.True(result)
+}
+
+func (s *TestTrackScrapperSuite) TestIsDownloaded_UnknownTrackID() {
+	// saved before the catalog recorded track ids
+	s.albumCatalog.EXPECT().Lookup(int64(3749823254)).Return("", false)
+	s.albumCatalog.EXPECT().Contains("Artist/Album/01 - Track.mp3").Return(true)
 	s.trackScrapper.Track = &model.Track{
 		Artist:      "Artist",
 		Album:       toPointer("Album"),
 		Title:       "Track",
 		TrackNumber: 1,
+		TrackID:     3749823254,
 	}
 
 	result := s.trackScrapper.isDownloaded()
@@ -295,8 +423,7 @@ func (s *TestTrackScrapperSuite) TestIsDownloaded_True() {
 }
 
 func (s *TestTrackScrapperSuite) TestIsDownloaded_False() {
-	expectedMapDir := make(map[string]bool)
-	s.albumCatalog.EXPECT().GetMapDir().Return(&expectedMapDir)
+	s.albumCatalog.EXPECT().Contains(gomock.Any()).Return(false)
 
 	s.trackScrapper.Track = &model.Track{
 		Artist:      "Artist",
@@ -335,6 +462,24 @@ func (s *TestTrackScrapperSuite) TestGenerateFilePath_WithoutAlbum() {
 	s.Equal("Artist/01 - Track.mp3", result)
 }
 
+func (s *TestTrackScrapperSuite) TestGenerateFilePath_Layout() {
+	options := DefaultOptions()
+	options.Layout = layout.MustParse(layout.Presets["plex"])
+	s.trackScrapper.SetOptions(options)
+	s.trackScrapper.Track = &model.Track{
+		Artist:      "Artist",
+		AlbumArtist: "Album Artist",
+		Album:       toPointer("Album"),
+		Title:       "Track",
+		TrackNumber: 1,
+		ReleaseYear: 2012,
+	}
+
+	result := s.trackScrapper.generateFilePath()
+
+	s.Equal("Album Artist/Album (2012)/01 - Track.mp3", result)
+}
+
 /*
 	func (s *TestTrackScrapperSuite) TestUpdateDownloadedTracks() {
 		// Initialize the downloadedTracks map
@@ -366,3 +511,475 @@ func (s *TestTrackScrapperSuite) TestGenerateFilePath_WithoutAlbum() {
 func toPointer(s string) *string {
 	return &s
 }
+
+// mockBody records whether the scrapper closed the retrieved body
+type mockBody struct {
+	*bytes.Reader
+	closed bool
+}
+
+func (m *mockBody) Close() error {
+	m.closed = true
+	return nil
+}
+
+func newMockResponse(data []byte) *retriever.Response {
+	return &retriever.Response{
+		ReadCloser:    &mockBody{Reader: bytes.NewReader(data)},
+		ContentLength: int64(len(data)),
+	}
+}
+
+func isClosed(response *retriever.Response) bool {
+	return response.ReadCloser.(*mockBody).closed
+}
+
+func (s *TestTrackScrapperSuite) TestDownload_Success() {
+	track := &model.Track{
+		Title:       "Elbow",
+		Artist:      "King Gizzard & The Lizard Wizard",
+		Album:       toPointer("12 Bar Bruise"),
+		ArtID:       3529909906,
+		Artwork:     []byte("album artwork"),
+		DownloadURL: "https://t4.bcbits.com/stream/elbow",
+	}
+
+	s.albumCatalog.EXPECT().Contains(gomock.Any()).Return(false)
+
+	mockMP3Reader := newMockResponse([]byte("mock mp3 data"))
+	s.mockHttpClient.EXPECT().Retrieve(s.ctx, track.DownloadURL, mp3ContentType).Return(mockMP3Reader, nil)
+	s.mockSaveClient.EXPECT().Save(s.ctx, mockMP3Reader, track).Return(nil)
+	s.albumCatalog.EXPECT().Update(gomock.Any(), gomock.Any()).Return()
+
+	err := s.trackScrapper.Download(s.ctx, track)
+
+	s.NoError(err)
+	s.Equal(track, s.trackScrapper.Track)
+	s.True(isClosed(mockMP3Reader), "MP3 body should be closed")
+}
+
+// recordingObserver keeps the titles of the tracks it is told about
+type recordingObserver struct {
+	mutex      sync.Mutex
+	downloaded []string
+	skipped    []string
+	failed     []string
+	albums     []string
+	// failedAlbums are the album pages that could not be read
+	failedAlbums []string
+}
+
+func (r *recordingObserver) TrackDownloaded(track *model.Track) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.downloaded = append(r.downloaded, track.Title)
+}
+
+func (r *recordingObserver) TrackSkipped(track *model.Track) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.skipped = append(r.skipped, track.Title)
+}
+
+func (r *recordingObserver) TrackFailed(track *model.Track, err error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.failed = append(r.failed, track.Title)
+}
+
+func (r *recordingObserver) AlbumFailed(albumURL string, err error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.failedAlbums = append(r.failedAlbums, albumURL)
+}
+
+func (r *recordingObserver) AlbumCompleted(albumURL string) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.albums = append(r.albums, albumURL)
+}
+
+// checkpointObserver resumes a download whose completed albums are known
+type checkpointObserver struct {
+	recordingObserver
+	completed map[string]bool
+}
+
+func (c *checkpointObserver) Completed(albumURL string) bool {
+	return c.completed[albumURL]
+}
+
+// progressObserver also keeps what the scrapper is working on and the progress reported
+type progressObserver struct {
+	recordingObserver
+	startedAlbums []string
+	startedTracks []string
+	progress      [][2]int64
+}
+
+func (p *progressObserver) AlbumStarted(albumURL string) {
+	p.startedAlbums = append(p.startedAlbums, albumURL)
+}
+
+func (p *progressObserver) TrackStarted(track *model.Track) {
+	p.startedTracks = append(p.startedTracks, track.Title)
+}
+
+func (p *progressObserver) TrackProgress(track *model.Track, written int64, total int64) {
+	p.progress = append(p.progress, [2]int64{written, total})
+}
+
+func (s *TestTrackScrapperSuite) TestDownload_Observer() {
+	observer := &recordingObserver{}
+	s.trackScrapper.SetObserver(observer)
+	downloaded := &model.Track{Title: "Elbow", Artist: "King Gizzard & The Lizard Wizard", Artwork: []byte("art"), DownloadURL: "https://t4.bcbits.com/stream/elbow"}
+	existing := &model.Track{Title: "Muckraker", Artist: "King Gizzard & The Lizard Wizard", DownloadURL: "https://t4.bcbits.com/stream/muckraker"}
+	notStreamed := &model.Track{Title: "Nein", Artist: "King Gizzard & The Lizard Wizard"}
+	failed := &model.Track{Title: "Sam Cherry's Last Shot", Artist: "King Gizzard & The Lizard Wizard", Artwork: []byte("art"), DownloadURL: "https://t4.bcbits.com/stream/sam"}
+
+	s.albumCatalog.EXPECT().Contains(gomock.Any()).Return(false)
+	mp3Reader := newMockResponse(mp3test.Frames(3))
+	s.mockHttpClient.EXPECT().Retrieve(s.ctx, downloaded.DownloadURL, mp3ContentType).Return(mp3Reader, nil)
+	s.mockSaveClient.EXPECT().Save(s.ctx, mp3Reader, downloaded).Return(nil)
+	s.albumCatalog.EXPECT().Update(gomock.Any(), downloaded)
+	s.NoError(s.trackScrapper.Download(s.ctx, downloaded))
+
+	s.albumCatalog.EXPECT().Contains(gomock.Any()).Return(true)
+	s.NoError(s.trackScrapper.Download(s.ctx, existing))
+
+	s.albumCatalog.EXPECT().Contains(gomock.Any()).Return(false)
+	s.NoError(s.trackScrapper.Download(s.ctx, notStreamed))
+
+	s.albumCatalog.EXPECT().Contains(gomock.Any()).Return(false)
+	s.mockHttpClient.EXPECT().Retrieve(s.ctx, failed.DownloadURL, mp3ContentType).Return(nil, retriever.ErrNotFound)
+	s.Error(s.trackScrapper.Download(s.ctx, failed))
+
+	s.Equal([]string{"Elbow"}, observer.downloaded)
+	s.Equal([]string{"Muckraker", "Nein"}, observer.skipped)
+	s.Equal([]string{"Sam Cherry's Last Shot"}, observer.failed)
+}
+
+func (s *TestTrackScrapperSuite) TestDownload_NoDownloadURL() {
+	track := &model.Track{Title: "Nein", Artist: "King Gizzard & The Lizard Wizard"}
+
+	s.albumCatalog.EXPECT().Contains(gomock.Any()).Return(false)
+
+	err := s.trackScrapper.Download(s.ctx, track)
+
+	s.NoError(err)
+}
+
+func (s *TestTrackScrapperSuite) TestDownload_ResumesInterruptedTransfer() {
+	content := string(mp3test.Frames(100))
+	var requests atomic.Int32
+	var ranges []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", mp3ContentType)
+		w.Header().Set("ETag", `"v1"`)
+		if requests.Add(1) == 1 {
+			w.Header().Set("Content-Length", strconv.Itoa(len(content)))
+			w.Write([]byte(content[:len(content)/2]))
+			w.(http.Flusher).Flush()
+			panic(http.ErrAbortHandler)
+		}
+		ranges = append(ranges, r.Header.Get("Range"))
+		http.ServeContent(w, r, "", time.Time{}, strings.NewReader(content))
+	}))
+	defer server.Close()
+
+	folder := s.T().TempDir()
+	trackScrapper := NewTrackScrapper(retriever.NewHttpClient(), s.mockParseClient, saver.NewLocalSaver(&folder, nil), s.albumCatalog)
+	track := &model.Track{
+		Title:       "Elbow",
+		TrackNumber: 1,
+		Artist:      "King Gizzard & The Lizard Wizard",
+		Album:       toPointer("12 Bar Bruise"),
+		Artwork:     []byte("album artwork"),
+		DownloadURL: server.URL + "/stream/elbow",
+	}
+
+	s.albumCatalog.EXPECT().Contains(gomock.Any()).Return(false).AnyTimes()
+	s.albumCatalog.EXPECT().Update(gomock.Any(), gomock.Any()).Return()
+
+	err := trackScrapper.Download(s.ctx, track)
+
+	s.NoError(err)
+	s.Equal(int32(2), requests.Load())
+	s.Equal([]string{"bytes=" + strconv.Itoa(len(content)/2) + "-"}, ranges)
+	saved, err := os.ReadFile(filepath.Join(folder, "King Gizzard & The Lizard Wizard", "12 Bar Bruise", "01 - Elbow.mp3"))
+	s.NoError(err)
+	s.True(strings.HasSuffix(string(saved), content), "resumed file should hold the whole stream after its tag")
+}
+
+// stopDownload downloads a track into a new library folder, stopping the download with
+// cause once its transfer is in flight.
+func (s *TestTrackScrapperSuite) stopDownload(cause error) (*saver.LocalSaver, *model.Track, *recordingObserver, error) {
+	content := mp3test.Frames(100)
+	ctx, stop := context.WithCancelCause(s.ctx)
+	defer stop(nil)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", mp3ContentType)
+		w.Header().Set("ETag", `"v1"`)
+		w.Header().Set("Content-Length", strconv.Itoa(len(content)))
+		w.Write(content[:len(content)/2])
+		w.(http.Flusher).Flush()
+		<-r.Context().Done()
+	}))
+	defer server.Close()
+
+	folder := s.T().TempDir()
+	partPath := filepath.Join(folder, "King Gizzard & The Lizard Wizard", "12 Bar Bruise", "01 - Elbow.mp3"+saver.PartialExtension)
+	go func() {
+		for info, err := os.Stat(partPath); err != nil || info.Size() == 0; info, err = os.Stat(partPath) {
+			time.Sleep(time.Millisecond)
+		}
+		stop(cause)
+	}()
+	localSaver := saver.NewLocalSaver(&folder, nil)
+	trackScrapper := NewTrackScrapper(retriever.NewHttpClient(), s.mockParseClient, localSaver, s.albumCatalog)
+	observer := &recordingObserver{}
+	trackScrapper.SetObserver(observer)
+	track := &model.Track{
+		Title:       "Elbow",
+		TrackNumber: 1,
+		Artist:      "King Gizzard & The Lizard Wizard",
+		Album:       toPointer("12 Bar Bruise"),
+		Artwork:     []byte("album artwork"),
+		DownloadURL: server.URL + "/stream/elbow",
+	}
+	s.albumCatalog.EXPECT().Contains(gomock.Any()).Return(false)
+
+	err := trackScrapper.Download(ctx, track)
+	return localSaver, track, observer, err
+}
+
+func (s *TestTrackScrapperSuite) TestDownload_Cancelled() {
+	localSaver, track, observer, err := s.stopDownload(ErrCancelled)
+
+	s.Error(err)
+	s.Empty(observer.failed, "a cancelled track did not fail")
+	offset, _ := localSaver.Partial(track)
+	s.Zero(offset, "the partial file should be removed")
+}
+
+func (s *TestTrackScrapperSuite) TestDownload_Paused() {
+	localSaver, track, observer, err := s.stopDownload(ErrPaused)
+
+	s.Error(err)
+	s.Empty(observer.failed, "a paused track did not fail")
+	offset, validator := localSaver.Partial(track)
+	s.Positive(offset, "the partial file should be kept to resume the track")
+	s.Equal(`"v1"`, validator)
+}
+
+func (s *TestTrackScrapperSuite) TestDownload_InvalidAudio() {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", mp3ContentType)
+		w.Write(mp3test.Frames(10))
+	}))
+	defer server.Close()
+
+	folder := s.T().TempDir()
+	trackScrapper := NewTrackScrapper(retriever.NewHttpClient(), s.mockParseClient, saver.NewLocalSaver(&folder, nil), s.albumCatalog)
+	track := &model.Track{
+		Title:       "Elbow",
+		TrackNumber: 1,
+		Artist:      "King Gizzard & The Lizard Wizard",
+		Duration:    159.88,
+		DownloadURL: server.URL + "/stream/elbow",
+	}
+
+	s.albumCatalog.EXPECT().Contains(gomock.Any()).Return(false)
+
+	err := trackScrapper.Download(s.ctx, track)
+
+	s.ErrorIs(err, saver.ErrInvalidAudio, "the track is reported as failed and not cataloged")
+	_, err = os.Stat(filepath.Join(folder, saver.QuarantineFolder, "King Gizzard & The Lizard Wizard", "01 - Elbow.mp3"))
+	s.NoError(err)
+}
+
+// newExpiringStreamServer serves a track page whose stream URL is /stream/fresh, the
+// /stream/expired URL answers with expiredStatus as the CDN does with stale signatures.
+func newExpiringStreamServer(expiredStatus int, freshStatus int, content string, pageRequests *atomic.Int32) *httptest.Server {
+	mux := http.NewServeMux()
+	server := httptest.NewServer(mux)
+	mux.HandleFunc("/track/elbow", func(w http.ResponseWriter, r *http.Request) {
+		pageRequests.Add(1)
+		tralbum := fmt.Sprintf(`{"url":"%[1]s/track/elbow","artist":"King Gizzard & The Lizard Wizard","current":{"title":"Elbow"},"trackinfo":[{"title":"Elbow","track_num":1,"file":{"mp3-128":"%[1]s/stream/fresh"}}]}`, server.URL)
+		w.Header().Set("Content-Type", htmlContentType)
+		fmt.Fprintf(w, `<html><head><script data-tralbum="%s"></script></head></html>`, stdhtml.EscapeString(tralbum))
+	})
+	mux.HandleFunc("/stream/expired", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(expiredStatus)
+	})
+	mux.HandleFunc("/stream/fresh", func(w http.ResponseWriter, r *http.Request) {
+		if freshStatus != http.StatusOK {
+			w.WriteHeader(freshStatus)
+			return
+		}
+		w.Header().Set("Content-Type", mp3ContentType)
+		w.Write([]byte(content))
+	})
+	return server
+}
+
+func (s *TestTrackScrapperSuite) TestDownload_RefreshesExpiredStreamURL() {
+	for _, status := range []int{http.StatusForbidden, http.StatusGone} {
+		s.Run(http.StatusText(status), func() {
+			var pageRequests atomic.Int32
+			server := newExpiringStreamServer(status, http.StatusOK, string(mp3test.Frames(3)), &pageRequests)
+			defer server.Close()
+
+			folder := s.T().TempDir()
+			trackScrapper := NewTrackScrapper(retriever.NewHttpClient(), parser.NewParseClient(), saver.NewLocalSaver(&folder, nil), s.albumCatalog)
+			track := &model.Track{
+				Title:       "Elbow",
+				TrackNumber: 1,
+				Artist:      "King Gizzard & The Lizard Wizard",
+				Artwork:     []byte("album artwork"),
+				URL:         server.URL + "/track/elbow",
+				DownloadURL: server.URL + "/stream/expired",
+			}
+
+			s.albumCatalog.EXPECT().Contains(gomock.Any()).Return(false)
+			s.albumCatalog.EXPECT().Update(gomock.Any(), gomock.Any()).Return()
+
+			err := trackScrapper.Download(s.ctx, track)
+
+			s.NoError(err)
+			s.Equal(int32(1), pageRequests.Load())
+			s.Equal(server.URL+"/stream/fresh", track.DownloadURL)
+			saved, err := os.ReadFile(filepath.Join(folder, "King Gizzard & The Lizard Wizard", "01 - Elbow.mp3"))
+			s.NoError(err)
+			s.True(strings.HasSuffix(string(saved), string(mp3test.Frames(3))))
+		})
+	}
+}
+
+func (s *TestTrackScrapperSuite) TestDownload_RefreshesExpiredStreamURLOnce() {
+	var pageRequests atomic.Int32
+	server := newExpiringStreamServer(http.StatusForbidden, http.StatusForbidden, "", &pageRequests)
+	defer server.Close()
+
+	folder := s.T().TempDir()
+	trackScrapper := NewTrackScrapper(retriever.NewHttpClient(), parser.NewParseClient(), saver.NewLocalSaver(&folder, nil), s.albumCatalog)
+	track := &model.Track{
+		Title:       "Elbow",
+		Artist:      "King Gizzard & The Lizard Wizard",
+		Artwork:     []byte("album artwork"),
+		URL:         server.URL + "/track/elbow",
+		DownloadURL: server.URL + "/stream/expired",
+	}
+
+	s.albumCatalog.EXPECT().Contains(gomock.Any()).Return(false)
+
+	err := trackScrapper.Download(s.ctx, track)
+
+	s.ErrorIs(err, retriever.ErrForbidden)
+	s.Equal(int32(1), pageRequests.Load())
+}
+
+func (s *TestTrackScrapperSuite) TestDownload_ExpiredStreamURLWithoutPage() {
+	track := &model.Track{
+		Title:       "Elbow",
+		Artist:      "King Gizzard & The Lizard Wizard",
+		Artwork:     []byte("album artwork"),
+		DownloadURL: "https://t4.bcbits.com/stream/elbow",
+	}
+	expiredErr := &retriever.StatusError{URL: track.DownloadURL, StatusCode: http.StatusGone}
+
+	s.albumCatalog.EXPECT().Contains(gomock.Any()).Return(false)
+	s.mockHttpClient.EXPECT().Retrieve(s.ctx, track.DownloadURL, mp3ContentType).Return(nil, expiredErr)
+
+	err := s.trackScrapper.Download(s.ctx, track)
+
+	s.ErrorIs(err, retriever.ErrGone)
+}
+
+// TestDownload_ConcurrentCatalogAccess runs under go test -race: concurrent API requests
+// share one catalog, checking it while other downloads record their tracks.
+func (s *TestTrackScrapperSuite) TestDownload_ConcurrentCatalogAccess() {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", mp3ContentType)
+		w.Write(mp3test.Frames(3))
+	}))
+	defer server.Close()
+
+	folder := s.T().TempDir()
+	catalog := album_catalog.NewInMemoryAlbumCatalog(folder)
+	localSaver := saver.NewLocalSaver(&folder, nil)
+
+	var wg sync.WaitGroup
+	for i := 1; i <= 8; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			trackScrapper := NewTrackScrapper(retriever.NewHttpClient(), s.mockParseClient, localSaver, catalog)
+			err := trackScrapper.Download(s.ctx, &model.Track{
+				Title:       fmt.Sprintf("Track %d", i),
+				TrackNumber: int64(i),
+				Artist:      "Artist",
+				Album:       toPointer("Album"),
+				TrackID:     int64(i),
+				Artwork:     []byte("album artwork"),
+				DownloadURL: server.URL,
+			})
+			s.NoError(err)
+		}(i)
+	}
+	wg.Wait()
+
+	s.Equal(album_catalog.Stats{Artists: 1, Albums: 1, Tracks: 8}, catalog.Stats())
+	s.Len(catalog.ListTracks(album_catalog.Album{Artist: "Artist", Title: "Album"}), 8)
+}
+
+func (s *TestTrackScrapperSuite) TestDownload_Progress() {
+	observer := &progressObserver{}
+	s.trackScrapper.SetObserver(observer)
+	track := &model.Track{Title: "Elbow", Artist: "King Gizzard & The Lizard Wizard", Artwork: []byte("art"), DownloadURL: "https://t4.bcbits.com/stream/elbow"}
+	audio := mp3test.Frames(700)
+
+	s.albumCatalog.EXPECT().Contains(gomock.Any()).Return(false)
+	mp3Reader := newMockResponse(audio)
+	s.mockHttpClient.EXPECT().Retrieve(s.ctx, track.DownloadURL, mp3ContentType).Return(mp3Reader, nil)
+	s.mockSaveClient.EXPECT().Save(s.ctx, mp3Reader, track).DoAndReturn(func(ctx context.Context, data io.Reader, track *model.Track) error {
+		_, err := io.CopyBuffer(io.Discard, data, make([]byte, 32*1024))
+		return err
+	})
+	s.albumCatalog.EXPECT().Update(gomock.Any(), track)
+
+	s.NoError(s.trackScrapper.Download(s.ctx, track))
+
+	total := int64(len(audio))
+	s.Equal([]string{"Elbow"}, observer.startedTracks)
+	s.Equal([][2]int64{{progressInterval, total}, {total, total}}, observer.progress)
+	s.Equal([]string{"Elbow"}, observer.downloaded)
+}
+
+func (s *TestTrackScrapperSuite) TestDownload_ArtworkAlreadyFetched() {
+	// the album could not fetch the cover, its tracks do not try again
+	track := &model.Track{Title: "Elbow", Artist: "King Gizzard & The Lizard Wizard", ArtID: 1846339374, ArtworkFetched: true, DownloadURL: "https://t4.bcbits.com/stream/elbow"}
+
+	s.albumCatalog.EXPECT().Contains(gomock.Any()).Return(false)
+	mp3Reader := newMockResponse(mp3test.Frames(2))
+	s.mockHttpClient.EXPECT().Retrieve(s.ctx, track.DownloadURL, mp3ContentType).Return(mp3Reader, nil)
+	s.mockSaveClient.EXPECT().Save(s.ctx, mp3Reader, track).Return(nil)
+	s.albumCatalog.EXPECT().Update(gomock.Any(), track)
+
+	s.NoError(s.trackScrapper.Download(s.ctx, track))
+	s.Nil(track.Artwork)
+}
+
+func (s *TestTrackScrapperSuite) TestDownload_ProgressResumed() {
+	observer := &progressObserver{}
+	s.trackScrapper.SetObserver(observer)
+	mp3Reader := newMockResponse(mp3test.Frames(2))
+	mp3Reader.Offset = 1000
+	mp3Reader.ContentLength = -1
+
+	reportProgress(mp3Reader, observer, &model.Track{Title: "Elbow"})
+	_, err := io.Copy(io.Discard, mp3Reader)
+
+	s.NoError(err)
+	s.Equal([][2]int64{{1834, -1}}, observer.progress, "bytes saved before resuming should be counted")
+}
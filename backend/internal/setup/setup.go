@@ -1,21 +1,39 @@
 package setup
 
 import (
+	"fmt"
 	"log"
 	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/josedelrio85/bndcmp_downloader/internal/album_catalog"
+	"github.com/josedelrio85/bndcmp_downloader/internal/jobs"
+	"github.com/josedelrio85/bndcmp_downloader/internal/layout"
 	"github.com/josedelrio85/bndcmp_downloader/internal/parser"
 	"github.com/josedelrio85/bndcmp_downloader/internal/retriever"
 	"github.com/josedelrio85/bndcmp_downloader/internal/saver"
+	"github.com/josedelrio85/bndcmp_downloader/internal/scrapper"
+)
+
+const (
+	defaultCatalogFile = saver.CatalogFileName
+	defaultJobsFile    = saver.JobsFileName
 )
 
 type Config struct {
-	BaseFolder   string
-	Retriever    *retriever.HttpClient
-	Parser       *parser.ParseClient
-	Saver        *saver.LocalSaver
-	AlbumCatalog album_catalog.AlbumCatalog
+	BaseFolder      string
+	RetryConfig     retriever.RetryConfig
+	RateLimitConfig retriever.RateLimitConfig
+	ScrapperOptions scrapper.Options
+	WatchConfig     album_catalog.WatchConfig
+	QueueConfig     jobs.QueueConfig
+	Retriever       *retriever.RetryingClient
+	Parser          *parser.ParseClient
+	Saver           *saver.LocalSaver
+	AlbumCatalog    album_catalog.AlbumCatalog
 }
 
 func LoadConfig() *Config {
@@ -24,16 +42,229 @@ func LoadConfig() *Config {
 		log.Fatal("BASE_FOLDER is not set")
 	}
 
-	albumCatalog := album_catalog.NewInMemoryAlbumCatalog(baseFolder)
-	if err := albumCatalog.Generate(baseFolder); err != nil {
+	retryConfig := LoadRetryConfig()
+	rateLimitConfig := LoadRateLimitConfig()
+	scrapperOptions := LoadScrapperOptions()
+
+	localSaver := saver.NewLocalSaver(&baseFolder, scrapperOptions.Layout)
+	// walking the whole library takes a while, downloads do not wait for it
+	go func() {
+		if err := localSaver.CleanPartials(); err != nil {
+			log.Println("Error cleaning partial downloads: ", err)
+		}
+	}()
+
+	albumCatalog := NewAlbumCatalog(baseFolder)
+	if _, persistent := albumCatalog.(*album_catalog.BoltAlbumCatalog); persistent {
+		// it knows the library as of the previous run, the API does not wait for the scan
+		go func() {
+			if err := albumCatalog.Generate(baseFolder); err != nil {
+				log.Println("Error generating album catalog: ", err)
+			}
+		}()
+	} else if err := albumCatalog.Generate(baseFolder); err != nil {
 		log.Fatal("Error generating album catalog: ", err)
 	}
 
 	return &Config{
-		BaseFolder:   baseFolder,
-		Retriever:    retriever.NewHttpClient(),
-		Parser:       parser.NewParseClient(),
-		Saver:        saver.NewLocalSaver(&baseFolder),
-		AlbumCatalog: albumCatalog,
+		BaseFolder:      baseFolder,
+		RetryConfig:     retryConfig,
+		RateLimitConfig: rateLimitConfig,
+		ScrapperOptions: scrapperOptions,
+		WatchConfig:     LoadWatchConfig(),
+		QueueConfig:     LoadQueueConfig(),
+		Retriever:       NewRetriever(retryConfig, rateLimitConfig),
+		Parser:          parser.NewParseClient(),
+		Saver:           localSaver,
+		AlbumCatalog:    albumCatalog,
+	}
+}
+
+// NewRetriever builds the HTTP retriever shared by the API and the CLI. Every retry
+// attempt goes through the rate limiter, so retries are throttled too.
+func NewRetriever(retryConfig retriever.RetryConfig, rateLimitConfig retriever.RateLimitConfig) *retriever.RetryingClient {
+	rateLimitedClient := retriever.NewRateLimitedClient(retriever.NewHttpClient(), rateLimitConfig)
+	return retriever.NewRetryingClient(rateLimitedClient, retryConfig)
+}
+
+// NewAlbumCatalog opens the persistent catalog of baseFolder at CATALOG_PATH, by default
+// .catalog.db in baseFolder. If it cannot be opened, e.g. because another process holds
+// it, the catalog is kept in memory and rebuilt from scratch.
+func NewAlbumCatalog(baseFolder string) album_catalog.AlbumCatalog {
+	catalogPath := os.Getenv("CATALOG_PATH")
+	if catalogPath == "" {
+		catalogPath = filepath.Join(baseFolder, defaultCatalogFile)
+	}
+
+	albumCatalog, err := album_catalog.NewBoltAlbumCatalog(baseFolder, catalogPath)
+	if err != nil {
+		log.Printf("Error opening album catalog: %v, falling back to an in-memory catalog", err)
+		return album_catalog.NewInMemoryAlbumCatalog(baseFolder)
+	}
+	return albumCatalog
+}
+
+// NewJobStore opens the store of the API download jobs at JOBS_PATH, by default .jobs.db
+// in baseFolder. If it cannot be opened, jobs are kept in memory and lost on restart.
+func NewJobStore(baseFolder string) jobs.Store {
+	jobsPath := os.Getenv("JOBS_PATH")
+	if jobsPath == "" {
+		jobsPath = filepath.Join(baseFolder, defaultJobsFile)
+	}
+
+	store, err := jobs.NewBoltStore(jobsPath)
+	if err != nil {
+		log.Printf("Error opening job store: %v, jobs will not be resumed after a restart", err)
+		return jobs.NewMemoryStore()
+	}
+	return store
+}
+
+// LoadRetryConfig reads RETRY_MAX_ATTEMPTS, RETRY_BASE_DELAY and RETRY_MAX_DELAY,
+// falling back to the retriever defaults for anything unset or invalid.
+func LoadRetryConfig() retriever.RetryConfig {
+	config := retriever.DefaultRetryConfig()
+	config.MaxAttempts = getEnvInt("RETRY_MAX_ATTEMPTS", config.MaxAttempts)
+	config.BaseDelay = getEnvDuration("RETRY_BASE_DELAY", config.BaseDelay)
+	config.MaxDelay = getEnvDuration("RETRY_MAX_DELAY", config.MaxDelay)
+	return config
+}
+
+// LoadRateLimitConfig reads RATE_LIMIT_RPS and RATE_LIMIT_BURST for the default
+// per-host limit, RATE_LIMIT_HOSTS for host specific ones (e.g.
+// "bandcamp.com=1:2,bcbits.com=2:4") and MAX_CONCURRENT_CONNECTIONS.
+func LoadRateLimitConfig() retriever.RateLimitConfig {
+	config := retriever.DefaultRateLimitConfig()
+	config.Default.RequestsPerSecond = getEnvFloat("RATE_LIMIT_RPS", config.Default.RequestsPerSecond)
+	config.Default.Burst = getEnvInt("RATE_LIMIT_BURST", config.Default.Burst)
+	config.MaxConcurrent = getEnvInt("MAX_CONCURRENT_CONNECTIONS", config.MaxConcurrent)
+
+	if value := os.Getenv("RATE_LIMIT_HOSTS"); value != "" {
+		hosts, err := parseHostRateLimits(value)
+		if err != nil {
+			log.Printf("Invalid value for RATE_LIMIT_HOSTS: %v, using defaults", err)
+		} else {
+			for host, limit := range hosts {
+				config.Hosts[host] = limit
+			}
+		}
+	}
+	return config
+}
+
+// LoadScrapperOptions reads COVER_ART_SIZE, the Bandcamp image format of the cover art
+// (e.g. 10 for 1200x1200, 16 for 700x700, 0 for the original upload), and LIBRARY_LAYOUT,
+// a preset name (default, plex, navidrome) or a template like
+// "{artist}/{year} - {album}/{track:02} - {title}.{ext}".
+func LoadScrapperOptions() scrapper.Options {
+	options := scrapper.DefaultOptions()
+	options.ArtworkSize = getEnvInt("COVER_ART_SIZE", options.ArtworkSize)
+
+	if value := os.Getenv("LIBRARY_LAYOUT"); value != "" {
+		template, err := layout.Load(value)
+		if err != nil {
+			log.Printf("Invalid value for LIBRARY_LAYOUT: %v, using %s", err, options.Layout)
+		} else {
+			options.Layout = template
+		}
+	}
+	return options
+}
+
+// LoadWatchConfig reads CATALOG_WATCH, whether the API keeps the album catalog in sync
+// with the changes made to BASE_FOLDER by other programs, and CATALOG_WATCH_DEBOUNCE.
+func LoadWatchConfig() album_catalog.WatchConfig {
+	config := album_catalog.DefaultWatchConfig()
+	config.Enabled = getEnvBool("CATALOG_WATCH", config.Enabled)
+	config.Debounce = getEnvDuration("CATALOG_WATCH_DEBOUNCE", config.Debounce)
+	return config
+}
+
+// LoadQueueConfig reads JOB_WORKERS, how many download jobs of the API run at the same
+// time, JOB_QUEUE_SIZE and JOB_HISTORY, how many finished jobs are listed.
+func LoadQueueConfig() jobs.QueueConfig {
+	config := jobs.DefaultQueueConfig()
+	config.Workers = getEnvInt("JOB_WORKERS", config.Workers)
+	config.Size = getEnvInt("JOB_QUEUE_SIZE", config.Size)
+	config.History = getEnvInt("JOB_HISTORY", config.History)
+	return config
+}
+
+func parseHostRateLimits(value string) (map[string]retriever.RateLimit, error) {
+	hosts := make(map[string]retriever.RateLimit)
+	for _, entry := range strings.Split(value, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		host, limit, found := strings.Cut(entry, "=")
+		if !found || host == "" {
+			return nil, fmt.Errorf("invalid entry %q, expected host=rps:burst", entry)
+		}
+		rps, burst, _ := strings.Cut(limit, ":")
+		requestsPerSecond, err := strconv.ParseFloat(rps, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid rate for %s: %w", host, err)
+		}
+		rateLimit := retriever.RateLimit{RequestsPerSecond: requestsPerSecond, Burst: 1}
+		if burst != "" {
+			if rateLimit.Burst, err = strconv.Atoi(burst); err != nil {
+				return nil, fmt.Errorf("invalid burst for %s: %w", host, err)
+			}
+		}
+		hosts[strings.ToLower(strings.TrimSpace(host))] = rateLimit
+	}
+	return hosts, nil
+}
+
+func getEnvInt(key string, fallback int) int {
+	value := os.Getenv(key)
+	if value == "" {
+		return fallback
+	}
+	parsed, err := strconv.Atoi(value)
+	if err != nil {
+		log.Printf("Invalid value for %s: %v, using %d", key, err, fallback)
+		return fallback
+	}
+	return parsed
+}
+
+func getEnvBool(key string, fallback bool) bool {
+	value := os.Getenv(key)
+	if value == "" {
+		return fallback
+	}
+	parsed, err := strconv.ParseBool(value)
+	if err != nil {
+		log.Printf("Invalid value for %s: %v, using %t", key, err, fallback)
+		return fallback
+	}
+	return parsed
+}
+
+func getEnvFloat(key string, fallback float64) float64 {
+	value := os.Getenv(key)
+	if value == "" {
+		return fallback
+	}
+	parsed, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		log.Printf("Invalid value for %s: %v, using %v", key, err, fallback)
+		return fallback
+	}
+	return parsed
+}
+
+func getEnvDuration(key string, fallback time.Duration) time.Duration {
+	value := os.Getenv(key)
+	if value == "" {
+		return fallback
+	}
+	parsed, err := time.ParseDuration(value)
+	if err != nil {
+		log.Printf("Invalid value for %s: %v, using %s", key, err, fallback)
+		return fallback
 	}
+	return parsed
 }
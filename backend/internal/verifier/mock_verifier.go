@@ -0,0 +1,124 @@
+// Code generated by MockGen. DO NOT EDIT.
+// Source: verifier.go
+
+// Package verifier is a generated GoMock package.
+package verifier
+
+import (
+	context "context"
+	reflect "reflect"
+
+	gomock "github.com/golang/mock/gomock"
+	album_catalog "github.com/josedelrio85/bndcmp_downloader/internal/album_catalog"
+)
+
+// MockVerifier is a mock of Verifier interface.
+type MockVerifier struct {
+	ctrl     *gomock.Controller
+	recorder *MockVerifierMockRecorder
+}
+
+// MockVerifierMockRecorder is the mock recorder for MockVerifier.
+type MockVerifierMockRecorder struct {
+	mock *MockVerifier
+}
+
+// NewMockVerifier creates a new mock instance.
+func NewMockVerifier(ctrl *gomock.Controller) *MockVerifier {
+	mock := &MockVerifier{ctrl: ctrl}
+	mock.recorder = &MockVerifierMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockVerifier) EXPECT() *MockVerifierMockRecorder {
+	return m.recorder
+}
+
+// Verify mocks base method.
+func (m *MockVerifier) Verify(ctx context.Context, repair bool) (*Report, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Verify", ctx, repair)
+	ret0, _ := ret[0].(*Report)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Verify indicates an expected call of Verify.
+func (mr *MockVerifierMockRecorder) Verify(ctx, repair interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Verify", reflect.TypeOf((*MockVerifier)(nil).Verify), ctx, repair)
+}
+
+// MockentryReader is a mock of entryReader interface.
+type MockentryReader struct {
+	ctrl     *gomock.Controller
+	recorder *MockentryReaderMockRecorder
+}
+
+// MockentryReaderMockRecorder is the mock recorder for MockentryReader.
+type MockentryReaderMockRecorder struct {
+	mock *MockentryReader
+}
+
+// NewMockentryReader creates a new mock instance.
+func NewMockentryReader(ctrl *gomock.Controller) *MockentryReader {
+	mock := &MockentryReader{ctrl: ctrl}
+	mock.recorder = &MockentryReaderMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockentryReader) EXPECT() *MockentryReaderMockRecorder {
+	return m.recorder
+}
+
+// Entry mocks base method.
+func (m *MockentryReader) Entry(path string) (album_catalog.Entry, bool) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Entry", path)
+	ret0, _ := ret[0].(album_catalog.Entry)
+	ret1, _ := ret[1].(bool)
+	return ret0, ret1
+}
+
+// Entry indicates an expected call of Entry.
+func (mr *MockentryReaderMockRecorder) Entry(path interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Entry", reflect.TypeOf((*MockentryReader)(nil).Entry), path)
+}
+
+// MockchecksumRecorder is a mock of checksumRecorder interface.
+type MockchecksumRecorder struct {
+	ctrl     *gomock.Controller
+	recorder *MockchecksumRecorderMockRecorder
+}
+
+// MockchecksumRecorderMockRecorder is the mock recorder for MockchecksumRecorder.
+type MockchecksumRecorderMockRecorder struct {
+	mock *MockchecksumRecorder
+}
+
+// NewMockchecksumRecorder creates a new mock instance.
+func NewMockchecksumRecorder(ctrl *gomock.Controller) *MockchecksumRecorder {
+	mock := &MockchecksumRecorder{ctrl: ctrl}
+	mock.recorder = &MockchecksumRecorderMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockchecksumRecorder) EXPECT() *MockchecksumRecorderMockRecorder {
+	return m.recorder
+}
+
+// SetChecksum mocks base method.
+func (m *MockchecksumRecorder) SetChecksum(path, checksum string) {
+	m.ctrl.T.Helper()
+	m.ctrl.Call(m, "SetChecksum", path, checksum)
+}
+
+// SetChecksum indicates an expected call of SetChecksum.
+func (mr *MockchecksumRecorderMockRecorder) SetChecksum(path, checksum interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SetChecksum", reflect.TypeOf((*MockchecksumRecorder)(nil).SetChecksum), path, checksum)
+}
@@ -0,0 +1,273 @@
+package verifier
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/josedelrio85/bndcmp_downloader/internal/album_catalog"
+	"github.com/josedelrio85/bndcmp_downloader/internal/mp3"
+	"github.com/josedelrio85/bndcmp_downloader/internal/saver"
+)
+
+const trackExtension = ".mp3"
+
+// Problem is what is wrong with a file of the library.
+type Problem string
+
+const (
+	ZeroByte        Problem = "zero_byte"
+	Truncated       Problem = "truncated"
+	OrphanedPartial Problem = "orphaned_partial"
+	// SizeMismatch is a track whose size changed since it was downloaded.
+	SizeMismatch Problem = "size_mismatch"
+	// BitRot is a file whose content changed although it was not written since it
+	// was cataloged: same size and modification time.
+	BitRot Problem = "bit_rot"
+	// Modified is a file written since it was saved, not by a new download as those
+	// update the manifest, e.g. retagged by a player. It is never repaired.
+	Modified Problem = "modified"
+)
+
+type Issue struct {
+	// Path is relative to the library folder, with forward slashes.
+	Path    string  `json:"path"`
+	Problem Problem `json:"problem"`
+	Detail  string  `json:"detail,omitempty"`
+	// Repaired is set once the file is removed and the catalog forgot it.
+	Repaired bool `json:"repaired"`
+}
+
+type Report struct {
+	Checked int     `json:"checked"`
+	Issues  []Issue `json:"issues"`
+}
+
+//go:generate mockgen -source=$GOFILE -package=$GOPACKAGE -destination=mock_$GOFILE
+type Verifier interface {
+	Verify(ctx context.Context, repair bool) (*Report, error)
+}
+
+// entryReader is implemented by the catalogs remembering the size of the downloaded tracks.
+type entryReader interface {
+	Entry(path string) (album_catalog.Entry, bool)
+}
+
+// checksumRecorder is implemented by the catalogs keeping checksums, the verifier
+// records those of the files the saver did not hash.
+type checksumRecorder interface {
+	SetChecksum(path string, checksum string)
+}
+
+// LibraryVerifier looks for the files of the library a scrape would not fix by itself:
+// they are in the catalog, so they count as downloaded.
+type LibraryVerifier struct {
+	baseFolder string
+	catalog    album_catalog.AlbumCatalog
+}
+
+func NewLibraryVerifier(baseFolder string, catalog album_catalog.AlbumCatalog) *LibraryVerifier {
+	return &LibraryVerifier{
+		baseFolder: baseFolder,
+		catalog:    catalog,
+	}
+}
+
+// Verify checks every file of the library. With repair, bad files are removed and
+// forgotten by the catalog and the manifest of their folder, so the next scrape downloads
+// them again. The partial files of the downloads in progress are left alone.
+func (v *LibraryVerifier) Verify(ctx context.Context, repair bool) (*Report, error) {
+	report := &Report{Issues: []Issue{}}
+	manifests := make(map[string]map[string]string)
+	err := filepath.WalkDir(v.baseFolder, func(filePath string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			if filePath == v.baseFolder {
+				return err
+			}
+			log.Printf("LibraryVerifier -> Verify -> error reading %s: %v\n", filePath, err)
+			return nil
+		}
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		// the quarantine of the saver and the databases
+		if filePath != v.baseFolder && saver.Internal(entry.Name()) {
+			if entry.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if entry.IsDir() {
+			return nil
+		}
+
+		dir := filepath.Dir(filePath)
+		if _, ok := manifests[dir]; !ok {
+			manifests[dir] = readManifest(dir)
+		}
+		report.Checked++
+		issue, found := v.check(filePath, manifests[dir])
+		if !found {
+			return nil
+		}
+		if repair {
+			issue.Repaired = v.repair(filePath, issue)
+		}
+		report.Issues = append(report.Issues, issue)
+		return nil
+	})
+	if err != nil {
+		log.Printf("LibraryVerifier -> Verify -> error walking %s: %v\n", v.baseFolder, err)
+		return nil, err
+	}
+	return report, nil
+}
+
+func (v *LibraryVerifier) check(filePath string, manifest map[string]string) (Issue, bool) {
+	issue := Issue{Path: v.relative(filePath)}
+	if saver.IsPartial(filePath) {
+		issue.Problem = OrphanedPartial
+		return issue, saver.Orphaned(filePath)
+	}
+
+	info, err := os.Stat(filePath)
+	if err != nil {
+		log.Printf("LibraryVerifier -> check -> error reading %s: %v\n", filePath, err)
+		return issue, false
+	}
+	if info.Size() == 0 {
+		issue.Problem = ZeroByte
+		return issue, true
+	}
+
+	var stored album_catalog.Entry
+	var cataloged bool
+	if entries, ok := v.catalog.(entryReader); ok {
+		stored, cataloged = entries.Entry(issue.Path)
+	}
+	if strings.EqualFold(filepath.Ext(filePath), trackExtension) {
+		if detail := truncation(filePath); detail != "" {
+			issue.Problem = Truncated
+			issue.Detail = detail
+			return issue, true
+		}
+		if cataloged && stored.Downloaded > 0 && stored.Downloaded != info.Size() {
+			issue.Problem = SizeMismatch
+			issue.Detail = fmt.Sprintf("downloaded %d bytes, found %d", stored.Downloaded, info.Size())
+			return issue, true
+		}
+	}
+
+	listed, inManifest := manifest[filepath.Base(filePath)]
+	unchanged := cataloged && stored.Size == info.Size() && stored.ModTime == info.ModTime().UnixNano()
+	if !inManifest && !unchanged {
+		return issue, false
+	}
+	actual, err := checksum(filePath)
+	if err != nil {
+		log.Printf("LibraryVerifier -> check -> error hashing %s: %v\n", filePath, err)
+		return issue, false
+	}
+	if unchanged && stored.Checksum == "" {
+		// the next verify tells whether the file rots from now on
+		if recorder, ok := v.catalog.(checksumRecorder); ok {
+			recorder.SetChecksum(issue.Path, actual)
+		}
+	}
+	switch {
+	case unchanged && stored.Checksum != "" && actual != stored.Checksum:
+		issue.Problem = BitRot
+		issue.Detail = fmt.Sprintf("sha256 %s, cataloged as %s", actual, stored.Checksum)
+		return issue, true
+	case inManifest && actual != listed:
+		issue.Problem = Modified
+		issue.Detail = fmt.Sprintf("sha256 %s, saved as %s", actual, listed)
+		return issue, true
+	default:
+		return issue, false
+	}
+}
+
+func checksum(filePath string) (string, error) {
+	file, err := os.Open(filePath)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+
+	hash := sha256.New()
+	if _, err := io.Copy(hash, file); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+// readManifest returns the checksums the saver recorded in dir, none if unreadable.
+func readManifest(dir string) map[string]string {
+	sums, err := saver.ReadManifest(dir)
+	if err != nil {
+		log.Printf("LibraryVerifier -> error reading the manifest of %s: %v\n", dir, err)
+	}
+	return sums
+}
+
+// truncation describes why the track at filePath is cut short, if it is: its ID3 tag
+// does not fit in the file, no audio follows it or its last frame is incomplete.
+func truncation(filePath string) string {
+	_, err := mp3.ReadFile(filePath)
+	var pathErr *fs.PathError
+	switch {
+	case err == nil:
+		return ""
+	case errors.As(err, &pathErr):
+		log.Printf("LibraryVerifier -> check -> error reading %s: %v\n", filePath, err)
+		return ""
+	case errors.Is(err, mp3.ErrNoFrames):
+		return "no audio after the ID3 tag"
+	case errors.Is(err, mp3.ErrTruncated):
+		return err.Error()
+	default:
+		return fmt.Sprintf("reading the ID3 tag: %v", err)
+	}
+}
+
+// repair removes the file of issue and forgets it in the catalog and the manifest.
+// Partial downloads are in neither, removing them is enough.
+func (v *LibraryVerifier) repair(filePath string, issue Issue) bool {
+	switch issue.Problem {
+	case Modified:
+		return false
+	case OrphanedPartial:
+		// a download may have picked it up since it was checked
+		removed, err := saver.RemoveOrphaned(filePath)
+		if err != nil {
+			log.Printf("LibraryVerifier -> repair -> error removing %s: %v\n", filePath, err)
+		}
+		return removed
+	}
+	if err := os.Remove(filePath); err != nil && !os.IsNotExist(err) {
+		log.Printf("LibraryVerifier -> repair -> error removing %s: %v\n", filePath, err)
+		return false
+	}
+	v.catalog.Remove(issue.Path)
+	if err := saver.UpdateManifest(filepath.Dir(filePath), filepath.Base(filePath), ""); err != nil {
+		log.Printf("LibraryVerifier -> repair -> error updating the manifest of %s: %v\n", filePath, err)
+	}
+	return true
+}
+
+func (v *LibraryVerifier) relative(filePath string) string {
+	rel, err := filepath.Rel(v.baseFolder, filePath)
+	if err != nil {
+		return filepath.ToSlash(filePath)
+	}
+	return filepath.ToSlash(rel)
+}
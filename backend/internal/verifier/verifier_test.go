@@ -0,0 +1,250 @@
+package verifier
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/josedelrio85/bndcmp_downloader/internal/album_catalog"
+	"github.com/josedelrio85/bndcmp_downloader/internal/id3"
+	"github.com/josedelrio85/bndcmp_downloader/internal/model"
+	"github.com/josedelrio85/bndcmp_downloader/internal/mp3/mp3test"
+	"github.com/josedelrio85/bndcmp_downloader/internal/saver"
+	"github.com/stretchr/testify/suite"
+)
+
+type VerifierTestSuite struct {
+	suite.Suite
+	tempDir  string
+	catalog  *album_catalog.BoltAlbumCatalog
+	verifier *LibraryVerifier
+	tag      []byte
+}
+
+func TestVerifierSuite(t *testing.T) {
+	suite.Run(t, new(VerifierTestSuite))
+}
+
+func (s *VerifierTestSuite) SetupTest() {
+	s.tempDir = s.T().TempDir()
+	catalog, err := album_catalog.NewBoltAlbumCatalog(s.tempDir, filepath.Join(s.tempDir, ".catalog.db"))
+	s.Require().NoError(err)
+	s.catalog = catalog
+	s.verifier = NewLibraryVerifier(s.tempDir, catalog)
+
+	s.tag, err = (&id3.Tag{Title: "Track"}).Bytes()
+	s.Require().NoError(err)
+
+	s.writeFile("Artist/Album/01 - Healthy.mp3", s.track("audio"))
+	s.writeFile("Artist/Album/cover.jpg", []byte("jpeg"))
+	s.writeFile("Artist/Album/02 - Empty.mp3", nil)
+	s.writeFile("Artist/Album/03 - Tag Only.mp3", s.tag)
+	s.writeFile("Artist/Album/04 - Cut In Tag.mp3", s.tag[:len(s.tag)-2])
+	s.writeFile("Artist/Album/05 - Shrunk.mp3", s.track("audio"))
+	s.writeFile("Artist/Album/06 - Orphan.mp3.part", []byte("aud"))
+	s.writeFile("Artist/Album/07 - Resumable.mp3.part", []byte("aud"))
+	s.writeFile("Artist/Album/07 - Resumable.mp3.part.validator", []byte(`"etag"`))
+	s.writeFile("Artist/Album/08 - Cut Off.mp3", s.track("")[:len(s.tag)+600])
+	s.writeFile("Artist/...Like Clockwork/01 - Empty.mp3", nil)
+	s.Require().NoError(s.catalog.Generate(s.tempDir))
+
+	s.catalog.Update("Artist/Album/01 - Healthy.mp3", &model.Track{TrackID: 1})
+	s.catalog.Update("Artist/Album/05 - Shrunk.mp3", &model.Track{TrackID: 5})
+	s.writeFile("Artist/Album/05 - Shrunk.mp3", s.track("aud"))
+	s.Require().NoError(s.catalog.Generate(s.tempDir))
+}
+
+func (s *VerifierTestSuite) TearDownTest() {
+	s.catalog.Close()
+}
+
+// track is tagged audio, ended by bytes that are not frames so tracks can differ.
+func (s *VerifierTestSuite) track(trailer string) []byte {
+	track := append(append([]byte(nil), s.tag...), mp3test.Frames(2)...)
+	return append(track, trailer...)
+}
+
+func (s *VerifierTestSuite) writeFile(rel string, content []byte) {
+	filePath := filepath.Join(s.tempDir, filepath.FromSlash(rel))
+	s.Require().NoError(os.MkdirAll(filepath.Dir(filePath), 0755))
+	s.Require().NoError(os.WriteFile(filePath, content, 0644))
+}
+
+func (s *VerifierTestSuite) exists(rel string) bool {
+	_, err := os.Stat(filepath.Join(s.tempDir, filepath.FromSlash(rel)))
+	return err == nil
+}
+
+func (s *VerifierTestSuite) problems(report *Report) map[string]Problem {
+	problems := make(map[string]Problem)
+	for _, issue := range report.Issues {
+		problems[issue.Path] = issue.Problem
+	}
+	return problems
+}
+
+func (s *VerifierTestSuite) TestVerify() {
+	report, err := s.verifier.Verify(context.Background(), false)
+
+	s.Require().NoError(err)
+	s.Equal(11, report.Checked)
+	s.Equal(map[string]Problem{
+		"Artist/Album/02 - Empty.mp3":             ZeroByte,
+		"Artist/Album/03 - Tag Only.mp3":          Truncated,
+		"Artist/Album/04 - Cut In Tag.mp3":        Truncated,
+		"Artist/Album/05 - Shrunk.mp3":            SizeMismatch,
+		"Artist/Album/06 - Orphan.mp3.part":       OrphanedPartial,
+		"Artist/Album/08 - Cut Off.mp3":           Truncated,
+		"Artist/...Like Clockwork/01 - Empty.mp3": ZeroByte,
+	}, s.problems(report))
+	for _, issue := range report.Issues {
+		s.False(issue.Repaired)
+		s.True(s.exists(issue.Path), "only reporting should not touch %s", issue.Path)
+	}
+	s.True(s.catalog.Contains("Artist/Album/05 - Shrunk.mp3"))
+}
+
+func (s *VerifierTestSuite) TestVerify_Repair() {
+	report, err := s.verifier.Verify(context.Background(), true)
+
+	s.Require().NoError(err)
+	s.Len(report.Issues, 7)
+	for _, issue := range report.Issues {
+		s.True(issue.Repaired)
+		s.False(s.exists(issue.Path), "%s should be removed", issue.Path)
+		s.False(s.catalog.Contains(issue.Path), "%s should be forgotten", issue.Path)
+	}
+	_, found := s.catalog.Lookup(5)
+	s.False(found, "the track should be downloaded again")
+
+	s.True(s.exists("Artist/Album/07 - Resumable.mp3.part"))
+	s.True(s.catalog.Contains("Artist/Album/01 - Healthy.mp3"))
+	_, found = s.catalog.Lookup(1)
+	s.True(found)
+
+	report, err = s.verifier.Verify(context.Background(), true)
+	s.Require().NoError(err)
+	s.Empty(report.Issues)
+}
+
+func (s *VerifierTestSuite) TestVerify_InMemoryCatalog() {
+	catalog := album_catalog.NewInMemoryAlbumCatalog(s.tempDir)
+	s.Require().NoError(catalog.Generate(s.tempDir))
+
+	report, err := NewLibraryVerifier(s.tempDir, catalog).Verify(context.Background(), true)
+
+	s.Require().NoError(err)
+	s.NotContains(s.problems(report), "Artist/Album/05 - Shrunk.mp3", "sizes are only known by the persistent catalog")
+	s.Contains(s.problems(report), "Artist/Album/02 - Empty.mp3")
+	s.Equal(Truncated, s.problems(report)["Artist/Album/08 - Cut Off.mp3"], "cut off audio is found without a downloaded size")
+	s.False(catalog.Contains("Artist/Album/02 - Empty.mp3"))
+}
+
+func (s *VerifierTestSuite) TestVerify_Canceled() {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	_, err := s.verifier.Verify(ctx, true)
+
+	s.ErrorIs(err, context.Canceled)
+	s.True(s.exists("Artist/Album/02 - Empty.mp3"))
+}
+
+// saveTrack mimics the saver: the checksum goes to the manifest and the catalog.
+func (s *VerifierTestSuite) saveTrack(rel string, content []byte) {
+	s.writeFile(rel, content)
+	sum := sha256.Sum256(content)
+	filePath := filepath.Join(s.tempDir, filepath.FromSlash(rel))
+	s.Require().NoError(saver.UpdateManifest(filepath.Dir(filePath), filepath.Base(filePath), hex.EncodeToString(sum[:])))
+	s.catalog.Update(rel, &model.Track{TrackID: 10, Checksum: hex.EncodeToString(sum[:])})
+}
+
+func (s *VerifierTestSuite) TestVerify_Checksums() {
+	s.saveTrack("Other/Album/01 - Rotten.mp3", s.track("audio"))
+	s.saveTrack("Other/Album/02 - Retagged.mp3", s.track("audio"))
+	s.saveTrack("Other/Album/03 - Downloaded Again.mp3", s.track("audio"))
+
+	rotten := filepath.Join(s.tempDir, "Other", "Album", "01 - Rotten.mp3")
+	info, err := os.Stat(rotten)
+	s.Require().NoError(err)
+	s.writeFile("Other/Album/01 - Rotten.mp3", s.track("audjo"))
+	s.Require().NoError(os.Chtimes(rotten, info.ModTime(), info.ModTime()))
+
+	s.writeFile("Other/Album/02 - Retagged.mp3", s.track("AUDIO"))
+	later := time.Now().Add(time.Minute)
+	s.Require().NoError(os.Chtimes(filepath.Join(s.tempDir, "Other", "Album", "02 - Retagged.mp3"), later, later))
+	s.Require().NoError(os.Chtimes(filepath.Join(s.tempDir, "Other", "Album"), later, later))
+	s.Require().NoError(s.catalog.Generate(s.tempDir))
+
+	s.saveTrack("Other/Album/03 - Downloaded Again.mp3", s.track("new audio"))
+
+	report, err := s.verifier.Verify(context.Background(), true)
+
+	s.Require().NoError(err)
+	problems := s.problems(report)
+	s.Equal(BitRot, problems["Other/Album/01 - Rotten.mp3"])
+	s.Equal(Modified, problems["Other/Album/02 - Retagged.mp3"])
+	s.NotContains(problems, "Other/Album/03 - Downloaded Again.mp3")
+
+	s.False(s.exists("Other/Album/01 - Rotten.mp3"))
+	s.False(s.catalog.Contains("Other/Album/01 - Rotten.mp3"))
+	s.True(s.exists("Other/Album/02 - Retagged.mp3"), "modified files are kept")
+	sums, err := saver.ReadManifest(filepath.Join(s.tempDir, "Other", "Album"))
+	s.NoError(err)
+	s.NotContains(sums, "01 - Rotten.mp3")
+	s.Contains(sums, "02 - Retagged.mp3")
+}
+
+func (s *VerifierTestSuite) TestVerify_RecordsChecksums() {
+	healthy := filepath.Join(s.tempDir, "Artist", "Album", "01 - Healthy.mp3")
+	entry, _ := s.catalog.Entry("Artist/Album/01 - Healthy.mp3")
+	s.Require().Empty(entry.Checksum, "scanned files are not hashed")
+
+	report, err := s.verifier.Verify(context.Background(), false)
+
+	s.Require().NoError(err)
+	s.NotContains(s.problems(report), "Artist/Album/01 - Healthy.mp3")
+	entry, _ = s.catalog.Entry("Artist/Album/01 - Healthy.mp3")
+	sum := sha256.Sum256(s.track("audio"))
+	s.Equal(hex.EncodeToString(sum[:]), entry.Checksum)
+
+	info, err := os.Stat(healthy)
+	s.Require().NoError(err)
+	s.writeFile("Artist/Album/01 - Healthy.mp3", s.track("audjo"))
+	s.Require().NoError(os.Chtimes(healthy, info.ModTime(), info.ModTime()))
+
+	report, err = s.verifier.Verify(context.Background(), false)
+
+	s.Require().NoError(err)
+	s.Equal(BitRot, s.problems(report)["Artist/Album/01 - Healthy.mp3"])
+}
+
+func (s *VerifierTestSuite) TestVerify_RepairWhileDownloading() {
+	reader, writer := io.Pipe()
+	album := "Album"
+	track := &model.Track{Title: "Downloading", TrackNumber: 9, Artist: "Artist", Album: &album}
+	saved := make(chan error)
+	go func() {
+		saved <- saver.NewLocalSaver(&s.tempDir, nil).Save(context.Background(), reader, track)
+	}()
+	_, err := writer.Write(mp3test.Frames(1))
+	s.Require().NoError(err)
+	var partials []string
+	s.Require().Eventually(func() bool {
+		partials, _ = filepath.Glob(filepath.Join(s.tempDir, "Artist", "Album", "*Downloading.mp3"+saver.PartialExtension))
+		return len(partials) == 1
+	}, time.Second, time.Millisecond)
+
+	report, err := s.verifier.Verify(context.Background(), true)
+
+	s.Require().NoError(err)
+	s.NotContains(s.problems(report), s.verifier.relative(partials[0]))
+	s.FileExists(partials[0], "the download in progress should keep writing it")
+	writer.CloseWithError(io.ErrUnexpectedEOF)
+	s.ErrorIs(<-saved, io.ErrUnexpectedEOF)
+}